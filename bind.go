@@ -0,0 +1,101 @@
+package form
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// Bind unmarshals the [*http.Request] form into i like [Unmarshal].
+// If unmarshalling fails then Bind writes the error to w with [WriteError] and returns false,
+// otherwise it returns true and nothing is written to w.
+//
+//	var p Person
+//	if !form.Bind(w, r, &p) {
+//		return
+//	}
+func Bind(w http.ResponseWriter, r *http.Request, i interface{}) bool {
+	return defaultDecoder.Bind(w, r, i)
+}
+
+// Bind unmarshals the [*http.Request] form into i like [Decoder.Unmarshal].
+// If unmarshalling fails then Bind writes the error to w with the Decoder's [Decoder.ErrorHandler] and returns false,
+// otherwise it returns true and nothing is written to w.
+func (d *Decoder) Bind(w http.ResponseWriter, r *http.Request, i interface{}) bool {
+	err := d.Unmarshal(r, i)
+	if err == nil {
+		return true
+	}
+	d.errorHandler(w, r, err)
+	return false
+}
+
+// An ErrorResponse is the JSON body written by [WriteError].
+type ErrorResponse struct {
+	Errors []ErrorDetail `json:"errors"`
+}
+
+// An ErrorDetail describes a single error in an [ErrorResponse].
+// Field, Key and Value are only set for errors caused by a specific struct field.
+type ErrorDetail struct {
+	Field   string `json:"field,omitempty"` // name of the Go struct field
+	Key     string `json:"key,omitempty"`   // form key of the field
+	Value   string `json:"value,omitempty"` // value from form that caused the error
+	Message string `json:"message"`
+}
+
+// WriteError writes err to w as a JSON [ErrorResponse].
+// [InvalidUnmarshalError] and [InvalidTagError] are programming errors rather than bad input,
+// so they are written with status 500 Internal Server Error and no details.
+// All other errors are written with status 400 Bad Request, with field details
+// for [UnmarshalTypeError], [ValidationError] and [MissingFieldError]. If err is a [MessageError] then its
+// message is written in place of the underlying error's.
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	status := http.StatusBadRequest
+	var detail ErrorDetail
+
+	var invalidUnmarshalErr *InvalidUnmarshalError
+	var invalidTagErr *InvalidTagError
+	var typeErr *UnmarshalTypeError
+	var validationErr *ValidationError
+	var missingErr *MissingFieldError
+	switch {
+	case errors.As(err, &invalidUnmarshalErr), errors.As(err, &invalidTagErr):
+		status = http.StatusInternalServerError
+		detail.Message = http.StatusText(status)
+	case errors.As(err, &typeErr):
+		detail = ErrorDetail{
+			Field:   typeErr.Field,
+			Key:     typeErr.Key,
+			Value:   typeErr.Value,
+			Message: typeErr.Err.Error(),
+		}
+	case errors.As(err, &missingErr):
+		detail = ErrorDetail{
+			Field:   missingErr.Field,
+			Key:     missingErr.Key,
+			Message: "missing value",
+		}
+		if missingErr.Condition != "" {
+			detail.Message += ", required when " + missingErr.Condition
+		}
+	case errors.As(err, &validationErr):
+		detail = ErrorDetail{
+			Field:   validationErr.Field,
+			Key:     validationErr.Key,
+			Value:   validationErr.Value,
+			Message: validationErr.Err.Error(),
+		}
+	default:
+		detail.Message = err.Error()
+	}
+
+	var msgErr *MessageError
+	if status == http.StatusBadRequest && errors.As(err, &msgErr) {
+		detail.Message = msgErr.Message
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(ErrorResponse{Errors: []ErrorDetail{detail}})
+}
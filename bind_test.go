@@ -0,0 +1,104 @@
+package form_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hunterwilkins2/form"
+)
+
+func TestBind(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Age int `form:"age"`
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, "/?age=30", nil)
+	w := httptest.NewRecorder()
+	var actual s
+	if !form.Bind(w, r, &actual) {
+		t.Fatalf("expected Bind to succeed")
+	}
+	if actual.Age != 30 {
+		t.Fatalf("wrong age. want=%d, got=%d", 30, actual.Age)
+	}
+	if w.Body.Len() != 0 {
+		t.Fatalf("expected nothing to be written. got=%s", w.Body.String())
+	}
+}
+
+func TestBindError(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Age   int    `form:"age"`
+		Name  string `form:"name,minlen=2"`
+		Email string `form:"email,minlen=3,msg=Please enter your email"`
+		Card  string `form:"card,requiredif=email=a@b"`
+	}
+
+	tests := []struct {
+		query    string
+		expected form.ErrorDetail
+	}{
+		{"age=thirty", form.ErrorDetail{Field: "Age", Key: "age", Value: "thirty", Message: `strconv.ParseInt: parsing "thirty": invalid syntax`}},
+		{"name=J", form.ErrorDetail{Field: "Name", Key: "name", Value: "J", Message: "length 1 is less than minlen 2"}},
+		{"email=x", form.ErrorDetail{Field: "Email", Key: "email", Value: "x", Message: "Please enter your email"}},
+		{"email=a@b&card=", form.ErrorDetail{Field: "Card", Key: "card", Message: "missing value, required when email=a@b"}},
+	}
+
+	for _, tt := range tests {
+		r, _ := http.NewRequest(http.MethodGet, "/?"+tt.query, nil)
+		w := httptest.NewRecorder()
+		if form.Bind(w, r, &s{}) {
+			t.Fatalf("expected Bind to fail for %s", tt.query)
+		}
+
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("wrong status code. want=%d, got=%d", http.StatusBadRequest, w.Code)
+		}
+		if w.Header().Get("Content-Type") != "application/json" {
+			t.Fatalf("wrong content type. want=%s, got=%s", "application/json", w.Header().Get("Content-Type"))
+		}
+		var resp form.ErrorResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("unexpected error decoding response: %s", err)
+		}
+		if len(resp.Errors) != 1 || resp.Errors[0] != tt.expected {
+			t.Fatalf("wrong error response. want=%+v, got=%+v", tt.expected, resp.Errors)
+		}
+	}
+}
+
+func TestBindInvalidTarget(t *testing.T) {
+	t.Parallel()
+	r, _ := http.NewRequest(http.MethodGet, "/", nil)
+	w := httptest.NewRecorder()
+	if form.Bind(w, r, nil) {
+		t.Fatalf("expected Bind to fail")
+	}
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("wrong status code. want=%d, got=%d", http.StatusInternalServerError, w.Code)
+	}
+}
+
+func TestDecoderErrorHandler(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Age int `form:"age"`
+	}
+
+	d := form.NewDecoder().ErrorHandler(func(w http.ResponseWriter, r *http.Request, err error) {
+		http.Error(w, "bad age", http.StatusUnprocessableEntity)
+	})
+
+	r, _ := http.NewRequest(http.MethodGet, "/?age=old", nil)
+	w := httptest.NewRecorder()
+	if d.Bind(w, r, &s{}) {
+		t.Fatalf("expected Bind to fail")
+	}
+	if w.Code != http.StatusUnprocessableEntity || w.Body.String() != "bad age\n" {
+		t.Fatalf("custom error handler not used. got status=%d, body=%q", w.Code, w.Body.String())
+	}
+}
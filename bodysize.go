@@ -0,0 +1,58 @@
+package form
+
+import (
+	"errors"
+	"io"
+	"net/http"
+)
+
+// ErrBodyTooLarge is wrapped in a [ParseError] when a request body is larger than the limit set with
+// [Decoder.MaxBodySize], or than the limit on bodies read whole for JSON and rawbody fields.
+var ErrBodyTooLarge = errors.New("request body too large")
+
+// MaxBodySize limits the request body to n bytes, as a cheap guard against clients sending huge bodies.
+// A request whose Content-Length exceeds n is rejected with a [ParseError] wrapping [ErrBodyTooLarge]
+// before any of the body is read. A body of unknown length, with a Content-Length of -1, is read
+// through a reader that fails with the same error once more than n bytes have been read.
+// The limit covers URL encoded, multipart and JSON bodies alike. A value of 0 or less removes the limit,
+// which is the default.
+func (d *Decoder) MaxBodySize(n int64) *Decoder {
+	d.maxBodySize = max(n, 0)
+	return d
+}
+
+// limitBody rejects the body of r if its declared length exceeds the Decoder's MaxBodySize,
+// and otherwise wraps it so reading past the limit fails.
+func (d *Decoder) limitBody(r *http.Request) error {
+	if d.maxBodySize == 0 || r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+	if r.ContentLength > d.maxBodySize {
+		return &ParseError{Err: ErrBodyTooLarge}
+	}
+	r.Body = &limitedBody{ReadCloser: r.Body, n: d.maxBodySize}
+	return nil
+}
+
+// limitedBody is a request body that returns ErrBodyTooLarge once more than n bytes would be read.
+type limitedBody struct {
+	io.ReadCloser
+	n int64 // bytes left before the limit
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.n <= 0 {
+		var extra [1]byte
+		n, err := b.ReadCloser.Read(extra[:])
+		if n > 0 {
+			return 0, ErrBodyTooLarge
+		}
+		return 0, err
+	}
+	if int64(len(p)) > b.n {
+		p = p[:b.n]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.n -= int64(n)
+	return n, err
+}
@@ -0,0 +1,63 @@
+package form
+
+import (
+	"strconv"
+	"strings"
+)
+
+// boolTokens are the tokens set with [Decoder.BoolTokens].
+type boolTokens struct {
+	truthy, falsy []string
+}
+
+// BoolTokens sets the tokens the Decoder accepts as true and false for every bool field, and for integer fields
+// with the bool option, so an application can define its policy once, such as yes and no or ja and nein.
+// Tokens are matched ignoring case. They are tried before the tokens [strconv.ParseBool] accepts, which still
+// work, and any other value returns a [UnmarshalTypeError]. A token in both lists is true.
+func (d *Decoder) BoolTokens(truthy, falsy []string) *Decoder {
+	d.boolTokens = boolTokens{
+		truthy: append([]string(nil), truthy...),
+		falsy:  append([]string(nil), falsy...),
+	}
+	return d
+}
+
+// match reports the bool value is a token for, and whether it is one of the tokens.
+func (bt boolTokens) match(value string) (bool, bool) {
+	for _, t := range bt.truthy {
+		if strings.EqualFold(value, t) {
+			return true, true
+		}
+	}
+	for _, t := range bt.falsy {
+		if strings.EqualFold(value, t) {
+			return false, true
+		}
+	}
+	return false, false
+}
+
+// parseBoolToken parses value as a boolean token for an integer field with the bool option:
+// a token set with [Decoder.BoolTokens], any value accepted by [strconv.ParseBool], or on and off
+// as sent by HTML checkboxes.
+func (d *Decoder) parseBoolToken(value string) (bool, bool) {
+	if b, ok := d.boolTokens.match(value); ok {
+		return b, true
+	}
+	switch value {
+	case "on":
+		return true, true
+	case "off":
+		return false, true
+	}
+	b, err := strconv.ParseBool(value)
+	return b, err == nil
+}
+
+// boolInt returns 1 for true and 0 for false.
+func boolInt(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,68 @@
+package form
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// byteEncodings are the tag options that encode a byte array or slice field as a single value.
+var byteEncodings = map[string]*struct {
+	decode func(string) ([]byte, error)
+	encode func([]byte) string
+}{
+	"hex":    {hex.DecodeString, hex.EncodeToString},
+	"base64": {base64.StdEncoding.DecodeString, base64.StdEncoding.EncodeToString},
+}
+
+// isBytes reports whether t is a byte array or byte slice.
+func isBytes(t reflect.Type) bool {
+	return (t.Kind() == reflect.Array || t.Kind() == reflect.Slice) && t.Elem().Kind() == reflect.Uint8
+}
+
+// parseEncodedBytes decodes a single value with the field's byte encoding into the byte array or slice f.
+// Arrays require the decoded value to be exactly their length.
+func parseEncodedBytes(f reflect.Value, fld field, values []string) *UnmarshalTypeError {
+	if len(values) == 0 {
+		return nil
+	}
+	if len(values) != 1 {
+		return &UnmarshalTypeError{
+			Value: "[" + strings.Join(values, ", ") + "]",
+			Type:  f.Type(),
+			Err:   fmt.Errorf("cannot unmarshal more than one value for %s encoded field", fld.encoding),
+		}
+	}
+
+	b, err := byteEncodings[fld.encoding].decode(values[0])
+	if err != nil {
+		return &UnmarshalTypeError{
+			Value: values[0],
+			Type:  f.Type(),
+			Err:   err,
+		}
+	}
+
+	if f.Kind() == reflect.Slice {
+		f.SetBytes(b)
+		return nil
+	}
+	if len(b) != f.Len() {
+		return &UnmarshalTypeError{
+			Value: values[0],
+			Type:  f.Type(),
+			Err:   fmt.Errorf("decoded %d bytes but %s needs %d", len(b), f.Type(), f.Len()),
+		}
+	}
+	reflect.Copy(f, reflect.ValueOf(b))
+	return nil
+}
+
+// formatEncodedBytes encodes the byte array or slice f with the field's byte encoding.
+func formatEncodedBytes(f reflect.Value, fld field) string {
+	b := make([]byte, f.Len())
+	reflect.Copy(reflect.ValueOf(b), f)
+	return byteEncodings[fld.encoding].encode(b)
+}
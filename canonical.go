@@ -0,0 +1,62 @@
+package form
+
+import (
+	"sort"
+	"strings"
+)
+
+// MarshalCanonical encodes the fields with the "form" struct tag into their canonical query string,
+// as described by [Encoder.MarshalCanonical], with the default options.
+func MarshalCanonical(i interface{}) (string, error) {
+	return defaultEncoder.MarshalCanonical(i)
+}
+
+// MarshalCanonical encodes the fields with the "form" struct tag into a canonical query string without
+// touching a request, as a stable input for signing such as with an HMAC. It encodes the same values as
+// [Encoder.Marshal], with fixed rules so that equal structs always give identical bytes:
+//
+//   - keys are sorted byte-wise, ignoring [Encoder.PreserveOrder] and [Encoder.KeyOrder]
+//   - the values of a repeated key keep the order they were marshalled in, such as that of a slice
+//   - keys and values are percent-encoded as in RFC 3986: every byte other than the unreserved
+//     characters A-Z, a-z, 0-9, '-', '.', '_' and '~' is written as '%' and two uppercase hex digits,
+//     so a space is "%20" rather than "+"
+//   - each pair is written as key=value, with an empty value as "key=", and pairs are joined with '&'
+//     with no leading or trailing separator
+//
+// An empty struct gives the empty string.
+func (e *Encoder) MarshalCanonical(i interface{}) (string, error) {
+	form, keys, err := e.encode(i)
+	if err != nil {
+		return "", err
+	}
+	sort.Strings(keys)
+
+	var buf strings.Builder
+	for _, k := range keys {
+		for _, v := range form[k] {
+			if buf.Len() > 0 {
+				buf.WriteByte('&')
+			}
+			percentEncode(&buf, k)
+			buf.WriteByte('=')
+			percentEncode(&buf, v)
+		}
+	}
+	return buf.String(), nil
+}
+
+// percentEncode writes s to buf with every byte other than the unreserved characters of RFC 3986
+// percent-encoded with uppercase hex digits.
+func percentEncode(buf *strings.Builder, s string) {
+	const hexDigits = "0123456789ABCDEF"
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '.' || c == '_' || c == '~' {
+			buf.WriteByte(c)
+			continue
+		}
+		buf.WriteByte('%')
+		buf.WriteByte(hexDigits[c>>4])
+		buf.WriteByte(hexDigits[c&15])
+	}
+}
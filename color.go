@@ -0,0 +1,52 @@
+package form
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+)
+
+// parseColor parses a hex color such as #ff8800, or its short form #f80, for an integer field with the color option.
+// Each digit of the short form is doubled, so #f80 is 0xff8800.
+func parseColor(value string) (uint64, error) {
+	if len(value) != 4 && len(value) != 7 || value[0] != '#' {
+		return 0, fmt.Errorf("%q is not a color of the form #rgb or #rrggbb", value)
+	}
+	digits := value[1:]
+	if len(digits) == 3 {
+		digits = string([]byte{digits[0], digits[0], digits[1], digits[1], digits[2], digits[2]})
+	}
+	v, err := strconv.ParseUint(digits, 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a color of the form #rgb or #rrggbb", value)
+	}
+	return v, nil
+}
+
+// maxColor is the largest value of a color, #ffffff.
+const maxColor = 0xffffff
+
+// formatColor formats v as a hex color with six digits, such as #ff8800.
+// Values above #ffffff have more digits than parseColor reads back, so they are an error.
+func formatColor(v uint64) (string, error) {
+	if v > maxColor {
+		return "", fmt.Errorf("value %#x is larger than the color #ffffff", v)
+	}
+	return fmt.Sprintf("#%06x", v), nil
+}
+
+// addColor adds the color v of the integer field f to form under tag, or returns a [MarshalTypeError]
+// if v is too large to be a color.
+func addColor(tag string, f reflect.Value, v uint64, form url.Values) *MarshalTypeError {
+	color, err := formatColor(v)
+	if err != nil {
+		return &MarshalTypeError{
+			Type:  f.Type(),
+			Value: marshalValue(f),
+			Err:   err,
+		}
+	}
+	form.Add(tag, color)
+	return nil
+}
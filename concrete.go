@@ -0,0 +1,107 @@
+package form
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// RegisterConcrete registers a factory the Decoder calls to allocate the concrete value of fields
+// whose type is the interface type iface, including embedded interfaces.
+// If the concrete value, or the value its pointer points to, is a struct then the struct's own
+// tagged fields are unmarshalled from the form, as if they were fields of the outer struct.
+// Otherwise the field's form value is parsed into the concrete value as for a field of that type.
+// The factory should return a pointer if the concrete type's methods have pointer receivers.
+//
+// Only one concrete type can be registered per interface type; registering another replaces it.
+// Interface fields without a registered factory are set to the raw string value when a string
+// can be assigned to them, as for interface{}, and return a [UnmarshalTypeError] otherwise.
+func (d *Decoder) RegisterConcrete(iface reflect.Type, factory func() interface{}) *Decoder {
+	if d.concrete == nil {
+		d.concrete = make(map[reflect.Type]func() interface{})
+	}
+	d.concrete[iface] = factory
+	return d
+}
+
+// newConcrete calls the factory registered for the interface type t. It returns the value to set
+// the interface to and the addressable value to bind form values into, which is the value a
+// returned pointer points to. Both are invalid if no factory is registered for t.
+func (d *Decoder) newConcrete(t reflect.Type) (v, target reflect.Value, err error) {
+	factory, ok := d.concrete[t]
+	if !ok {
+		return v, target, nil
+	}
+
+	c := factory()
+	v = reflect.ValueOf(c)
+	if !v.IsValid() || !v.Type().Implements(t) {
+		return reflect.Value{}, target, fmt.Errorf("concrete factory for %s returned %T, which does not implement it", t, c)
+	}
+	if v.Kind() == reflect.Pointer && !v.IsNil() {
+		return v, v.Elem(), nil
+	}
+	target = reflect.New(v.Type()).Elem()
+	target.Set(v)
+	return target, target, nil
+}
+
+// unmarshalConcreteStruct allocates the concrete value of the interface field f bound to key and,
+// if it is a struct, unmarshals the struct's fields. It reports whether f was handled.
+func (d *Decoder) unmarshalConcreteStruct(f reflect.Value, key string, ds *decodeState) (bool, error) {
+	if !f.CanSet() {
+		return true, nil
+	}
+
+	v, target, err := d.newConcrete(f.Type())
+	if err != nil {
+		return true, &UnmarshalTypeError{
+			Value: key,
+			Type:  f.Type(),
+			Err:   err,
+		}
+	}
+	if target.Kind() != reflect.Struct || target.Type() == timeType {
+		return false, nil
+	}
+
+	err = d.unmarshalFields(target, ds)
+	if err != nil {
+		return true, err
+	}
+	f.Set(v)
+	return true, nil
+}
+
+// parseInterface parses value into the concrete value registered for the interface field f,
+// or sets f to value itself if no concrete type is registered and a string can be assigned to f.
+func (d *Decoder) parseInterface(f reflect.Value, fld field, value string) *UnmarshalTypeError {
+	v, target, err := d.newConcrete(f.Type())
+	if err != nil {
+		return &UnmarshalTypeError{
+			Value: value,
+			Type:  f.Type(),
+			Err:   err,
+		}
+	}
+
+	if !v.IsValid() {
+		rv := reflect.ValueOf(value)
+		if !rv.Type().AssignableTo(f.Type()) {
+			return &UnmarshalTypeError{
+				Value: value,
+				Type:  f.Type(),
+				Err:   fmt.Errorf("type %s cannot be unmarshalled from form without a concrete type", f.Type()),
+			}
+		}
+		f.Set(rv)
+		return nil
+	}
+
+	parseErr := d.parseFormValue(target, fld, value)
+	if parseErr != nil {
+		parseErr.Type = f.Type()
+		return parseErr
+	}
+	f.Set(v)
+	return nil
+}
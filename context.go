@@ -0,0 +1,62 @@
+package form
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+)
+
+// A DecodeContextFunc is a [DecodeFunc] that receives the context of the call, so expensive work such as
+// a DNS lookup can stop when the context is cancelled or its deadline passes.
+type DecodeContextFunc func(ctx context.Context, value string) (interface{}, error)
+
+// RegisterDecoderContext registers a function the Decoder uses to unmarshal values of type t, like
+// [Decoder.RegisterDecoder], which it replaces for t. The function receives the context given to
+// [Decoder.UnmarshalContext], or the request's context for [Decoder.Unmarshal], and should return
+// the context's error once it is done, which is wrapped in the returned [UnmarshalTypeError].
+func (d *Decoder) RegisterDecoderContext(t reflect.Type, dec DecodeContextFunc) *Decoder {
+	if d.ctxDecoders == nil {
+		d.ctxDecoders = make(map[reflect.Type]DecodeContextFunc)
+	}
+	d.ctxDecoders[t] = dec
+	delete(d.decoders, t)
+	return d
+}
+
+// UnmarshalContext is like [Unmarshal], passing ctx to the functions registered with [Decoder.RegisterDecoderContext]
+// rather than the request's context, for example to bound them with a shorter timeout.
+func UnmarshalContext(ctx context.Context, r *http.Request, i interface{}) error {
+	return defaultDecoder.UnmarshalContext(ctx, r, i)
+}
+
+// UnmarshalContext is like [Decoder.Unmarshal], passing ctx to the functions registered with
+// [Decoder.RegisterDecoderContext] rather than the request's context.
+func (d *Decoder) UnmarshalContext(ctx context.Context, r *http.Request, i interface{}) error {
+	return d.withContext(ctx).unmarshal(r, i, nil, nil)
+}
+
+// withContext returns a copy of d whose context-aware decoders receive ctx, or d itself if it has none.
+func (d *Decoder) withContext(ctx context.Context) *Decoder {
+	if len(d.ctxDecoders) == 0 {
+		return d
+	}
+	dc := *d
+	dc.ctx = ctx
+	return &dc
+}
+
+// contextDecodeFunc returns the function registered with [Decoder.RegisterDecoderContext] for t bound to the
+// Decoder's context, or nil if there is none.
+func (d *Decoder) contextDecodeFunc(t reflect.Type) DecodeFunc {
+	dec, ok := d.ctxDecoders[t]
+	if !ok {
+		return nil
+	}
+	ctx := d.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return func(value string) (interface{}, error) {
+		return dec(ctx, value)
+	}
+}
@@ -0,0 +1,39 @@
+package form
+
+import (
+	"reflect"
+	"strings"
+)
+
+// DecimalComma makes the Decoder parse float fields with a comma as the decimal separator,
+// as written in many European locales, so 10,49 unmarshals as 10.49. Values with a point are still accepted.
+// A comma cannot also separate elements, so a float slice or array field with the sep option and
+// no other separator returns a [UnmarshalTypeError]; use a different separator such as sep=; instead.
+func (d *Decoder) DecimalComma() *Decoder {
+	d.decimalComma = true
+	return d
+}
+
+// DecimalComma makes the Encoder write float fields with a comma as the decimal separator, so 10.49 is marshalled as 10,490000.
+// Combined with the sep option the elements of a float slice must be joined by a separator other than a comma,
+// as they must be for [Decoder.DecimalComma], and a comma separator returns a [MarshalTypeError].
+// Other Encoders write floats with a point whatever the options of the Decoder reading them.
+func (e *Encoder) DecimalComma() *Encoder {
+	e.decimalComma = true
+	return e
+}
+
+// fromDecimalComma replaces the decimal comma in value with a point.
+func fromDecimalComma(value string) string {
+	return strings.Replace(value, ",", ".", 1)
+}
+
+// toDecimalComma replaces the decimal point in value with a comma.
+func toDecimalComma(value string) string {
+	return strings.Replace(value, ".", ",", 1)
+}
+
+// isFloat reports whether t is a float type.
+func isFloat(t reflect.Type) bool {
+	return t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64
+}
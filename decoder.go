@@ -0,0 +1,689 @@
+package form
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// A Decoder unmarshals [*http.Request] forms into Go structs.
+// The zero value is not usable, create Decoders with [NewDecoder].
+//
+// Options are set by calling methods on the Decoder, each of which returns the Decoder so calls can be chained.
+// Options must be set before the Decoder is used and not changed while it may be used concurrently.
+type Decoder struct {
+	errorHandler func(w http.ResponseWriter, r *http.Request, err error)
+	useScanner   bool
+	validators   []func(i interface{}, values url.Values) error
+	arrayLength  ArrayLength
+	maxMapDepth  int
+	overflow     Overflow
+	sparseIndex  SparseIndex
+	multiValue   MultiValue
+	decoders     map[reflect.Type]DecodeFunc
+	ctxDecoders  map[reflect.Type]DecodeContextFunc
+	skipErrors   bool
+	concrete     map[reflect.Type]func() interface{}
+	decimalComma bool
+	allowJSON    bool
+	underscores  bool
+	normalizers  map[reflect.Type]func(reflect.Value)
+	multipart    bool
+	emptySlices  bool
+	nesting      Nesting
+	fallbackTag  string
+
+	keepTrailingEmpty bool
+	unknownEnumZero   bool
+	lenientNumbers    bool
+	untaggedNames     bool
+	boolTokens        boolTokens
+	maxMemory         int64
+	maxBodySize       int64
+
+	maxSliceLen    int
+	hasMaxSliceLen bool
+	keyFunc        func(fieldName, tag string) string
+	logger         *slog.Logger
+	ctx            context.Context // context of the call for ctxDecoders, set on a copy of the Decoder
+}
+
+// ArrayLength controls how array fields are unmarshalled when the form has fewer values than the array's length.
+type ArrayLength int
+
+const (
+	// ArrayExact requires the form to have exactly as many values as the array's length.
+	ArrayExact ArrayLength = iota
+	// ArrayPad allows fewer values than the array's length, leaving the remaining elements at their zero value.
+	// Use the count tag option to record how many values were provided.
+	ArrayPad
+)
+
+// defaultDecoder is used by the package level functions.
+var defaultDecoder = NewDecoder()
+
+// NewDecoder returns a Decoder with the default options used by [Unmarshal].
+func NewDecoder() *Decoder {
+	return &Decoder{
+		errorHandler: WriteError,
+		maxMapDepth:  2,
+	}
+}
+
+// ErrorHandler sets the function [Decoder.Bind] uses to write the response when unmarshalling fails.
+// By default [WriteError] is used.
+func (d *Decoder) ErrorHandler(h func(w http.ResponseWriter, r *http.Request, err error)) *Decoder {
+	d.errorHandler = h
+	return d
+}
+
+// UseScanner unmarshals form values into fields whose pointer implements [sql.Scanner]
+// by calling Scan with the value as a string. The Scanner takes precedence over the
+// built-in handling of the field's kind, so a named string type implementing
+// [sql.Scanner] is scanned rather than set directly, but functions registered with
+// [Decoder.RegisterDecoder] or [RegisterType] take precedence over the Scanner.
+// Each element of a slice or array field is scanned separately.
+func (d *Decoder) UseScanner() *Decoder {
+	d.useScanner = true
+	return d
+}
+
+// Validator registers a function that is called after every field of the struct has been unmarshalled.
+// It receives the pointer passed to [Decoder.Unmarshal] and the parsed form values of the request,
+// so it can check rules spanning several fields or the raw values.
+// Validators are called in the order they were registered and the first error is returned
+// from [Decoder.Unmarshal] unchanged.
+func (d *Decoder) Validator(v func(i interface{}, values url.Values) error) *Decoder {
+	d.validators = append(d.validators, v)
+	return d
+}
+
+// ArrayLengthMode sets how array fields with fewer form values than their length are unmarshalled.
+// The default is [ArrayExact]. More values than the array's length is always an error.
+func (d *Decoder) ArrayLengthMode(m ArrayLength) *Decoder {
+	d.arrayLength = m
+	return d
+}
+
+// KeyFunc sets a function that computes the form key of every field from the name of the Go struct field
+// and the key in its "form" struct tag, which is empty for untagged fields. Without a KeyFunc the tag's key is used.
+// The function decides whether the tag takes precedence, for example:
+//
+//	d.KeyFunc(func(fieldName, tag string) string {
+//		if tag == "" {
+//			tag = strings.ToLower(fieldName)
+//		}
+//		return "v2_" + tag
+//	})
+//
+// The key returned is used everywhere the tag's key would be, including bracket and indexed keys and errors.
+// Options are still read from the tag. It must be safe to call concurrently and should return the same key
+// each time it is called with the same arguments.
+func (d *Decoder) KeyFunc(fn func(fieldName, tag string) string) *Decoder {
+	d.keyFunc = fn
+	return d
+}
+
+// MaxMapDepth sets the maximum number of levels of nested maps a map field may have.
+// The default of 2 allows fields such as map[string]map[string]string, bound from keys like config[db][host].
+// Unmarshalling a field with more levels returns a [UnmarshalTypeError].
+func (d *Decoder) MaxMapDepth(n int) *Decoder {
+	d.maxMapDepth = n
+	return d
+}
+
+// KeepTrailingEmpty keeps the empty elements at the end of a value split by the sep option,
+// so a,b, unmarshals into three elements, the last of them empty, as for CSV rows whose trailing
+// fields are significant. By default trailing empty elements are dropped and a,b, unmarshals into two.
+// Leading and consecutive separators always produce empty elements, so ,a,,b unmarshals into four.
+func (d *Decoder) KeepTrailingEmpty() *Decoder {
+	d.keepTrailingEmpty = true
+	return d
+}
+
+// Unmarshal parses the [*http.Request] form and populates the struct fields with the "form" struct tag in i.
+// It behaves like the package level [Unmarshal] with the options of d applied.
+func (d *Decoder) Unmarshal(r *http.Request, i interface{}) error {
+	return d.unmarshal(r, i, nil, nil)
+}
+
+// UnmarshalRecord behaves like [Decoder.Unmarshal] and also stores in m the raw form values each field was
+// unmarshalled from, before any default, sep splitting or parsing, keyed by the field's form key or by its name
+// if it has no key, as the errors option is. The fields of an element of a slice of structs are keyed under
+// its index, as in "items[0].name". Fields whose key is absent are not stored, nor are map and url.Values
+// fields or slices bound from indexed keys. Binding is unchanged. m is only written during the call,
+// so the Decoder can still be shared while each call records into a map of its own.
+func (d *Decoder) UnmarshalRecord(r *http.Request, i interface{}, m map[string][]string) error {
+	return d.unmarshal(r, i, nil, m)
+}
+
+// DecodeFrom populates the struct fields with the "form" struct tag in i from values, rather than from
+// a request, so the caller chooses the exact source, such as r.URL.Query() on one route and r.PostForm on another.
+// Every option of d applies, including tag validation, [Decoder.SkipErrors] and validators, which receive values.
+// Fields with the rawbody, method, path, segment or pathvalue options are left unchanged, as there is no request to read.
+// The package level [UnmarshalValues] is DecodeFrom with the default options.
+func (d *Decoder) DecodeFrom(values url.Values, i interface{}) error {
+	s, _, err := structValue(i)
+	if err != nil {
+		return err
+	}
+	return d.decode(i, s, &decodeState{form: values})
+}
+
+// unmarshal implements [Decoder.Unmarshal], recording skipped fields in report and raw values in record
+// if they are not nil.
+func (d *Decoder) unmarshal(r *http.Request, i interface{}, report *Report, record map[string][]string) error {
+	_, err := d.unmarshalTargets(r, []interface{}{i}, report, record)
+	return err
+}
+
+// unmarshalTargets parses the form of r once and unmarshals it into each of targets in turn.
+// It returns the index of the target that failed, or -1 if the failure is not specific to one target.
+func (d *Decoder) unmarshalTargets(r *http.Request, targets []interface{}, report *Report, record map[string][]string) (int, error) {
+	if d.ctx == nil {
+		d = d.withContext(r.Context())
+	}
+	structs := make([]reflect.Value, len(targets))
+	fields := make([][]field, len(targets))
+	hasRaw := false
+	for n, i := range targets {
+		s, fs, err := structValue(i)
+		if err != nil {
+			return n, err
+		}
+		structs[n], fields[n] = s, fs
+		if _, ok := rawBodyField(fs); ok {
+			hasRaw = true
+		}
+	}
+	if err := d.limitBody(r); err != nil {
+		return -1, err
+	}
+	var body []byte
+	if hasRaw {
+		var err error
+		body, err = readRawBody(r)
+		if err != nil {
+			return -1, err
+		}
+	}
+
+	var form, postForm url.Values
+	var err error
+	if d.allowJSON && isJSON(r) {
+		form, postForm, err = parseJSON(r)
+	} else if d.multipart && isMultipart(r) {
+		err = parseMultipart(r, d.maxMemory)
+		form, postForm = r.Form, r.PostForm
+	} else {
+		err = parseForm(r)
+		form, postForm = r.Form, r.PostForm
+	}
+	if hasRaw && body != nil {
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	if err != nil {
+		return -1, err
+	}
+
+	for n, i := range targets {
+		s := structs[n]
+		if raw, ok := rawBodyField(fields[n]); ok {
+			setRawBody(raw.parentOf(s).Field(raw.index), body)
+		}
+		if err := d.setRequestAttrs(r, s, fields[n]); err != nil {
+			return n, err
+		}
+
+		ds := &decodeState{form: form, report: report, record: record}
+		ds.sources = requestSources(r, postForm, ds)
+		if err := d.decode(i, s, ds); err != nil {
+			return n, err
+		}
+	}
+	return -1, nil
+}
+
+// structValue returns the struct i points to along with its fields.
+// If i is not a non-nil pointer to a struct then a [InvalidUnmarshalError] is returned.
+func structValue(i interface{}) (reflect.Value, []field, error) {
+	rv := reflect.ValueOf(i)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return reflect.Value{}, nil, &InvalidUnmarshalError{
+			Type: reflect.TypeOf(i),
+		}
+	}
+
+	s := rv.Elem()
+	fields, err := cachedFields(s.Type())
+	if err != nil {
+		return reflect.Value{}, nil, err
+	}
+	return s, fields, nil
+}
+
+// decode unmarshals the form of ds into the struct s that i points to and then runs the validators.
+func (d *Decoder) decode(i interface{}, s reflect.Value, ds *decodeState) error {
+	err := d.unmarshalFields(s, ds)
+	if err != nil {
+		return err
+	}
+
+	for _, v := range d.validators {
+		err := v(i, ds.form)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// unmarshalFields unmarshals the fields with a "form" struct tag of the struct s.
+func (d *Decoder) unmarshalFields(s reflect.Value, ds *decodeState) error {
+	fields, err := cachedFields(s.Type())
+	if err != nil {
+		return err
+	}
+
+	var errs reflect.Value
+	var raw []field
+	for _, f := range fields {
+		if f.errs && len(f.parent) == 0 {
+			errs = s.Field(f.index)
+			errs.Set(reflect.Zero(errs.Type()))
+		}
+		if f.raw != "" && len(f.parent) == 0 {
+			raw = append(raw, f)
+		}
+	}
+
+	// fail records err for the field f of ps, returning it unless errors are skipped.
+	fail := func(ps reflect.Value, f field, err error) error {
+		err = f.withMessage(err)
+		if errs.IsValid() {
+			recordError(errs, f, err)
+		}
+		if d.skipErrors {
+			skipField(ps, f, err, ds.report)
+			return nil
+		}
+		return err
+	}
+
+	var bound []string
+	for _, f := range fields {
+		if f.errs || f.raw != "" || f.rawBody || f.request != "" {
+			continue
+		}
+		ps := f.parentOf(s)
+		f.key = d.fieldKey(ps, f)
+		f, err = d.resolveConditions(ps, fields, f)
+		if err != nil {
+			return err
+		}
+		if f.group != nil && !f.group.holds(ps) {
+			continue
+		}
+		if d.logger != nil {
+			d.warnField(ps, f, ds)
+		}
+		if f.key == "" {
+			continue
+		}
+		bound = append(bound, f.key)
+		var start time.Time
+		if ds.report != nil {
+			start = time.Now()
+		}
+		err := d.unmarshalField(ps, f, ds.source(f))
+		if ds.report != nil {
+			d.timeDecoder(ds, f, ps.Field(f.index).Type(), start)
+		}
+		if err != nil {
+			if err := fail(ps, f, err); err != nil {
+				return err
+			}
+		}
+	}
+	for _, f := range fields {
+		if f.match == nil {
+			continue
+		}
+		ps := f.parentOf(s)
+		f, err = d.resolveConditions(ps, fields, f)
+		if err != nil {
+			return err
+		}
+		if f.group != nil && !f.group.holds(ps) {
+			continue
+		}
+		if err := checkMatch(ps, f, ds.form); err != nil {
+			if err := fail(ps, f, err); err != nil {
+				return err
+			}
+		}
+	}
+	for _, f := range raw {
+		s.Field(f.index).Set(reflect.ValueOf(rawValues(ds.form, f.raw, bound)))
+	}
+	return nil
+}
+
+// fieldKey returns the form key d binds the field f of the struct s from: its tag key or, for a field without one,
+// its key in the [Decoder.FallbackTag] or its Go name with [Decoder.UntaggedFieldNames], passed through the [Decoder.KeyFunc].
+func (d *Decoder) fieldKey(s reflect.Value, f field) string {
+	key := f.key
+	if key == "" && d.fallbackTag != "" {
+		key = f.fallbackKey(d.fallbackTag)
+	}
+	if key == "" && d.untaggedNames {
+		key = f.nameKey(s)
+	}
+	if d.keyFunc != nil {
+		key = d.keyFunc(f.name, key)
+	}
+	return key
+}
+
+// recordError adds the message of err to the map field errs under the key of f, or the key err
+// reports for an element of a slice of structs, or the name of f if it has no key, allocating the map on first use.
+func recordError(errs reflect.Value, f field, err error) {
+	if errs.IsNil() {
+		errs.Set(reflect.MakeMap(errs.Type()))
+	}
+	key := errorKey(f, err)
+	if key == "" {
+		key = f.name
+	}
+	errs.SetMapIndex(reflect.ValueOf(key).Convert(errs.Type().Key()), reflect.ValueOf(err.Error()).Convert(errs.Type().Elem()))
+}
+
+// errorKey returns the form key reported by err, which differs from the key of f for errors of
+// the elements of a slice of structs, such as items[0][qty], or the key of f if err reports none.
+func errorKey(f field, err error) string {
+	var typeErr *UnmarshalTypeError
+	var validationErr *ValidationError
+	var missingErr *MissingFieldError
+	switch {
+	case errors.As(err, &typeErr) && typeErr.Key != "":
+		return typeErr.Key
+	case errors.As(err, &validationErr) && validationErr.Key != "":
+		return validationErr.Key
+	case errors.As(err, &missingErr) && missingErr.Key != "":
+		return missingErr.Key
+	}
+	return f.key
+}
+
+// decodeState holds the state of a single call to [Decoder.Unmarshal].
+type decodeState struct {
+	form   url.Values
+	nested map[string][]string // keys of form in bracket notation grouped by their base key, built on first use
+	dotted map[string][]string // keys of form in dot notation grouped by their base key, built on first use
+	report *Report             // receives skipped fields, or nil if no report was requested
+	record map[string][]string // receives the raw values of each field, or nil if none was requested
+
+	sources map[string]*decodeState // states of the sources named by the "in" option, or nil without a request
+
+	keyPrefix string // prefix of the keys of form in the request, such as items[0]. for an element of a slice of structs
+}
+
+// nestedKeys returns the keys of the form that start with key followed by an opening bracket, in sorted order.
+// The keys of the whole form are grouped in a single pass the first time nestedKeys is called.
+func (ds *decodeState) nestedKeys(key string) []string {
+	if ds.nested == nil {
+		ds.nested = groupNestedKeys(ds.form, '[')
+	}
+	return ds.nested[key]
+}
+
+// mapKeys returns the keys of the form that nest under key in the notation m, in sorted order.
+func (ds *decodeState) mapKeys(key string, m Nesting) []string {
+	if m != NestingDot {
+		return ds.nestedKeys(key)
+	}
+	if ds.dotted == nil {
+		ds.dotted = groupNestedKeys(ds.form, '.')
+	}
+	return ds.dotted[key]
+}
+
+// unmarshalField parses and validates the values of f in the form into its field of the struct s.
+func (d *Decoder) unmarshalField(s reflect.Value, f field, ds *decodeState) error {
+	form := ds.form
+	if fv := s.Field(f.index); fv.Kind() == reflect.Interface && d.concrete[fv.Type()] != nil {
+		handled, err := d.unmarshalConcreteStruct(fv, f.key, ds)
+		if handled || err != nil {
+			if err, ok := err.(*UnmarshalTypeError); ok && err.Struct == "" {
+				err.Struct = s.Type().Name()
+				err.Field = f.name
+				err.Key = f.key
+			}
+			return err
+		}
+	}
+
+	if fv := s.Field(f.index); fv.Type() == valuesType && f.key != "" {
+		if len(subFormKeys(form, f.key)) == 0 {
+			if err := f.checkRequired(s); err != nil {
+				return err
+			}
+		}
+		err := d.parseSubForm(fv, f.key, form)
+		if err != nil {
+			err.Struct = s.Type().Name()
+			err.Field = f.name
+			err.Key = f.key
+			return err
+		}
+		return nil
+	}
+
+	if fv := s.Field(f.index); fv.Kind() == reflect.Map && f.key != "" {
+		if len(ds.mapKeys(f.key, d.nesting)) == 0 {
+			if err := f.checkRequired(s); err != nil {
+				return err
+			}
+		}
+		err := d.parseMap(fv, f, f.key, ds)
+		if err != nil {
+			err.Struct = s.Type().Name()
+			err.Field = f.name
+			err.Key = f.key
+			return err
+		}
+		return nil
+	}
+
+	if fv := s.Field(f.index); isStructSlice(fv.Type()) && f.key != "" {
+		n, err := d.parseStructSlice(s.Type().Name(), fv, f, ds)
+		if err, ok := err.(*UnmarshalTypeError); ok && err.Struct == "" {
+			err.Struct = s.Type().Name()
+			err.Field = f.name
+			err.Key = f.key
+		}
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return f.checkRequired(s)
+		}
+		if f.count >= 0 {
+			setCount(s.Field(f.count), n)
+		}
+		return nil
+	}
+
+	fv := s.Field(f.index)
+	if d.decimalComma && f.sep == "," && isFloat(elemType(fv.Type())) {
+		return &UnmarshalTypeError{
+			Value:  f.key,
+			Type:   fv.Type(),
+			Struct: s.Type().Name(),
+			Field:  f.name,
+			Key:    f.key,
+			Err:    fmt.Errorf("sep cannot split around commas when DecimalComma is set"),
+		}
+	}
+
+	values := formValues(form, f.key, fv)
+	if ds.record != nil && len(values) > 0 {
+		key := f.key
+		if key == "" {
+			key = f.name
+		}
+		ds.record[ds.keyPrefix+key] = append([]string(nil), values...)
+	}
+	indexed := ds.indexedKeys(f, fv.Type())
+	if len(indexed) == 0 && missing(values) {
+		if def, ok := f.opts.Get("default"); ok {
+			values = []string{def}
+		}
+	}
+	if fv.Kind() == reflect.Slice {
+		n := len(values)
+		if f.sep != "" {
+			n = splitLen(values, f.sep)
+		}
+		if err := d.checkSliceLen(fv.Type(), f.key, n); err != nil {
+			err.Struct = s.Type().Name()
+			err.Field = f.name
+			err.Key = f.key
+			return err
+		}
+	}
+	empty := len(indexed) == 0 && d.emptySlice(fv, f, values)
+	if f.sep != "" {
+		values = splitValues(values, f.sep, d.keepTrailingEmpty)
+	}
+
+	if len(indexed) == 0 && missing(values) {
+		if err := f.checkRequired(s); err != nil {
+			return err
+		}
+	}
+	if empty {
+		fv.Set(reflect.MakeSlice(fv.Type(), 0, 0))
+		values = nil
+	}
+
+	n := len(values)
+	var err *UnmarshalTypeError
+	if len(indexed) > 0 {
+		n, err = d.parseIndexed(fv, f, indexed, values, ds)
+	} else {
+		err = d.parseFormValues(fv, f, values)
+	}
+	if err != nil {
+		err.Struct = s.Type().Name()
+		err.Field = f.name
+		err.Key = f.key
+		return err
+	}
+
+	if f.count >= 0 {
+		setCount(s.Field(f.count), n)
+	}
+
+	if n > 0 {
+		d.normalize(fv)
+		validationErr := f.rules.validate(s.Field(f.index))
+		if validationErr != nil {
+			validationErr.Struct = s.Type().Name()
+			validationErr.Field = f.name
+			validationErr.Key = f.key
+			return validationErr
+		}
+	}
+	return nil
+}
+
+// formValues returns the values of key in form.
+// Slice and array fields also receive the values of key with a trailing "[]",
+// as sent by clients that use PHP style keys such as ids[]=1&ids[]=2.
+func formValues(form url.Values, key string, f reflect.Value) []string {
+	if f.Kind() != reflect.Slice && f.Kind() != reflect.Array {
+		return form[key]
+	}
+
+	values, bracketed := form[key], form[key+"[]"]
+	if len(bracketed) == 0 {
+		return values
+	}
+	if len(values) == 0 {
+		return bracketed
+	}
+	return append(append(make([]string, 0, len(values)+len(bracketed)), values...), bracketed...)
+}
+
+// splitValues splits each of values around sep, returning the elements of all values in order.
+// Empty elements at the end of each value are dropped unless keepTrailing is set,
+// so a,b, splits into two elements, while leading and consecutive separators still produce empty elements.
+func splitValues(values []string, sep string, keepTrailing bool) []string {
+	split := make([]string, 0, splitLen(values, sep))
+	for _, value := range values {
+		elems := strings.Split(value, sep)
+		if !keepTrailing {
+			for len(elems) > 0 && elems[len(elems)-1] == "" {
+				elems = elems[:len(elems)-1]
+			}
+		}
+		split = append(split, elems...)
+	}
+	return split
+}
+
+// parseForm calls [http.Request.ParseForm], wrapping any error in a [ParseError].
+// If the request has a URL encoded body with a known length but no bytes of it can be read,
+// the body was most likely consumed before unmarshalling and a [ParseError] wrapping [ErrBodyConsumed] is returned.
+func parseForm(r *http.Request) error {
+	var body *countingReader
+	if r.PostForm == nil && r.ContentLength > 0 && r.Body != nil && r.Body != http.NoBody {
+		ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if ct == "application/x-www-form-urlencoded" {
+			body = &countingReader{ReadCloser: r.Body}
+			r.Body = body
+			defer func() { r.Body = body.ReadCloser }()
+		}
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		return &ParseError{Err: err}
+	}
+	if body != nil && body.n == 0 {
+		return &ParseError{Err: ErrBodyConsumed}
+	}
+	return nil
+}
+
+// countingReader counts the bytes read from a request body.
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// setCount sets the integer field count to the number of values n bound to another field.
+func setCount(count reflect.Value, n int) {
+	if count.CanInt() {
+		count.SetInt(int64(n))
+	} else {
+		count.SetUint(uint64(n))
+	}
+}
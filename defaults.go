@@ -0,0 +1,121 @@
+package form
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// An inapplicableError reports a tag option used on a field of a type it does not apply to.
+type inapplicableError struct {
+	kinds string       // description of the fields the option applies to
+	t     reflect.Type // type of the field it was used on
+}
+
+func (e *inapplicableError) Error() string {
+	return fmt.Sprintf("option only applies to %s fields, not %s", e.kinds, e.t)
+}
+
+// inapplicable returns the error for an option that only applies to the fields described by kinds used on a field of type t.
+func inapplicable(kinds string, t reflect.Type) error {
+	return &inapplicableError{kinds: kinds, t: t}
+}
+
+// fieldOnlyOptions are the options that identify a single field and so cannot be struct defaults.
+var fieldOnlyOptions = []string{"count", "requiredif", "group", "errors", "raw", "rawbody", "method", "path", "segment", "pathvalue", "match", "default", "dropdefault", "msg", "prefix"}
+
+// exclusiveOptions are groups of options of which a field has at most one, so a field with one of
+// them inherits none of the others from the struct defaults.
+var exclusiveOptions = [][]string{{"hex", "base64", "json"}, {"sep", "json"}, {"in", "source"}}
+
+// isDefaultsField reports whether sf is a blank field whose "form" tag holds the struct's default options,
+// as in _ struct{} `form:",sep=;"`.
+func isDefaultsField(sf reflect.StructField) bool {
+	_, ok := sf.Tag.Lookup("form")
+	return ok && sf.Name == "_"
+}
+
+// structDefaults returns the default options of the struct type t, or nil if it has none.
+// A defaults field with a key or a field-only option returns a [InvalidTagError].
+func structDefaults(t reflect.Type) (tagOptions, *InvalidTagError) {
+	var defaults tagOptions
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !isDefaultsField(sf) {
+			continue
+		}
+		key, opts := parseTag(sf.Tag.Get("form"))
+		if key != "" {
+			return nil, &InvalidTagError{
+				Struct: t.Name(),
+				Field:  sf.Name,
+				Option: key,
+				Err:    fmt.Errorf("struct defaults cannot have a key"),
+			}
+		}
+		for _, name := range fieldOnlyOptions {
+			if opts.Has(name) {
+				return nil, &InvalidTagError{
+					Struct: t.Name(),
+					Field:  sf.Name,
+					Option: name,
+					Err:    fmt.Errorf("option cannot be a struct default"),
+				}
+			}
+		}
+		if defaults == nil {
+			defaults = make(tagOptions)
+		}
+		for name, value := range opts {
+			defaults[name] = value
+		}
+	}
+	return defaults, nil
+}
+
+// withDefaults parses the i'th field of the struct type t with the options of defaults it does not set itself.
+// A default option that does not apply to the field's type is left out rather than returning an error,
+// so a default such as sep only affects the slice fields. Errors from the field's own options are returned.
+func withDefaults(t reflect.Type, i int, defaults tagOptions) (field, *InvalidTagError) {
+	key, opts := parseTag(t.Field(i).Tag.Get("form"))
+	merged := make(tagOptions, len(opts)+len(defaults))
+	for name, value := range opts {
+		merged[name] = value
+	}
+	inherited := make(map[string]bool)
+	for name, value := range defaults {
+		if !merged.Has(name) && !exclusiveWith(opts, name) {
+			merged[name] = value
+			inherited[name] = true
+		}
+	}
+
+	for {
+		f, err := parseField(t, i, key, merged)
+		var inapplicable *inapplicableError
+		if err == nil || !inherited[err.Option] || !errors.As(err.Err, &inapplicable) {
+			return f, err
+		}
+		delete(merged, err.Option)
+		delete(inherited, err.Option)
+	}
+}
+
+// exclusiveWith reports whether opts holds an option that excludes the option name.
+func exclusiveWith(opts tagOptions, name string) bool {
+	for _, group := range exclusiveOptions {
+		in := false
+		for _, o := range group {
+			in = in || o == name
+		}
+		if !in {
+			continue
+		}
+		for _, o := range group {
+			if opts.Has(o) {
+				return true
+			}
+		}
+	}
+	return false
+}
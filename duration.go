@@ -0,0 +1,47 @@
+package form
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// durationUnits are the values accepted by the unit tag option.
+var durationUnits = map[string]time.Duration{
+	"ns": time.Nanosecond,
+	"us": time.Microsecond,
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+}
+
+// parseDuration parses value as a Go duration string such as "1h30m",
+// or as an integer number of unit when unit is not zero.
+func parseDuration(value string, unit time.Duration) (time.Duration, error) {
+	if unit == 0 {
+		return time.ParseDuration(value)
+	}
+
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	d := time.Duration(n) * unit
+	if d/unit != time.Duration(n) {
+		return 0, fmt.Errorf("%s overflows %s value", value, durationType)
+	}
+	return d, nil
+}
+
+// formatDuration formats d with [time.Duration.String],
+// or as an integer number of unit, truncated towards zero, when unit is not zero.
+func formatDuration(d time.Duration, unit time.Duration) string {
+	if unit == 0 {
+		return d.String()
+	}
+	return strconv.FormatInt(int64(d/unit), 10)
+}
@@ -0,0 +1,45 @@
+package form
+
+import "reflect"
+
+// EmptyValueAsEmptySlice makes the Decoder unmarshal a slice field whose key has a single empty value,
+// such as tags=, into an empty slice rather than a slice holding one zero element. Multi-select widgets
+// often submit an empty key this way to mean nothing was selected. The empty slice is not nil, so it can
+// be told apart from an absent key, and the field's count is set to 0.
+// Arrays, fields with the hex or base64 options and types registered with [Decoder.RegisterDecoder]
+// or [RegisterType] are unaffected.
+func (d *Decoder) EmptyValueAsEmptySlice() *Decoder {
+	d.emptySlices = true
+	return d
+}
+
+// emptySlice reports whether values should unmarshal into the slice field f as an empty slice.
+func (d *Decoder) emptySlice(f reflect.Value, fld field, values []string) bool {
+	return d.emptySlices && f.Kind() == reflect.Slice && fld.encoding == "" && d.decodeFunc(f.Type()) == nil &&
+		len(values) == 1 && values[0] == ""
+}
+
+// EmptySlice controls how slice fields without elements are marshalled.
+type EmptySlice int
+
+const (
+	// EmptySliceOmit writes nothing for both nil and empty slices, so the two cannot be told apart.
+	EmptySliceOmit EmptySlice = iota
+	// EmptySliceEmit writes an empty value, such as tags=, for an empty non-nil slice and nothing for a nil slice,
+	// for APIs that treat a present but empty list as clearing it. [Decoder.EmptyValueAsEmptySlice] reads
+	// the empty value back as an empty slice.
+	EmptySliceEmit
+)
+
+// EmptySliceMode sets how slice fields without elements are marshalled. The default is [EmptySliceOmit].
+// [Encoder.EmitEmpty] still writes an empty value for nil slices, and fields with the omitempty option
+// are still omitted.
+func (e *Encoder) EmptySliceMode(m EmptySlice) *Encoder {
+	e.emptySlices = m
+	return e
+}
+
+// emitEmptySlice reports whether the Encoder writes an empty value for the field f because it is an empty non-nil slice.
+func (e *Encoder) emitEmptySlice(f reflect.Value) bool {
+	return e.emptySlices == EmptySliceEmit && f.Kind() == reflect.Slice && !f.IsNil() && f.Len() == 0
+}
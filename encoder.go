@@ -0,0 +1,372 @@
+package form
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+)
+
+// A Encoder marshals Go structs into [*http.Request] forms.
+// The zero value is not usable, create Encoders with [NewEncoder].
+//
+// Options are set by calling methods on the Encoder, each of which returns the Encoder so calls can be chained.
+// Options must be set before the Encoder is used and not changed while it may be used concurrently.
+type Encoder struct {
+	bracketSlices bool
+	preserveOrder bool
+	keyOrder      []string
+	mapKeyLess    func(a, b string) bool
+	emitEmpty     bool
+	emptySlices   EmptySlice
+	decimalComma  bool
+	numericBools  bool
+	flatten       bool
+	floatFormat   byte
+	nesting       Nesting
+	collectErrors bool
+	keyFunc       func(fieldName, tag string) string
+	encoders      map[reflect.Type]EncodeFunc
+	untaggedNames bool
+	fileParts     *[]filePart // collects the file fields of [Encoder.MarshalMultipart], set on a copy of the Encoder
+}
+
+// defaultEncoder is used by the package level functions.
+var defaultEncoder = NewEncoder()
+
+// NewEncoder returns a Encoder with the default options used by [Marshal].
+func NewEncoder() *Encoder {
+	return &Encoder{}
+}
+
+// BracketSlices appends "[]" to the keys of slice and array fields, so a field tagged `form:"ids"`
+// is encoded as ids[]=1&ids[]=2 for clients that expect PHP style keys.
+func (e *Encoder) BracketSlices() *Encoder {
+	e.bracketSlices = true
+	return e
+}
+
+// PreserveOrder writes the marshalled query with keys in the order of the struct's field declarations,
+// rather than sorted by key as [url.Values.Encode] does. Repeated keys keep the order of their slice elements.
+// This gives a reproducible order for requests that are signed.
+// Keys and values are both escaped with [url.QueryEscape], exactly as [url.Values.Encode] escapes them.
+func (e *Encoder) PreserveOrder() *Encoder {
+	e.preserveOrder = true
+	return e
+}
+
+// KeyOrder writes the marshalled query with the given keys first, in the order listed, followed by the
+// other keys in the order of the struct's field declarations as with [Encoder.PreserveOrder].
+// This matches servers that canonicalize signed requests in an order that differs from the struct's.
+// A listed key also matches the same key with a trailing "[]" written by [Encoder.BracketSlices].
+// Listed keys the struct does not write are ignored.
+func (e *Encoder) KeyOrder(keys []string) *Encoder {
+	e.keyOrder = append([]string{}, keys...)
+	return e
+}
+
+// MapKeyOrder sorts the keys of map fields with less, which reports whether the key a is
+// written before the key b, instead of in increasing string order. Go maps have no order of
+// their own, not even that of insertion, so their keys are always sorted to give reproducible
+// output. The sort applies at every level of nested maps, and shows in the marshalled query
+// with [Encoder.PreserveOrder] or [Encoder.KeyOrder], as the query is otherwise sorted by key.
+func (e *Encoder) MapKeyOrder(less func(a, b string) bool) *Encoder {
+	e.mapKeyLess = less
+	return e
+}
+
+// EmitEmpty writes an empty value, such as key=, for fields that would otherwise write nothing:
+// nil pointers, interfaces, slices and maps, and empty slices. This lets the receiver tell a field
+// that is present but empty apart from one that is absent. Other zero values are already written,
+// as name= for a string and n=0 for a number. Fields with the omitempty option are still omitted.
+func (e *Encoder) EmitEmpty() *Encoder {
+	e.emitEmpty = true
+	return e
+}
+
+// NumericBools marshals every bool field as 1 or 0 rather than true or false, as the numeric tag option does for a single field.
+// Both forms are accepted when unmarshalling.
+func (e *Encoder) NumericBools() *Encoder {
+	e.numericBools = true
+	return e
+}
+
+// Flatten marshals every slice and array field holding a single element under its plain key, as the
+// flatten tag option does for a single field, so with [Encoder.BracketSlices] ids=1 is written
+// rather than ids[]=1. Fields with more elements are still written with the brackets.
+func (e *Encoder) Flatten() *Encoder {
+	e.flatten = true
+	return e
+}
+
+// CollectErrors makes the Encoder marshal every field it can and return a [MarshalErrors] holding a
+// [MarshalTypeError] for each field that cannot be marshalled, rather than stopping at the first.
+// This lists every unsupported field of a struct in one pass. The request is left unchanged if any field fails.
+func (e *Encoder) CollectErrors() *Encoder {
+	e.collectErrors = true
+	return e
+}
+
+// KeyFunc sets a function that computes the form key of every field from the name of the Go struct field
+// and the key in its "form" struct tag, as [Decoder.KeyFunc] does when unmarshalling.
+// Fields for which it returns an empty key are not marshalled.
+func (e *Encoder) KeyFunc(fn func(fieldName, tag string) string) *Encoder {
+	e.keyFunc = fn
+	return e
+}
+
+// Marshal encodes the fields with the "form" struct tag into a URL encoded form on the request.
+// It behaves like the package level [Marshal] with the options of e applied.
+func (e *Encoder) Marshal(r *http.Request, i interface{}) error {
+	form, keys, err := e.encode(i)
+	if err != nil {
+		return err
+	}
+
+	if e.keyOrder != nil {
+		r.URL.RawQuery = encodeOrdered(form, orderKeys(keys, e.keyOrder))
+	} else if e.preserveOrder {
+		r.URL.RawQuery = encodeOrdered(form, keys)
+	} else {
+		r.URL.RawQuery = form.Encode()
+	}
+	return nil
+}
+
+// encode marshals the struct pointed to by i into form values.
+// The keys are returned in the order they were first written.
+func (e *Encoder) encode(i interface{}) (url.Values, []string, error) {
+	rv := reflect.ValueOf(i)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return nil, nil, &InvalidMarshalError{
+			Type: reflect.TypeOf(i),
+		}
+	}
+
+	s := rv.Elem()
+	if s.Kind() != reflect.Struct {
+		return nil, nil, &InvalidMarshalError{
+			Type: reflect.TypeOf(i),
+		}
+	}
+
+	fields, err := cachedFields(s.Type())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	form := make(url.Values)
+	keys := make([]string, 0, len(fields))
+	var errs []*MarshalTypeError
+	for _, f := range fields {
+		if f.errs || f.raw != "" || f.rawBody || f.request != "" {
+			continue
+		}
+		ps := f.parentOf(s)
+		if f.key == "" && e.untaggedNames {
+			f.key = f.nameKey(ps)
+		}
+		if e.keyFunc != nil {
+			f.key = e.keyFunc(f.name, f.key)
+		}
+		if f.key == "" {
+			continue
+		}
+		key := f.key
+		fv := ps.Field(f.index)
+		if f.opts.Has("omitempty") && isEmptyValue(fv) {
+			continue
+		}
+		if f.opts.Has("dropdefault") && e.isDefaultValue(fv, f) {
+			continue
+		}
+		if e.fileParts != nil && isFileType(fv.Type()) {
+			if !isEmptyValue(fv) {
+				*e.fileParts = append(*e.fileParts, filePart{key: key, value: fv, field: f, parent: ps})
+			}
+			continue
+		}
+		if fv.Type() == valuesType {
+			keys = marshalSubForm(key, valuesOf(fv), e.nesting, form, keys)
+			continue
+		}
+		if fv.Kind() == reflect.Map && mapDepth(fv.Type()) > 0 && e.encodeFunc(fv.Type()) == nil {
+			n := len(keys)
+			var err *MarshalTypeError
+			keys, err = e.marshalMap(key, fv, f, form, keys)
+			if err != nil {
+				err.Struct = ps.Type().Name()
+				err.Field = f.name
+				if !e.collectErrors {
+					return nil, nil, err
+				}
+				errs = append(errs, err)
+				continue
+			}
+			if e.emitEmpty && len(keys) == n && !form.Has(key) {
+				form.Add(key, "")
+				keys = append(keys, key)
+			}
+			continue
+		}
+		if e.bracketSlices && f.repeated(fv.Type()) && e.encodeFunc(fv.Type()) == nil &&
+			!((e.flatten || f.opts.Has("flatten")) && fv.Len() == 1) {
+			key += "[]"
+		}
+
+		_, seen := form[key]
+		n := len(form[key])
+		var err *MarshalTypeError
+		if f.sep != "" {
+			err = e.marshalSeparated(key, fv, f, form)
+		} else {
+			err = e.marshalFormValues(key, fv, f, form)
+		}
+		if err != nil {
+			err.Struct = ps.Type().Name()
+			err.Field = f.name
+			if !e.collectErrors {
+				return nil, nil, err
+			}
+			errs = append(errs, err)
+			continue
+		}
+		if (e.emitEmpty || e.emitEmptySlice(fv)) && len(form[key]) == n {
+			form.Add(key, "")
+		}
+		if !seen && form.Has(key) {
+			keys = append(keys, key)
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, nil, &MarshalErrors{Errors: errs}
+	}
+	return form, keys, nil
+}
+
+// decimalCommaDecoder parses the default option of fields for an Encoder with DecimalComma set.
+var decimalCommaDecoder = NewDecoder().DecimalComma()
+
+// isDefaultValue reports whether v equals the value of the default option of f, parsed as by [Unmarshal]
+// with the DecimalComma option of e. A value of a type with an encode function, registered with
+// [Encoder.RegisterEncoder] or [RegisterType], equals the default when it encodes to the same text,
+// as there may be no matching decode function. Decode functions registered on a Decoder are unknown
+// to e, so a slice of such types whose default cannot be parsed is never equal to it.
+// The value of an unexported field cannot be compared and is never equal to the default.
+func (e *Encoder) isDefaultValue(v reflect.Value, f field) bool {
+	if !v.CanInterface() {
+		return false
+	}
+	def, _ := f.opts.Get("default")
+	if enc := e.encodeFunc(v.Type()); enc != nil {
+		s, err := enc(v.Interface())
+		return err == nil && s == def
+	}
+	values := []string{def}
+	if f.sep != "" {
+		values = splitValues(values, f.sep, false)
+	}
+	d := defaultDecoder
+	if e.decimalComma {
+		d = decimalCommaDecoder
+	}
+	dv := reflect.New(v.Type()).Elem()
+	if err := d.parseFormValues(dv, f, values); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(v.Interface(), dv.Interface())
+}
+
+// formatNumericBool formats b as 1 or 0.
+func formatNumericBool(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
+
+// isEmptyValue reports whether v is empty for the omitempty option: false, 0, a nil pointer or interface,
+// an empty string, slice, array or map, or a zero struct. A struct with an IsZero method, such as
+// [time.Time], is empty when the method reports true, so the zero time in any location is omitted.
+// The method cannot be called on the value of an unexported field, which is empty when it is the zero value.
+func isEmptyValue(v reflect.Value) bool {
+	if v.Kind() == reflect.Struct && v.CanInterface() {
+		if z, ok := v.Interface().(interface{ IsZero() bool }); ok {
+			return z.IsZero()
+		}
+	}
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Pointer, reflect.Interface:
+		return v.IsNil()
+	default:
+		return v.IsZero()
+	}
+}
+
+// orderKeys returns keys with those listed in order moved to the front, in the order listed.
+// The other keys keep their relative order.
+func orderKeys(keys, order []string) []string {
+	ordered := make([]string, 0, len(keys))
+	placed := make(map[string]bool, len(keys))
+	present := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		present[k] = true
+	}
+	for _, k := range order {
+		for _, fk := range []string{k, k + "[]"} {
+			if present[fk] && !placed[fk] {
+				ordered = append(ordered, fk)
+				placed[fk] = true
+			}
+		}
+	}
+	for _, k := range keys {
+		if !placed[k] {
+			ordered = append(ordered, k)
+		}
+	}
+	return ordered
+}
+
+// encodeOrdered encodes form into URL encoded form like [url.Values.Encode],
+// but with keys in the given order rather than sorted.
+func encodeOrdered(form url.Values, keys []string) string {
+	var buf strings.Builder
+	for _, k := range keys {
+		keyEscaped := url.QueryEscape(k)
+		for _, v := range form[k] {
+			if buf.Len() > 0 {
+				buf.WriteByte('&')
+			}
+			buf.WriteString(keyEscaped)
+			buf.WriteByte('=')
+			buf.WriteString(url.QueryEscape(v))
+		}
+	}
+	return buf.String()
+}
+
+// marshalSeparated encodes the elements of the slice or array f into a single value joined by the field's separator.
+// Nothing is written for an empty slice.
+func (e *Encoder) marshalSeparated(key string, f reflect.Value, fld field, form url.Values) *MarshalTypeError {
+	if e.decimalComma && fld.sep == "," && isFloat(elemType(f.Type())) {
+		return &MarshalTypeError{
+			Type:  f.Type(),
+			Value: marshalValue(f),
+			Err:   fmt.Errorf("sep cannot join with commas when DecimalComma is set"),
+		}
+	}
+	elems := make(url.Values)
+	err := e.marshalFormValues(key, f, fld, elems)
+	if err != nil {
+		return err
+	}
+	if len(elems[key]) > 0 {
+		form.Add(key, strings.Join(elems[key], fld.sep))
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package form
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// ErrUnknownEnum is wrapped by the [UnmarshalTypeError] returned when a value is not one of the
+// names registered with [RegisterEnum] for the field's type.
+var ErrUnknownEnum = errors.New("unknown enum value")
+
+// RegisterEnum registers the names of the values of an enum type t, such as a named int, with [RegisterType],
+// so a field of type t unmarshals from the name of a value and marshals as it:
+//
+//	form.RegisterEnum(reflect.TypeOf(Red), map[string]interface{}{"red": Red, "green": Green})
+//
+// A name missing from values returns a [UnmarshalTypeError] wrapping [ErrUnknownEnum], or sets the field to
+// its zero value with [Decoder.UnknownEnumZero]. RegisterEnum panics if a value is not of type t.
+func RegisterEnum(t reflect.Type, values map[string]interface{}) {
+	byName := make(map[string]reflect.Value, len(values))
+	byValue := make(map[interface{}]string, len(values))
+	for name, v := range values {
+		rv := reflect.ValueOf(v)
+		if !rv.IsValid() || rv.Type() != t {
+			panic(fmt.Sprintf("form: RegisterEnum(%s) value %q is %T", t, name, v))
+		}
+		byName[name] = rv
+		byValue[v] = name
+	}
+
+	RegisterType(t,
+		func(value string) (interface{}, error) {
+			v, ok := byName[value]
+			if !ok {
+				return nil, fmt.Errorf("%w %q", ErrUnknownEnum, value)
+			}
+			return v.Interface(), nil
+		},
+		func(v interface{}) (string, error) {
+			name, ok := byValue[v]
+			if !ok {
+				return "", fmt.Errorf("%w %v", ErrUnknownEnum, v)
+			}
+			return name, nil
+		},
+	)
+}
+
+// UnknownEnumZero makes the Decoder set a field of a type registered with [RegisterEnum] to its zero value
+// when the form value is not one of the registered names, as for an optional dropdown whose unexpected
+// value should not fail the whole request. By default an unknown name returns a [UnmarshalTypeError].
+func (d *Decoder) UnknownEnumZero() *Decoder {
+	d.unknownEnumZero = true
+	return d
+}
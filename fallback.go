@@ -0,0 +1,29 @@
+package form
+
+import "strings"
+
+// FallbackTag makes the Decoder bind fields that have no "form" struct tag using the key in the struct tag
+// with the given name instead, such as "json" for structs already tagged for JSON responses:
+//
+//	type Person struct {
+//		Name  string `json:"name"`
+//		Email string `json:"email,omitempty" form:"contact"`
+//	}
+//
+// The key is the tag's value up to the first comma, and the rest of the tag is ignored, so options such as
+// omitempty have no effect. Fields whose fallback tag is "-" or has an empty key are not bound.
+// The key of a "form" tag always wins. A "form" tag without a key, such as `form:",required"`,
+// takes its key from the fallback tag, so options can still be given alongside it.
+func (d *Decoder) FallbackTag(name string) *Decoder {
+	d.fallbackTag = name
+	return d
+}
+
+// fallbackKey returns the key of f in the struct tag name, or "" if it has none or it is "-".
+func (f field) fallbackKey(name string) string {
+	key, _, _ := strings.Cut(f.tag.Get(name), ",")
+	if key == "-" {
+		return ""
+	}
+	return key
+}
@@ -0,0 +1,612 @@
+package form
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A field is a struct field along with its parsed "form" struct tag.
+type field struct {
+	name    string            // name of the Go struct field
+	index   int               // index of the field in its struct
+	parent  []int             // index path of the nested struct holding the field, empty for a top-level field
+	key     string            // form key the field is bound to
+	tag     reflect.StructTag // struct tag of the field, for the fallback tag of a Decoder
+	sep     string            // separator splitting a single value into slice elements
+	unit    time.Duration     // unit of integer time.Duration values, zero for Go duration strings
+	layouts []string          // layouts of time.Time values, tried in order when unmarshalling
+	format  byte              // strconv.FormatFloat format of float values, or zero for the default
+	prec    int               // strconv.FormatFloat precision of float values, or -1 for the default
+	count   int               // index of the field receiving the number of values bound, or -1
+
+	encoding   string   // hex, base64 or json encoding of an array or slice as a single value
+	errs       bool     // field receives the errors of the other fields instead of form values
+	rawBody    bool     // field receives the raw request body instead of form values
+	raw        string   // keys of the whole form the field receives, "all" or "unbound", or ""
+	request    string   // request attribute the field receives instead of form values, or ""
+	requestArg string   // segment index or path value name of the segment and pathvalue options
+	in         string   // source the field is read from, "body" or "query", or "" for the whole form
+	sources    []string // sources tried in order for the field, the first holding its key winning, or nil
+	opts       tagOptions
+	rules      rules
+
+	required   bool       // field must have a non-empty value
+	requiredIf *condition // condition under which the field must have a non-empty value, or nil
+	group      *condition // discriminator condition under which the field is bound at all, or nil
+	match      *condition // field whose value the field must equal, with an empty value, or nil
+}
+
+// structFields is the cached result of parsing the fields of a struct type.
+type structFields struct {
+	list []field
+	err  error
+}
+
+var fieldCache sync.Map // map[reflect.Type]structFields
+
+// cachedFields returns the fields of the struct type t, parsing and caching them on first use.
+// If a field has an invalid tag then a [InvalidTagError] is returned.
+// Goroutines using t for the first time at once may each parse it, but only one result is stored.
+// The returned fields are shared, so callers copy a field before changing it, as ranging over them does.
+func cachedFields(t reflect.Type) ([]field, error) {
+	if sf, ok := fieldCache.Load(t); ok {
+		return sf.(structFields).list, sf.(structFields).err
+	}
+	sf, _ := fieldCache.LoadOrStore(t, typeFields(t))
+	return sf.(structFields).list, sf.(structFields).err
+}
+
+func typeFields(t reflect.Type) structFields {
+	defaults, err := structDefaults(t)
+	if err != nil {
+		return structFields{err: err}
+	}
+
+	list := make([]field, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		if isDefaultsField(t.Field(i)) {
+			continue
+		}
+		f, err := newField(t, i)
+		if err == nil && len(defaults) > 0 && !nestedStruct(t.Field(i), f) {
+			f, err = withDefaults(t, i, defaults)
+		}
+		if err != nil {
+			err.Struct = t.Name()
+			err.Field = t.Field(i).Name
+			return structFields{err: err}
+		}
+		if nestedStruct(t.Field(i), f) {
+			nested, err := cachedFields(t.Field(i).Type)
+			if err != nil {
+				return structFields{err: err}
+			}
+			prefix, _ := f.opts.Get("prefix")
+			for _, n := range nested {
+				n.parent = append([]int{i}, n.parent...)
+				if n.key != "" {
+					n.key = prefix + n.key
+				}
+				if f.key != "" && n.key != "" {
+					n.key = f.key + "." + n.key
+				}
+				list = append(list, n)
+			}
+			continue
+		}
+		list = append(list, f)
+	}
+	return structFields{list: list}
+}
+
+// nestedStruct reports whether the struct field sf, parsed as f, is a nested struct whose own
+// tagged fields are bound in place of the field itself. That is a struct valued field, either
+// embedded without a key or with a key or prefix option and no other options, that has tagged fields of its own and
+// is not parsed as a single value as time.Time, the database/sql Null types, [Unmarshaler] and
+// [encoding.TextUnmarshaler] implementations are.
+func nestedStruct(sf reflect.StructField, f field) bool {
+	_, prefixed := f.opts.Get("prefix")
+	if len(f.opts) > 1 || (len(f.opts) == 1 && !prefixed) {
+		return false
+	}
+	if (f.key == "" && !sf.Anonymous && !prefixed) || (!sf.IsExported() && !sf.Anonymous) {
+		return false
+	}
+	return hasTaggedFields(sf.Type)
+}
+
+// hasTaggedFields reports whether t is a struct with tagged fields of its own that is not parsed
+// as a single value as time.Time, the database/sql Null types, [Unmarshaler] and
+// [encoding.TextUnmarshaler] implementations are.
+func hasTaggedFields(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct || t == timeType || isNullType(t) ||
+		reflect.PointerTo(t).Implements(unmarshalerType) || reflect.PointerTo(t).Implements(scannerType) ||
+		implementsText(t) {
+		return false
+	}
+	for i := 0; i < t.NumField(); i++ {
+		if _, ok := t.Field(i).Tag.Lookup("form"); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// parentOf returns the struct holding the field f within the outer struct s,
+// following the index path of f to jump directly to it.
+func (f field) parentOf(s reflect.Value) reflect.Value {
+	if len(f.parent) == 0 {
+		return s
+	}
+	return s.FieldByIndex(f.parent)
+}
+
+// newField parses the "form" struct tag of the i'th field of the struct type t.
+func newField(t reflect.Type, i int) (field, *InvalidTagError) {
+	key, opts := parseTag(t.Field(i).Tag.Get("form"))
+	return parseField(t, i, key, opts)
+}
+
+// parseField parses the i'th field of the struct type t with the key and options of its "form" struct tag.
+func parseField(t reflect.Type, i int, key string, opts tagOptions) (field, *InvalidTagError) {
+	sf := t.Field(i)
+	f := field{
+		name:  sf.Name,
+		index: i,
+		key:   key,
+		tag:   sf.Tag,
+		opts:  opts,
+		count: -1,
+		prec:  -1,
+	}
+
+	if sep, ok := opts.Get("sep"); ok {
+		if sf.Type.Kind() != reflect.Slice && sf.Type.Kind() != reflect.Array {
+			return f, &InvalidTagError{
+				Option: "sep",
+				Err:    inapplicable("slice and array", sf.Type),
+			}
+		}
+		f.sep = sep
+		if f.sep == "" {
+			f.sep = ","
+		}
+	}
+
+	if unit, ok := opts.Get("unit"); ok {
+		if elemType(sf.Type) != durationType {
+			return f, &InvalidTagError{
+				Option: "unit",
+				Err:    inapplicable("time.Duration", sf.Type),
+			}
+		}
+		f.unit, ok = durationUnits[unit]
+		if !ok {
+			return f, &InvalidTagError{
+				Option: "unit",
+				Err:    fmt.Errorf("unknown unit %q", unit),
+			}
+		}
+	}
+
+	if nullValueType(baseType(elemType(sf.Type))) == timeType {
+		f.layouts = []string{defaultLayout}
+	}
+	if layout, ok := opts.Get("layout"); ok {
+		if nullValueType(baseType(elemType(sf.Type))) != timeType {
+			return f, &InvalidTagError{
+				Option: "layout",
+				Err:    inapplicable("time.Time", sf.Type),
+			}
+		}
+		f.layouts = strings.Split(layout, "|")
+		for _, l := range f.layouts {
+			if l == "" {
+				return f, &InvalidTagError{
+					Option: "layout",
+					Err:    fmt.Errorf("layout cannot be empty"),
+				}
+			}
+		}
+	}
+
+	if name, ok := opts.Get("count"); ok {
+		if sf.Type.Kind() != reflect.Slice && sf.Type.Kind() != reflect.Array {
+			return f, &InvalidTagError{
+				Option: "count",
+				Err:    inapplicable("slice and array", sf.Type),
+			}
+		}
+		count, ok := t.FieldByName(name)
+		if !ok || len(count.Index) != 1 {
+			return f, &InvalidTagError{
+				Option: "count",
+				Err:    fmt.Errorf("struct has no field %q", name),
+			}
+		}
+		switch count.Type.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		default:
+			return f, &InvalidTagError{
+				Option: "count",
+				Err:    fmt.Errorf("field %s has type %s, not an integer type", name, count.Type),
+			}
+		}
+		f.count = count.Index[0]
+	}
+
+	for _, name := range []string{"hex", "base64"} {
+		if !opts.Has(name) {
+			continue
+		}
+		if !isBytes(sf.Type) {
+			return f, &InvalidTagError{
+				Option: name,
+				Err:    inapplicable("byte array and slice", sf.Type),
+			}
+		}
+		if f.encoding != "" {
+			return f, &InvalidTagError{
+				Option: name,
+				Err:    fmt.Errorf("option cannot be combined with %s", f.encoding),
+			}
+		}
+		f.encoding = name
+	}
+
+	if opts.Has("json") {
+		if sf.Type.Kind() != reflect.Slice && sf.Type.Kind() != reflect.Array {
+			return f, &InvalidTagError{
+				Option: "json",
+				Err:    inapplicable("slice and array", sf.Type),
+			}
+		}
+		if f.encoding != "" || f.sep != "" {
+			with := f.encoding
+			if with == "" {
+				with = "sep"
+			}
+			return f, &InvalidTagError{
+				Option: "json",
+				Err:    fmt.Errorf("option cannot be combined with %s", with),
+			}
+		}
+		f.encoding = "json"
+	}
+
+	if _, ok := opts.Get("default"); ok && sf.Type.Kind() == reflect.Map {
+		return f, &InvalidTagError{
+			Option: "default",
+			Err:    fmt.Errorf("option does not apply to map fields"),
+		}
+	}
+
+	if _, ok := opts.Get("default"); opts.Has("dropdefault") && !ok {
+		return f, &InvalidTagError{
+			Option: "dropdefault",
+			Err:    fmt.Errorf("option needs the default option"),
+		}
+	}
+
+	if opts.Has("last") && (sf.Type.Kind() == reflect.Slice || sf.Type.Kind() == reflect.Array) {
+		return f, &InvalidTagError{
+			Option: "last",
+			Err:    inapplicable("non-slice", sf.Type),
+		}
+	}
+
+	if opts.Has("concat") {
+		if baseType(sf.Type).Kind() != reflect.String {
+			return f, &InvalidTagError{
+				Option: "concat",
+				Err:    inapplicable("string", sf.Type),
+			}
+		}
+		if opts.Has("last") {
+			return f, &InvalidTagError{
+				Option: "concat",
+				Err:    fmt.Errorf("option cannot be combined with last"),
+			}
+		}
+	}
+
+	if opts.Has("flatten") && sf.Type.Kind() != reflect.Slice && sf.Type.Kind() != reflect.Array {
+		return f, &InvalidTagError{
+			Option: "flatten",
+			Err:    inapplicable("slice and array", sf.Type),
+		}
+	}
+
+	if opts.Has("numeric") {
+		if t := baseType(elemType(sf.Type)); t.Kind() != reflect.Bool {
+			return f, &InvalidTagError{
+				Option: "numeric",
+				Err:    inapplicable("bool", sf.Type),
+			}
+		}
+	}
+
+	if format, ok := opts.Get("format"); ok {
+		if !isFloat(baseType(elemType(sf.Type))) {
+			return f, &InvalidTagError{
+				Option: "format",
+				Err:    inapplicable("float", sf.Type),
+			}
+		}
+		if len(format) != 1 || !validFloatFormat(format[0]) {
+			return f, &InvalidTagError{
+				Option: "format",
+				Err:    fmt.Errorf("%q is not one of e, f, g or x", format),
+			}
+		}
+		f.format = format[0]
+	}
+
+	if prec, ok := opts.Get("prec"); ok {
+		if !isFloat(baseType(elemType(sf.Type))) {
+			return f, &InvalidTagError{
+				Option: "prec",
+				Err:    inapplicable("float", sf.Type),
+			}
+		}
+		n, err := strconv.Atoi(prec)
+		if err != nil || n < 0 {
+			return f, &InvalidTagError{
+				Option: "prec",
+				Err:    fmt.Errorf("%q is not a non-negative integer", prec),
+			}
+		}
+		f.prec = n
+	}
+
+	if opts.Has("bool") {
+		if t := baseType(elemType(sf.Type)); !isInteger(t) || t == durationType {
+			return f, &InvalidTagError{
+				Option: "bool",
+				Err:    inapplicable("integer", sf.Type),
+			}
+		}
+	}
+
+	if opts.Has("underscore") {
+		if t := baseType(elemType(sf.Type)); !isInteger(t) || t == durationType {
+			return f, &InvalidTagError{
+				Option: "underscore",
+				Err:    inapplicable("integer", sf.Type),
+			}
+		}
+	}
+
+	if opts.Has("color") {
+		if t := baseType(elemType(sf.Type)); !isInteger(t) || t == durationType {
+			return f, &InvalidTagError{
+				Option: "color",
+				Err:    inapplicable("integer", sf.Type),
+			}
+		}
+	}
+
+	if in, ok := opts.Get("in"); ok {
+		if in != sourceBody && in != sourceQuery {
+			return f, &InvalidTagError{
+				Option: "in",
+				Err:    fmt.Errorf("unknown source %q, expected body or query", in),
+			}
+		}
+		f.in = in
+	}
+
+	if chain, ok := opts.Get("source"); ok {
+		if f.in != "" {
+			return f, &InvalidTagError{
+				Option: "source",
+				Err:    fmt.Errorf("option cannot be combined with in"),
+			}
+		}
+		for _, src := range strings.Split(chain, "|") {
+			if src != sourceBody && src != sourceQuery && src != sourceHeader {
+				return f, &InvalidTagError{
+					Option: "source",
+					Err:    fmt.Errorf("unknown source %q, expected body, query or header", src),
+				}
+			}
+			f.sources = append(f.sources, src)
+		}
+	}
+
+	if prefix, ok := opts.Get("prefix"); ok {
+		if !hasTaggedFields(sf.Type) {
+			return f, &InvalidTagError{
+				Option: "prefix",
+				Err:    inapplicable("nested struct", sf.Type),
+			}
+		}
+		if prefix == "" {
+			return f, &InvalidTagError{
+				Option: "prefix",
+				Err:    fmt.Errorf("option needs a prefix"),
+			}
+		}
+		if len(opts) > 1 {
+			return f, &InvalidTagError{
+				Option: "prefix",
+				Err:    fmt.Errorf("option cannot be combined with other options"),
+			}
+		}
+	}
+
+	if opts.Has("rawbody") {
+		if sf.Type.Kind() != reflect.String && !(sf.Type.Kind() == reflect.Slice && sf.Type.Elem().Kind() == reflect.Uint8) {
+			return f, &InvalidTagError{
+				Option: "rawbody",
+				Err:    inapplicable("string and []byte", sf.Type),
+			}
+		}
+		f.rawBody = true
+	}
+
+	if opts.Has("raw") {
+		if sf.Type != valuesType {
+			return f, &InvalidTagError{
+				Option: "raw",
+				Err:    inapplicable("url.Values", sf.Type),
+			}
+		}
+		f.raw = "all"
+		if v, _ := opts.Get("raw"); v != "" {
+			if v != "unbound" {
+				return f, &InvalidTagError{
+					Option: "raw",
+					Err:    fmt.Errorf("unknown value %q, expected unbound", v),
+				}
+			}
+			f.raw = v
+		}
+	}
+
+	for _, name := range requestAttrs {
+		arg, ok := opts.Get(name)
+		if !ok {
+			continue
+		}
+		if name == "method" || name == "path" {
+			if sf.Type.Kind() != reflect.String {
+				return f, &InvalidTagError{
+					Option: name,
+					Err:    inapplicable("string", sf.Type),
+				}
+			}
+		} else if k := sf.Type.Kind(); k == reflect.Slice || k == reflect.Array || k == reflect.Map {
+			return f, &InvalidTagError{
+				Option: name,
+				Err:    inapplicable("single value", sf.Type),
+			}
+		}
+		if name == "segment" {
+			if n, err := strconv.Atoi(arg); err != nil || n < 0 {
+				return f, &InvalidTagError{
+					Option: name,
+					Err:    fmt.Errorf("%q is not a non-negative integer", arg),
+				}
+			}
+		}
+		if name == "pathvalue" && arg == "" {
+			return f, &InvalidTagError{
+				Option: name,
+				Err:    fmt.Errorf("option needs the name of a path value"),
+			}
+		}
+		if f.request != "" || f.rawBody {
+			other := f.request
+			if f.rawBody {
+				other = "rawbody"
+			}
+			return f, &InvalidTagError{
+				Option: name,
+				Err:    fmt.Errorf("option cannot be combined with %s", other),
+			}
+		}
+		f.request = name
+		f.requestArg = arg
+	}
+
+	if key, ok := opts.Get("match"); ok {
+		c, err := parseMatch(t, i, key)
+		if err != nil {
+			return f, err
+		}
+		f.match = c
+	}
+
+	f.required = opts.Has("required")
+	if cond, ok := opts.Get("requiredif"); ok {
+		c, err := parseCondition(t, i, "requiredif", cond)
+		if err != nil {
+			return f, err
+		}
+		f.requiredIf = c
+	}
+	if cond, ok := opts.Get("group"); ok {
+		c, err := parseCondition(t, i, "group", cond)
+		if err != nil {
+			return f, err
+		}
+		f.group = c
+	}
+
+	if opts.Has("errors") {
+		if sf.Type.Kind() != reflect.Map || sf.Type.Key().Kind() != reflect.String || sf.Type.Elem().Kind() != reflect.String {
+			return f, &InvalidTagError{
+				Option: "errors",
+				Err:    inapplicable("map[string]string", sf.Type),
+			}
+		}
+		f.errs = true
+	}
+
+	rs, err := parseRules(sf.Type, opts)
+	if err != nil {
+		return f, err
+	}
+	f.rules = rs
+	return f, nil
+}
+
+// repeated reports whether a field of type t is marshalled as one repeated key per element.
+func (f field) repeated(t reflect.Type) bool {
+	return (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) && f.sep == "" && f.encoding == ""
+}
+
+// elemType returns the element type of slice and array types, and t itself otherwise.
+func elemType(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		return t.Elem()
+	}
+	return t
+}
+
+// baseType returns t with any pointer indirections removed.
+func baseType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+// withMessage wraps err in a [MessageError] if the field's tag has a msg option.
+func (f field) withMessage(err error) error {
+	msg, ok := f.opts.Get("msg")
+	if !ok {
+		return err
+	}
+	return &MessageError{
+		Message: msg,
+		Err:     err,
+	}
+}
+
+// ValidateStruct checks the "form" struct tags of i, which must be a struct or a pointer to a struct.
+// Tags are otherwise checked the first time a type is passed to [Unmarshal] or [Marshal],
+// so calling ValidateStruct at start up surfaces malformed options, such as a pattern that does not compile,
+// before any request is handled.
+// If i is not a struct or a pointer to a struct then a [InvalidUnmarshalError] error is returned.
+// If a tag option is invalid then a [InvalidTagError] is returned.
+func ValidateStruct(i interface{}) error {
+	t := reflect.TypeOf(i)
+	if t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return &InvalidUnmarshalError{
+			Type: reflect.TypeOf(i),
+		}
+	}
+
+	_, err := cachedFields(t)
+	return err
+}
@@ -0,0 +1,59 @@
+package form
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// FloatFormat controls how float fields are marshalled.
+type FloatFormat int
+
+const (
+	// FloatDefault writes floats with six decimal places, as fmt's %f verb does, so 1.5 is written as 1.500000.
+	FloatDefault FloatFormat = iota
+	// FloatFixed writes floats without an exponent, with the fewest digits that parse back to the same value.
+	FloatFixed
+	// FloatExponent writes floats in scientific notation, such as 1.5e+00.
+	FloatExponent
+	// FloatShortest writes floats with an exponent only when it is large, such as 1.5 and 1e+21,
+	// with the fewest digits that parse back to the same value.
+	FloatShortest
+	// FloatHex writes floats in hexadecimal notation, such as 0x1.8p+00.
+	FloatHex
+)
+
+// floatFormats are the [strconv.FormatFloat] formats of each FloatFormat, also accepted by the format tag option.
+var floatFormats = map[FloatFormat]byte{
+	FloatFixed:    'f',
+	FloatExponent: 'e',
+	FloatShortest: 'g',
+	FloatHex:      'x',
+}
+
+// FloatFormatMode sets how every float field is marshalled, as the format tag option does for a single field.
+// Fields with the format or prec tag option keep their own format.
+// The default is [FloatDefault]. Use [FloatShortest] for floats that must round trip, as the default
+// rounds to six decimal places.
+func (e *Encoder) FloatFormatMode(m FloatFormat) *Encoder {
+	e.floatFormat = floatFormats[m]
+	return e
+}
+
+// validFloatFormat reports whether format is accepted by the format tag option.
+func validFloatFormat(format byte) bool {
+	for _, f := range floatFormats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// formatFloat formats v, a float with the given number of bits, with the [strconv.FormatFloat] format
+// and precision, where -1 is the fewest digits that round trip, or with six decimal places if format is zero.
+func formatFloat(v float64, bits int, format byte, prec int) string {
+	if format == 0 {
+		return fmt.Sprintf("%f", v)
+	}
+	return strconv.FormatFloat(v, format, prec, bits)
+}
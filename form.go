@@ -4,92 +4,204 @@
 // All primative types including their slice and array equivalent are supported.
 // Those include bool, string, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64,
 // float32, float64, complex64, complex128.
+// Fields of type [time.Duration] are formatted and parsed as Go duration strings such as "1h30m".
+// Fields of type [time.Time] are formatted and parsed with [time.RFC3339], or the layout option.
+// The layout option may list several layouts separated by "|", which are tried in order when unmarshalling,
+// while marshalling uses the first:
+//
+//	Date time.Time `form:"date,layout=2006-01-02"`
+//	Day  time.Time `form:"day,layout:2006-01-02|2006/01/02|02-01-2006"`
+//
+// Nullable types of database/sql, such as [database/sql.NullString], [database/sql.NullInt64] and
+// [database/sql.Null], are unmarshalled into their value with Valid set when their key is present,
+// and left invalid when it is absent. When marshalled, only valid values are written.
+//
+// Float fields accept any input [strconv.ParseFloat] does, including scientific notation such as 1e3 and
+// hexadecimal such as 0x1p-2. They are marshalled with six decimal places unless the format option or
+// [Encoder.FloatFormatMode] chooses a [strconv.FormatFloat] format; format=g round trips every value.
+//
+// Pointer fields, such as *int or *time.Time, are left nil when their key is absent and otherwise
+// allocated and unmarshalled like the type they point to, so they model optional values:
+//
+//	Due *time.Time `form:"due,layout=2006-01-02"`
+//
+// Fields whose pointer implements [Unmarshaler], including through methods promoted from embedded fields,
+// unmarshal themselves from the values of their key.
+//
+// Map fields with string keys are unmarshalled from keys in bracket notation. A field tagged `form:"meta"`
+// of type map[string]string binds meta[color]=red, and nested maps bind one level per pair of brackets,
+// so config[db][host]=x binds a map[string]map[string]string. Keys whose brackets do not match the
+// map's levels return a [UnmarshalTypeError]. See [Decoder.MaxMapDepth] for the limit on nesting.
+// Maps of slices accumulate repeated keys, so groups[a]=1&groups[a]=2 binds {"a": [1, 2]}.
+// Map fields are marshalled in the same notation, visiting keys in sorted order. With [NestingDot] passed to
+// [Decoder.NestingMode] and [Encoder.NestingMode], levels are separated by dots instead, as in meta.color=red.
+//
+// A field of type [url.Values] binds a sub-form. Tagged `form:"extra"`, it collects the values of every key
+// written as extra.name or extra[name] under name, keeping anything after the first pair of brackets, so
+// extra[a][b] is stored under a[b]. It is marshalled back in bracket notation, so extra[name] round trips.
+//
+// Options may follow the key in the tag, separated by commas:
+//
+//	Username string `form:"username,minlen=3,maxlen=20"`
+//
+// Only the first "=" or ":" separates an option's name from its value, so a value may contain either.
+// A comma inside a value is escaped with a backslash, which is doubled inside the quoted struct tag:
+//
+//	Name string `form:"name,required,msg=Please enter your name\\, first and last"`
+//
+// The default option gives the value unmarshalled when the form has no value, or only empty values,
+// for a field. It is split, parsed and validated like a value from the form, before required is checked:
+//
+//	Tags []string `form:"tags,sep=;,default=a;b"`
+//
+// The required option returns a [MissingFieldError] if the form has no value for a field, or only empty values
+// as sent for a blank HTML input. The requiredif option requires a field only when another field was bound
+// to a given value, compared as formatted by [fmt.Sprint]:
+//
+//	Payment string `form:"payment"`
+//	Card    string `form:"card,requiredif=payment=card"`
+//
+// Fields are unmarshalled in declaration order, so the field a condition refers to by its form key
+// must be declared before the field with the requiredif option.
+//
+// The minlen and maxlen options validate the length of a string field when it is unmarshalled.
+// Lengths are counted in runes, not bytes, so multi-byte UTF-8 characters count once.
+// The pattern option validates that a string field matches a regular expression:
+//
+//	Slug string `form:"slug,pattern=^[a-z0-9-]+$"`
+//
+// Tags are parsed, and patterns compiled, once per struct type.
+// Use [ValidateStruct] to check the tags of a struct before it is first unmarshalled.
+//
+// The msg option attaches a human readable message to a field. If the field fails to unmarshal
+// then the error is wrapped in a [MessageError] carrying that message:
+//
+//	Email string `form:"email,minlen=3,msg=Please enter your email"`
+//
+// The errors option marks a map[string]string field that collects the message of each field that fails to
+// unmarshal, keyed by the field's form key, for rendering next to the inputs of an HTML form:
+//
+//	Errors map[string]string `form:",errors"`
+//
+// By default unmarshalling stops at the first error, so the map holds that error alone. With
+// [Decoder.SkipErrors] every failing field is recorded and the others are still bound.
+// The map is reset at the start of every unmarshal and left nil if no field failed.
+// It is never read from or written to the form.
+//
+// The rawbody option stores the whole request body in a string or []byte field, for example to check the
+// signature of a webhook, while the other fields are still unmarshalled from the form:
+//
+//	Raw []byte `form:",rawbody"`
+//
+// The body is read into memory in full, so every request costs a copy of its body, and a body larger
+// than 10MB returns a [ParseError]. The request's body is replaced with a reader over the copy, both to
+// parse the form and afterwards, so handlers can read it again.
+//
+// The method and path options store the request's method and URL path in a string field, for logging or
+// auditing alongside the form data. Like rawbody they are options rather than keys, so they never collide
+// with form keys of the same name, and the fields are skipped when marshalling:
+//
+//	Method string `form:",method"`
+//	Path   string `form:",path"`
+//
+// Slice and array fields bind repeated keys in the order they appear in the request.
+// They also bind PHP style keys with a trailing "[]", so ids[]=1&ids[]=2 unmarshals into a field
+// tagged `form:"ids"`, after any values of the key without brackets. Use [Encoder.BracketSlices] to marshal keys in this style.
+// They also bind indexed keys such as items[2]=c&items[0]=a, which place each value at its index
+// regardless of the order the keys were sent in. Slices grow to fit the largest index and arrays
+// return a [UnmarshalTypeError] for indices beyond their length. Gaps between indices are left at
+// their zero value unless [Decoder.SparseIndexMode] is set to [SparseError].
+//
+// The sep option splits each value of a slice or array field around a separator before the elements are parsed,
+// and joins the elements into a single value when marshalled. Without a value, sep splits around commas:
+//
+//	Flags []bool   `form:"flags,sep"`   // flags=true,false,true
+//	Tags  []string `form:"tags,sep=;"`  // tags=a;b;c
+//
+// Empty elements at the end of a value are dropped, so a,b, splits into two elements, unless
+// [Decoder.KeepTrailingEmpty] is set. Leading and consecutive separators always produce empty elements.
+//
+// The unit option represents a [time.Duration] field as an integer number of a unit,
+// one of ns, us, ms, s, m or h, rather than a Go duration string.
+// Marshalled durations are truncated towards zero to a whole number of the unit:
+//
+//	TTL time.Duration `form:"ttl,unit=s"` // ttl=5400
+//
+// The hex and base64 options encode a byte array or slice field as a single hexadecimal or
+// standard base64 value, rather than one key per byte. Arrays must decode to exactly their length:
+//
+//	Hash [32]byte `form:"hash,hex"`
+//
+// The count option names an integer field of the same struct that receives the number of values
+// bound to a slice or array field. Combined with [Decoder.ArrayLengthMode] and [ArrayPad] it tells
+// a real zero element apart from padding. The count field should not have a "form" tag of its own:
+//
+//	Vals  [4]int `form:"vals,count=NVals"`
+//	NVals int
+//
+// Unmarshal, Marshal and the methods of a Decoder or Encoder are safe to call from many goroutines at once,
+// including on different values of the same struct type. The fields of each struct type are parsed once
+// and cached in a [sync.Map], and the cached fields are never modified afterwards; all other state,
+// such as a [Report] or the map given to [Decoder.UnmarshalRecord], lives in the call and is never kept
+// by the Decoder. Options must still be set before a Decoder or Encoder is shared.
 package form
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
 	"reflect"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // Unmarshal parses the [*http.Request] form and populates the struct fields with the "form" struct tag in i.
 // If i is not a pointer to a struct then a [InvalidUnmarshalError] error is returned.
 // If a form value cannot be parsed into the struct field, either mismatched type or value overflows type, then a [UnmarshalTypeError] is returned.
+// If the request's form cannot be parsed then a [ParseError] is returned.
+// If a field has a malformed "form" struct tag then a [InvalidTagError] is returned.
+// If a parsed value does not satisfy a validation option of the field's tag then a [ValidationError] is returned.
+// Errors for fields with a msg tag option are wrapped in a [MessageError].
 func Unmarshal(r *http.Request, i interface{}) error {
-	rv := reflect.ValueOf(i)
-	if rv.Kind() != reflect.Pointer || rv.IsNil() {
-		return &InvalidUnmarshalError{
-			Type: reflect.TypeOf(i),
-		}
-
-	}
+	return defaultDecoder.Unmarshal(r, i)
+}
 
-	s := rv.Elem()
-	if s.Kind() != reflect.Struct {
-		return &InvalidUnmarshalError{
-			Type: reflect.TypeOf(i),
-		}
-	}
+// UnmarshalValues populates the struct fields with the "form" struct tag in i from values, such as
+// r.URL.Query() or r.PostForm, like [Unmarshal] does from a request's form.
+// It is [Decoder.DecodeFrom] with the default options; use a [Decoder] for validators and other options.
+func UnmarshalValues(values url.Values, i interface{}) error {
+	return defaultDecoder.DecodeFrom(values, i)
+}
 
-	err := r.ParseForm()
+// Decode allocates a T, unmarshals the [*http.Request] form into it like [Unmarshal], and returns it:
+//
+//	p, err := form.Decode[Person](r)
+//
+// T must be a struct type. If unmarshalling fails then the zero value of T is returned along with the error.
+func Decode[T any](r *http.Request) (T, error) {
+	var v T
+	err := Unmarshal(r, &v)
 	if err != nil {
-		return err
-	}
-
-	for i := 0; i < s.NumField(); i++ {
-		f := s.Type().Field(i)
-		tag := f.Tag.Get("form")
-		values := r.Form[tag]
-		err := parseFormValues(s.Field(i), values)
-		if err != nil {
-			err.Struct = s.Type().Name()
-			err.Field = f.Name
-			return err
-		}
+		var zero T
+		return zero, err
 	}
-
-	return nil
+	return v, nil
 }
 
 // Marshal encodes the fields with the "form" struct tag into a URL encoded form on the request.
 // Marshal does not set the Content-Type header for the request.
 // If i is not a pointer to a struct then a [InvalidMarshalError] error is returned.
 // If a field in the struct does not match the supported primative types, then a [MarshalTypeError] error is returned.
+// Pointer and interface fields are marshalled as the value they hold. Nil pointers, interfaces, maps and slices
+// have nothing to marshal and are skipped, unless [Encoder.EmitEmpty] is set.
+// Fields with the omitempty option are skipped when they are empty: false, 0, a nil pointer or interface,
+// an empty string, slice, array or map, or a zero struct such as the zero [time.Time].
+// If a field has a malformed "form" struct tag then a [InvalidTagError] is returned.
 func Marshal(r *http.Request, i interface{}) error {
-	rv := reflect.ValueOf(i)
-	if rv.Kind() != reflect.Pointer || rv.IsNil() {
-		return &InvalidMarshalError{
-			Type: reflect.TypeOf(i),
-		}
-	}
-
-	s := rv.Elem()
-	if s.Kind() != reflect.Struct {
-		return &InvalidMarshalError{
-			Type: reflect.TypeOf(i),
-		}
-	}
-
-	form := make(url.Values)
-	for i := 0; i < s.NumField(); i++ {
-		f := s.Type().Field(i)
-		tag := f.Tag.Get("form")
-		if tag == "" {
-			continue
-		}
-		err := marshalFormValues(tag, s.Field(i), form)
-		if err != nil {
-			err.Struct = s.Type().Name()
-			err.Field = f.Name
-			return err
-		}
-	}
-
-	r.URL.RawQuery = form.Encode()
-	return nil
+	return defaultEncoder.Marshal(r, i)
 }
 
 // A InvalidUnmarshalError describes a invalid value passed to [Unmarshal]
@@ -125,12 +237,15 @@ func (e *InvalidMarshalError) Error() string {
 }
 
 // A UnmarshalTypeError describes a value that is
-// invalid for a specific Go type.
+// invalid for a specific Go type. For an instantiated
+// generic struct, Struct includes the type arguments,
+// such as "pair[string,int]".
 type UnmarshalTypeError struct {
 	Value  string       // value from form being decoded
 	Type   reflect.Type // type of Go value it could not be assigned to
 	Struct string       // name of struct
 	Field  string       // name of field that could not be unmarshalled
+	Key    string       // form key of the field
 	Err    error        // wrapped error either from parsing value, or value overflow Go type
 }
 
@@ -150,21 +265,187 @@ type MarshalTypeError struct {
 	Value  interface{}  // value trying to be marshalled
 	Struct string       // name of struct
 	Field  string       // name of field that could not be marshalled
+	Err    error        // error from a registered EncodeFunc, if any
 }
 
 func (e *MarshalTypeError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("form: cannot marshal %v (%s) of Go struct field %s.%s into form data: %s", e.Value, e.Type, e.Struct, e.Field, e.Err)
+	}
 	return fmt.Sprintf("form: cannot marshal %v (%s) of Go struct field %s.%s into form data", e.Value, e.Type, e.Struct, e.Field)
 }
 
-func parseFormValues(f reflect.Value, values []string) *UnmarshalTypeError {
+func (e *MarshalTypeError) Unwrap() error {
+	return e.Err
+}
+
+// errUnexported is the error of a [MarshalTypeError] for a value that must be read through its interface,
+// such as a time.Time or a value with a registered encoder, but was obtained from an unexported field.
+var errUnexported = errors.New("cannot read the value of an unexported field")
+
+// unexportedError returns a [MarshalTypeError] for f, whose value is needed to marshal it but was obtained
+// from an unexported field.
+func unexportedError(f reflect.Value) *MarshalTypeError {
+	return &MarshalTypeError{
+		Type: f.Type(),
+		Err:  errUnexported,
+	}
+}
+
+// marshalValue returns the value of f for a [MarshalTypeError], or nil if f was obtained from an unexported field.
+func marshalValue(f reflect.Value) interface{} {
+	if !f.CanInterface() {
+		return nil
+	}
+	return f.Interface()
+}
+
+// MarshalErrors is returned by an [Encoder] with [Encoder.CollectErrors] set,
+// holding the error of every field that could not be marshalled, in struct order.
+type MarshalErrors struct {
+	Errors []*MarshalTypeError
+}
+
+func (e *MarshalErrors) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Unwrap returns the errors of each field, so [errors.As] finds the first [MarshalTypeError].
+func (e *MarshalErrors) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, err := range e.Errors {
+		errs[i] = err
+	}
+	return errs
+}
+
+// A ValidationError describes a form value that was unmarshalled
+// but does not satisfy a validation option of its "form" struct tag.
+type ValidationError struct {
+	Value  string // value from form being validated
+	Option string // tag option the value does not satisfy
+	Struct string // name of struct
+	Field  string // name of field that failed validation
+	Key    string // form key of the field
+	Err    error  // description of the violation
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("form: invalid value %s for Go struct field %s.%s: %s",
+		e.Value, e.Struct, e.Field, e.Err)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// A MissingFieldError describes a field with the required or requiredif option
+// that has no value, or only empty values, in the form.
+type MissingFieldError struct {
+	Option    string // tag option that requires the field, required or requiredif
+	Condition string // condition of the requiredif option that holds, such as payment=card
+	Struct    string // name of struct
+	Field     string // name of field that is missing
+	Key       string // form key of the field
+}
+
+func (e *MissingFieldError) Error() string {
+	if e.Condition != "" {
+		return fmt.Sprintf("form: missing value for Go struct field %s.%s, required when %s", e.Struct, e.Field, e.Condition)
+	}
+	return fmt.Sprintf("form: missing value for required Go struct field %s.%s", e.Struct, e.Field)
+}
+
+// A InvalidTagError describes a "form" struct tag option
+// that is malformed or does not apply to the field's type.
+type InvalidTagError struct {
+	Option string // tag option that is invalid
+	Struct string // name of struct
+	Field  string // name of field with the invalid tag
+	Err    error  // reason the option is invalid
+}
+
+func (e *InvalidTagError) Error() string {
+	return fmt.Sprintf("form: invalid option %s in tag of Go struct field %s.%s: %s",
+		e.Option, e.Struct, e.Field, e.Err)
+}
+
+func (e *InvalidTagError) Unwrap() error {
+	return e.Err
+}
+
+// ErrBodyConsumed is wrapped in a [ParseError] when a request declares a URL encoded body
+// but none of it could be read, which usually means middleware read r.Body before the form was unmarshalled.
+// Restore the body, for example with [io.NopCloser] over the bytes read, or call [http.Request.ParseForm]
+// before reading it.
+var ErrBodyConsumed = errors.New("request body was already read before the form was parsed, possibly by middleware")
+
+// A ParseError describes a failure to parse the request's form before it is unmarshalled.
+type ParseError struct {
+	Err error // error from parsing the form, or ErrBodyConsumed
+}
+
+func (e *ParseError) Error() string {
+	return "form: cannot parse request form: " + e.Err.Error()
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// A MessageError carries the human readable message from the msg option of a field's "form" struct tag,
+// such as `form:"email,msg=Please enter your email"`, for an error unmarshalling that field.
+// Error returns only the message, the underlying [UnmarshalTypeError] or [ValidationError]
+// is available by unwrapping.
+type MessageError struct {
+	Message string // message from the msg tag option
+	Err     error  // error unmarshalling the field
+}
+
+func (e *MessageError) Error() string {
+	return e.Message
+}
+
+func (e *MessageError) Unwrap() error {
+	return e.Err
+}
+
+func (d *Decoder) parseFormValues(f reflect.Value, fld field, values []string) *UnmarshalTypeError {
 	if len(values) == 0 || !f.IsValid() || !f.CanSet() {
 		return nil
 	}
 
-	if f.Kind() == reflect.Slice {
+	if f.Kind() == reflect.Pointer && d.decodeFunc(f.Type()) == nil {
+		p := reflect.New(f.Type().Elem())
+		if err := d.parseFormValues(p.Elem(), fld, values); err != nil {
+			err.Type = f.Type()
+			return err
+		}
+		f.Set(p)
+		return nil
+	}
+
+	if d.decodeFunc(f.Type()) == nil {
+		if u, ok := unmarshaler(f); ok {
+			return unmarshalForm(u, f, values)
+		}
+	}
+
+	if fld.encoding == "json" {
+		return parseJSONArray(f, values)
+	}
+	if fld.encoding != "" {
+		return parseEncodedBytes(f, fld, values)
+	}
+
+	if f.Kind() == reflect.Slice && d.decodeFunc(f.Type()) == nil && !implementsText(f.Type()) {
 		s := reflect.MakeSlice(f.Type(), len(values), len(values))
 		for i, val := range values {
-			err := parseFormValue(s.Index(i), val)
+			err := d.parseFormValue(s.Index(i), fld, val)
 			if err != nil {
 				err.Value = "[" + strings.Join(values, ", ") + "]"
 				err.Type = f.Type()
@@ -175,18 +456,17 @@ func parseFormValues(f reflect.Value, values []string) *UnmarshalTypeError {
 		return nil
 	}
 
-	if f.Kind() == reflect.Array {
-		if f.Len() != len(values) {
+	if f.Kind() == reflect.Array && d.decodeFunc(f.Type()) == nil && !implementsText(f.Type()) {
+		if f.Len() < len(values) || (f.Len() > len(values) && d.arrayLength != ArrayPad) {
 			return &UnmarshalTypeError{
 				Value: "[" + strings.Join(values, ", ") + "]",
 				Type:  f.Type(),
 				Err:   fmt.Errorf("cannot use [%d]%s as %s value in struct", len(values), f.Type().Elem(), f.Type()),
 			}
 		}
-		arr := reflect.ArrayOf(len(values), f.Type().Elem())
-		s := reflect.New(arr).Elem()
+		s := reflect.New(f.Type()).Elem()
 		for i, val := range values {
-			err := parseFormValue(s.Index(i), val)
+			err := d.parseFormValue(s.Index(i), fld, val)
 			if err != nil {
 				err.Value = "[" + strings.Join(values, ", ") + "]"
 				err.Type = f.Type()
@@ -197,6 +477,10 @@ func parseFormValues(f reflect.Value, values []string) *UnmarshalTypeError {
 		return nil
 	}
 
+	if sep, ok := fld.opts.Get("concat"); ok && len(values) > 1 {
+		values = []string{strings.Join(values, sep)}
+	}
+	values = d.singleValue(fld, values)
 	if len(values) != 1 {
 		return &UnmarshalTypeError{
 			Value: "[" + strings.Join(values, ", ") + "]",
@@ -205,19 +489,98 @@ func parseFormValues(f reflect.Value, values []string) *UnmarshalTypeError {
 		}
 	}
 
-	err := parseFormValue(f, values[0])
+	err := d.parseFormValue(f, fld, values[0])
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func parseFormValue(f reflect.Value, value string) *UnmarshalTypeError {
+func (d *Decoder) parseFormValue(f reflect.Value, fld field, value string) *UnmarshalTypeError {
+	if dec := d.decodeFunc(f.Type()); dec != nil {
+		err := decodeRegistered(f, dec, value)
+		if err != nil && d.unknownEnumZero && errors.Is(err.Err, ErrUnknownEnum) {
+			f.SetZero()
+			return nil
+		}
+		return err
+	}
+
+	if f.Kind() == reflect.Pointer {
+		p := reflect.New(f.Type().Elem())
+		if err := d.parseFormValue(p.Elem(), fld, value); err != nil {
+			err.Type = f.Type()
+			return err
+		}
+		f.Set(p)
+		return nil
+	}
+
+	if u, ok := unmarshaler(f); ok {
+		return unmarshalForm(u, f, []string{value})
+	}
+
+	if d.useScanner && f.CanAddr() {
+		if scanner, ok := f.Addr().Interface().(sql.Scanner); ok {
+			err := scanner.Scan(value)
+			if err != nil {
+				return &UnmarshalTypeError{
+					Value: value,
+					Type:  f.Type(),
+					Err:   err,
+				}
+			}
+			return nil
+		}
+	}
+
+	if isNullType(f.Type()) {
+		return d.parseNull(f, fld, value)
+	}
+
+	if f.Type() == durationType {
+		v, err := parseDuration(value, fld.unit)
+		if err != nil {
+			return &UnmarshalTypeError{
+				Value: value,
+				Type:  f.Type(),
+				Err:   err,
+			}
+		}
+		f.SetInt(int64(v))
+		return nil
+	}
+
+	if f.Type() == timeType {
+		v, err := parseTime(value, fld.layouts)
+		if err != nil {
+			return &UnmarshalTypeError{
+				Value: value,
+				Type:  f.Type(),
+				Err:   err,
+			}
+		}
+		f.Set(reflect.ValueOf(v))
+		return nil
+	}
+
+	if u, ok := textUnmarshaler(f); ok {
+		return unmarshalText(u, f, value)
+	}
+
+	if f.Kind() == reflect.Interface {
+		return d.parseInterface(f, fld, value)
+	}
+
 	switch f.Kind() {
 	case reflect.String:
 		f.SetString(value)
 		return nil
 	case reflect.Bool:
+		if b, ok := d.boolTokens.match(value); ok {
+			f.SetBool(b)
+			return nil
+		}
 		v, err := strconv.ParseBool(value)
 		if err != nil {
 			return &UnmarshalTypeError{
@@ -229,69 +592,129 @@ func parseFormValue(f reflect.Value, value string) *UnmarshalTypeError {
 		f.SetBool(v)
 		return nil
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		v, err := strconv.ParseInt(value, 10, 64)
-		if err != nil {
+		if fld.opts.Has("color") {
+			v, err := parseColor(value)
+			if err == nil && f.OverflowInt(int64(v)) {
+				err = fmt.Errorf("%s overflows %s value", value, f.Type())
+			}
+			if err != nil {
+				return &UnmarshalTypeError{
+					Value: value,
+					Type:  f.Type(),
+					Err:   err,
+				}
+			}
+			f.SetInt(int64(v))
+			return nil
+		}
+		if fld.opts.Has("bool") {
+			if b, ok := d.parseBoolToken(value); ok {
+				f.SetInt(boolInt(b))
+				return nil
+			}
+		}
+		v, err := parseInt(d.numberPart(value), d.underscores || fld.opts.Has("underscore"))
+		if err != nil && !d.clampRange(err) {
 			return &UnmarshalTypeError{
 				Value: value,
 				Type:  f.Type(),
 				Err:   err,
 			}
 		}
-		if f.OverflowInt(v) {
-			return &UnmarshalTypeError{
-				Value: value,
-				Type:  f.Type(),
-				Err:   fmt.Errorf("%s overflows %s value", value, f.Type()),
+		if f.OverflowInt(v) || err != nil {
+			if d.overflow != OverflowClamp {
+				return &UnmarshalTypeError{
+					Value: value,
+					Type:  f.Type(),
+					Err:   fmt.Errorf("%s overflows %s value", value, f.Type()),
+				}
 			}
+			v = clampInt(v, f.Type().Bits())
 		}
 		f.SetInt(v)
 		return nil
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		v, err := strconv.ParseUint(value, 10, 64)
-		if err != nil {
+		if fld.opts.Has("color") {
+			v, err := parseColor(value)
+			if err == nil && f.OverflowUint(v) {
+				err = fmt.Errorf("%s overflows %s value", value, f.Type())
+			}
+			if err != nil {
+				return &UnmarshalTypeError{
+					Value: value,
+					Type:  f.Type(),
+					Err:   err,
+				}
+			}
+			f.SetUint(v)
+			return nil
+		}
+		if fld.opts.Has("bool") {
+			if b, ok := d.parseBoolToken(value); ok {
+				f.SetUint(uint64(boolInt(b)))
+				return nil
+			}
+		}
+		number, underscores := d.numberPart(value), d.underscores || fld.opts.Has("underscore")
+		v, err := parseUint(number, underscores)
+		if err != nil && d.clampNegative(number, underscores) {
+			f.SetUint(0)
+			return nil
+		}
+		if err != nil && !d.clampRange(err) {
 			return &UnmarshalTypeError{
 				Value: value,
 				Type:  f.Type(),
 				Err:   err,
 			}
 		}
-		if f.OverflowUint(v) {
-			return &UnmarshalTypeError{
-				Value: value,
-				Type:  f.Type(),
-				Err:   fmt.Errorf("%s overflows %s value", value, f.Type()),
+		if f.OverflowUint(v) || err != nil {
+			if d.overflow != OverflowClamp {
+				return &UnmarshalTypeError{
+					Value: value,
+					Type:  f.Type(),
+					Err:   fmt.Errorf("%s overflows %s value", value, f.Type()),
+				}
 			}
+			v = clampUint(f.Type().Bits())
 		}
 		f.SetUint(v)
 		return nil
 	case reflect.Float32, reflect.Float64:
-		v, err := strconv.ParseFloat(value, 64)
-		if err != nil {
+		number := d.numberPart(value)
+		if d.decimalComma {
+			number = fromDecimalComma(number)
+		}
+		v, err := strconv.ParseFloat(number, 64)
+		if err != nil && !d.clampRange(err) {
 			return &UnmarshalTypeError{
 				Value: value,
 				Type:  f.Type(),
 				Err:   err,
 			}
 		}
-		if f.OverflowFloat(v) {
-			return &UnmarshalTypeError{
-				Value: value,
-				Type:  f.Type(),
-				Err:   fmt.Errorf("%s overflows %s value", value, f.Type()),
+		if f.OverflowFloat(v) || err != nil {
+			if d.overflow != OverflowClamp {
+				return &UnmarshalTypeError{
+					Value: value,
+					Type:  f.Type(),
+					Err:   fmt.Errorf("%s overflows %s value", value, f.Type()),
+				}
 			}
+			v = clampFloat(v, f.Type().Bits())
 		}
 		f.SetFloat(v)
 		return nil
 	case reflect.Complex64, reflect.Complex128:
-		v, err := strconv.ParseComplex(value, 128)
-		if err != nil {
+		v, err := strconv.ParseComplex(value, f.Type().Bits())
+		if err != nil && !errors.Is(err, strconv.ErrRange) {
 			return &UnmarshalTypeError{
 				Value: value,
 				Type:  f.Type(),
 				Err:   err,
 			}
 		}
-		if f.OverflowComplex(v) {
+		if err != nil {
 			return &UnmarshalTypeError{
 				Value: value,
 				Type:  f.Type(),
@@ -309,10 +732,40 @@ func parseFormValue(f reflect.Value, value string) *UnmarshalTypeError {
 	}
 }
 
-func marshalFormValues(tag string, f reflect.Value, form url.Values) *MarshalTypeError {
-	if f.Kind() == reflect.Slice || f.Kind() == reflect.Array {
+func (e *Encoder) marshalFormValues(tag string, f reflect.Value, fld field, form url.Values) *MarshalTypeError {
+	switch f.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if f.IsNil() {
+			return nil
+		}
+		return e.marshalFormValues(tag, f.Elem(), fld, form)
+	case reflect.Map:
+		if f.IsNil() {
+			return nil
+		}
+	}
+
+	if fld.encoding == "json" {
+		if f.Kind() != reflect.Slice || !f.IsNil() {
+			v, err := formatJSONArray(f)
+			if err != nil {
+				return err
+			}
+			form.Add(tag, v)
+		}
+		return nil
+	}
+
+	if fld.encoding != "" {
+		if f.Kind() != reflect.Slice || !f.IsNil() {
+			form.Add(tag, formatEncodedBytes(f, fld))
+		}
+		return nil
+	}
+
+	if (f.Kind() == reflect.Slice || f.Kind() == reflect.Array) && e.encodeFunc(f.Type()) == nil {
 		for i := 0; i < f.Len(); i++ {
-			err := marshalFormValue(tag, f.Index(i), form)
+			err := e.marshalFormValue(tag, f.Index(i), fld, form)
 			if err != nil {
 				err.Type = f.Type()
 				err.Field = f.Type().Name()
@@ -321,33 +774,103 @@ func marshalFormValues(tag string, f reflect.Value, form url.Values) *MarshalTyp
 		}
 		return nil
 	}
-	return marshalFormValue(tag, f, form)
+	return e.marshalFormValue(tag, f, fld, form)
 }
 
-func marshalFormValue(tag string, f reflect.Value, form url.Values) *MarshalTypeError {
+func (e *Encoder) marshalFormValue(tag string, f reflect.Value, fld field, form url.Values) *MarshalTypeError {
+	if enc := e.encodeFunc(f.Type()); enc != nil {
+		if !f.CanInterface() {
+			return unexportedError(f)
+		}
+		v, err := enc(f.Interface())
+		if err != nil {
+			return &MarshalTypeError{
+				Type:  f.Type(),
+				Value: marshalValue(f),
+				Err:   err,
+			}
+		}
+		form.Add(tag, v)
+		return nil
+	}
+
+	if f.Kind() == reflect.Pointer || f.Kind() == reflect.Interface {
+		if f.IsNil() {
+			return nil
+		}
+		return e.marshalFormValue(tag, f.Elem(), fld, form)
+	}
+
+	if isNullType(f.Type()) {
+		if !f.Field(1).Bool() {
+			return nil
+		}
+		return e.marshalFormValue(tag, f.Field(0), fld, form)
+	}
+
+	if f.Type() == durationType {
+		form.Add(tag, formatDuration(time.Duration(f.Int()), fld.unit))
+		return nil
+	}
+
+	if f.Type() == timeType {
+		if !f.CanInterface() {
+			return unexportedError(f)
+		}
+		form.Add(tag, f.Interface().(time.Time).Format(fld.layouts[0]))
+		return nil
+	}
+
 	switch f.Kind() {
 	case reflect.String:
 		form.Add(tag, f.String())
 		return nil
 	case reflect.Bool:
+		if e.numericBools || fld.opts.Has("numeric") {
+			form.Add(tag, formatNumericBool(f.Bool()))
+			return nil
+		}
 		form.Add(tag, fmt.Sprintf("%t", f.Bool()))
 		return nil
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if fld.opts.Has("color") {
+			if f.Int() < 0 {
+				return &MarshalTypeError{
+					Type:  f.Type(),
+					Value: marshalValue(f),
+					Err:   fmt.Errorf("negative value is not a color"),
+				}
+			}
+			return addColor(tag, f, uint64(f.Int()), form)
+		}
 		form.Add(tag, fmt.Sprintf("%d", f.Int()))
 		return nil
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if fld.opts.Has("color") {
+			return addColor(tag, f, f.Uint(), form)
+		}
 		form.Add(tag, fmt.Sprintf("%d", f.Uint()))
 		return nil
 	case reflect.Float32, reflect.Float64:
-		form.Add(tag, fmt.Sprintf("%f", f.Float()))
+		format := e.floatFormat
+		if fld.format != 0 {
+			format = fld.format
+		} else if fld.prec >= 0 {
+			format = 'f'
+		}
+		v := formatFloat(f.Float(), f.Type().Bits(), format, fld.prec)
+		if e.decimalComma {
+			v = toDecimalComma(v)
+		}
+		form.Add(tag, v)
 		return nil
 	case reflect.Complex64, reflect.Complex128:
-		form.Add(tag, fmt.Sprintf("%e", f.Complex()))
+		form.Add(tag, strconv.FormatComplex(f.Complex(), 'g', -1, f.Type().Bits()))
 		return nil
 	default:
 		return &MarshalTypeError{
 			Type:  f.Type(),
-			Value: f.Interface(),
+			Value: marshalValue(f),
 		}
 	}
 }
@@ -0,0 +1,132 @@
+package form
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// SparseIndex controls how gaps between the indices of indexed keys, such as items[0]=a&items[2]=c, are unmarshalled.
+type SparseIndex int
+
+const (
+	// SparseFill leaves the elements at missing indices at their zero value. The slice is as long as the
+	// largest index plus one, up to the limit of [Decoder.MaxSliceLen] or [SetMaxSliceLen], which is
+	// [DefaultMaxSliceLen] unless changed.
+	SparseFill SparseIndex = iota
+	// SparseError returns a [UnmarshalTypeError] if any index below the largest index is missing.
+	SparseError
+)
+
+// SparseIndexMode sets how gaps between the indices of indexed keys are unmarshalled. The default is [SparseFill].
+func (d *Decoder) SparseIndexMode(m SparseIndex) *Decoder {
+	d.sparseIndex = m
+	return d
+}
+
+// indexedKeys returns the keys of the form in bracket notation that index the slice or array field f of type t,
+// such as items[0] for a field tagged `form:"items"`. Keys with an empty index, such as items[], are not included.
+func (ds *decodeState) indexedKeys(f field, t reflect.Type) []string {
+	if f.key == "" || !f.repeated(t) {
+		return nil
+	}
+
+	keys := ds.nestedKeys(f.key)
+	if len(keys) == 0 || (len(keys) == 1 && keys[0] == f.key+"[]") {
+		return nil
+	}
+	indexed := make([]string, 0, len(keys))
+	for _, k := range keys {
+		if k != f.key+"[]" {
+			indexed = append(indexed, k)
+		}
+	}
+	return indexed
+}
+
+// parseIndexed unmarshals indexed keys such as items[2]=c&items[0]=a into the slice or array field f,
+// placing each value at its index regardless of the order the keys were sent in.
+// Slices grow to fit the largest index, while indices beyond the length of an array are an error.
+// It returns the number of values that were bound.
+func (d *Decoder) parseIndexed(f reflect.Value, fld field, keys []string, values []string, ds *decodeState) (int, *UnmarshalTypeError) {
+	if len(values) > 0 {
+		return 0, &UnmarshalTypeError{
+			Value: strings.Join(keys, ", "),
+			Type:  f.Type(),
+			Err:   fmt.Errorf("cannot mix indexed keys with unindexed values for %s", fld.key),
+		}
+	}
+
+	indices := make([]int, len(keys))
+	length := 0
+	for i, k := range keys {
+		index, err := parseIndex(k[len(fld.key):])
+		if err != nil {
+			return 0, &UnmarshalTypeError{
+				Value: k,
+				Type:  f.Type(),
+				Err:   err,
+			}
+		}
+		if f.Kind() == reflect.Array && index >= f.Len() {
+			return 0, &UnmarshalTypeError{
+				Value: k,
+				Type:  f.Type(),
+				Err:   fmt.Errorf("index %d out of range for %s", index, f.Type()),
+			}
+		}
+		if len(ds.form[k]) != 1 {
+			return 0, &UnmarshalTypeError{
+				Value: k + "=[" + strings.Join(ds.form[k], ", ") + "]",
+				Type:  f.Type(),
+				Err:   fmt.Errorf("cannot unmarshal more than one value for index %d", index),
+			}
+		}
+		if err := d.checkSliceLen(f.Type(), k, index+1); err != nil {
+			return 0, err
+		}
+		indices[i] = index
+		length = max(length, index+1)
+	}
+
+	if d.sparseIndex == SparseError && length != len(keys) {
+		return 0, &UnmarshalTypeError{
+			Value: strings.Join(keys, ", "),
+			Type:  f.Type(),
+			Err:   fmt.Errorf("indices are not contiguous from 0 to %d", length-1),
+		}
+	}
+
+	var s reflect.Value
+	if f.Kind() == reflect.Slice {
+		s = reflect.MakeSlice(f.Type(), length, length)
+	} else {
+		s = reflect.New(f.Type()).Elem()
+	}
+	for i, k := range keys {
+		value := ds.form[k][0]
+		err := d.parseFormValue(s.Index(indices[i]), fld, value)
+		if err != nil {
+			err.Value = k + "=" + value
+			err.Type = f.Type()
+			return 0, err
+		}
+	}
+	f.Set(s)
+	return len(keys), nil
+}
+
+// parseIndex parses a single bracketed non-negative index such as "[2]".
+// The largest int is rejected so that the length index+1 cannot overflow.
+func parseIndex(s string) (int, error) {
+	if len(s) < 2 || s[0] != '[' || s[len(s)-1] != ']' || strings.ContainsAny(s[1:len(s)-1], "[]") {
+		return 0, fmt.Errorf("key is not a single bracketed index")
+	}
+	index, err := strconv.Atoi(s[1 : len(s)-1])
+	if err != nil || index < 0 || index == math.MaxInt {
+		return 0, fmt.Errorf("invalid index %q", s[1:len(s)-1])
+	}
+	return index, nil
+}
@@ -0,0 +1,155 @@
+package form
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// maxBodySize is the largest body read in full for a JSON body or a rawbody field,
+// matching the limit [http.Request.ParseForm] places on URL encoded bodies.
+const maxBodySize = 10 << 20
+
+// AllowJSON makes the Decoder also accept requests with a Content-Type of application/json.
+// The body must be a JSON object, which is converted into form values keyed by the object's names
+// and then unmarshalled exactly like a form, so the "form" struct tags, tag options and validators all apply:
+//
+//   - strings, numbers and booleans become a single value, with numbers written as sent
+//   - arrays become one value per element, like a repeated key
+//   - objects become keys in bracket notation, so {"meta": {"color": "red"}} binds meta[color]=red
+//   - null leaves the key absent
+//
+// Values in the URL query are added after the values of the body, as for a URL encoded body, and fields
+// read only from the body with in=body see the values of the JSON object alone.
+// Bodies larger than 10MB, or that are not a JSON object, return a [ParseError].
+// The body is read into memory and replaced with a reader over the bytes read, so it can be read again.
+// Requests with any other Content-Type are unmarshalled as forms.
+func (d *Decoder) AllowJSON() *Decoder {
+	d.allowJSON = true
+	return d
+}
+
+// isJSON reports whether r has a JSON body.
+func isJSON(r *http.Request) bool {
+	ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return ct == "application/json"
+}
+
+// parseJSON reads the JSON object in the body of r into form values, followed by the values of the URL query.
+// It also returns the values of the body alone, without those of the query, for fields read only from the body.
+func parseJSON(r *http.Request) (form, body url.Values, err error) {
+	body = make(url.Values)
+	if r.Body != nil && r.Body != http.NoBody {
+		data, err := readBody(r.Body)
+		r.Body.Close()
+		if err != nil {
+			return nil, nil, &ParseError{Err: err}
+		}
+		r.Body = io.NopCloser(bytes.NewReader(data))
+
+		var obj map[string]interface{}
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		err = dec.Decode(&obj)
+		if err != nil {
+			return nil, nil, &ParseError{Err: err}
+		}
+		for k, v := range obj {
+			err := addJSONValue(body, k, v, true)
+			if err != nil {
+				return nil, nil, &ParseError{Err: err}
+			}
+		}
+	}
+
+	query, err := url.ParseQuery(r.URL.RawQuery)
+	if err != nil {
+		return nil, nil, &ParseError{Err: err}
+	}
+	form = make(url.Values, len(body)+len(query))
+	for k, vs := range body {
+		form[k] = append([]string(nil), vs...)
+	}
+	for k, vs := range query {
+		form[k] = append(form[k], vs...)
+	}
+	return form, body, nil
+}
+
+// addJSONValue adds the decoded JSON value v to form under key. Arrays add one value per element
+// and are only allowed if array is true, so arrays of arrays are rejected.
+func addJSONValue(form url.Values, key string, v interface{}, array bool) error {
+	switch v := v.(type) {
+	case string:
+		form.Add(key, v)
+	case json.Number:
+		form.Add(key, v.String())
+	case bool:
+		form.Add(key, strconv.FormatBool(v))
+	case []interface{}:
+		if !array {
+			return fmt.Errorf("JSON value of %s is an array nested in an array", key)
+		}
+		for _, elem := range v {
+			if err := addJSONValue(form, key, elem, false); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		for k, elem := range v {
+			if err := addJSONValue(form, key+"["+k+"]", elem, true); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// parseJSONArray decodes a single value holding a JSON array into the slice or array f,
+// for fields with the json option. Arrays take at most their length of elements, as with [json.Unmarshal].
+func parseJSONArray(f reflect.Value, values []string) *UnmarshalTypeError {
+	if len(values) == 0 {
+		return nil
+	}
+	if len(values) != 1 {
+		return &UnmarshalTypeError{
+			Value: "[" + strings.Join(values, ", ") + "]",
+			Type:  f.Type(),
+			Err:   fmt.Errorf("cannot unmarshal more than one value for json encoded field"),
+		}
+	}
+
+	v := reflect.New(f.Type())
+	if err := json.Unmarshal([]byte(values[0]), v.Interface()); err != nil {
+		return &UnmarshalTypeError{
+			Value: values[0],
+			Type:  f.Type(),
+			Err:   err,
+		}
+	}
+	f.Set(v.Elem())
+	return nil
+}
+
+// formatJSONArray encodes the slice or array f as a single JSON array, for fields with the json option.
+func formatJSONArray(f reflect.Value) (string, *MarshalTypeError) {
+	if !f.CanInterface() {
+		return "", unexportedError(f)
+	}
+	b, err := json.Marshal(f.Interface())
+	if err != nil {
+		return "", &MarshalTypeError{
+			Type:  f.Type(),
+			Value: marshalValue(f),
+			Err:   err,
+		}
+	}
+	return string(b), nil
+}
@@ -0,0 +1,32 @@
+package form
+
+import "strings"
+
+// LenientNumbers makes the Decoder ignore a unit suffix after the number of integer and float fields,
+// so 30px unmarshals as 30 and 100% as 100. A suffix is a run of ASCII letters and percent signs at the end
+// of the value, optionally preceded by spaces, as in "12 kg", and is only removed when a number ends before it.
+// Values without a leading number, such as px, still fail to unmarshal, while Inf and NaN are kept for floats.
+// This is lossy, since the unit is discarded without being checked, so it is off by default.
+// Duration fields are not affected.
+func (d *Decoder) LenientNumbers() *Decoder {
+	d.lenientNumbers = true
+	return d
+}
+
+// numberPart returns value without its unit suffix if LenientNumbers is set and a digit or decimal point,
+// which is a comma with DecimalComma, comes before the suffix, and value unchanged otherwise.
+func (d *Decoder) numberPart(value string) string {
+	if !d.lenientNumbers {
+		return value
+	}
+	number := strings.TrimRight(value, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ%")
+	number = strings.TrimRight(number, " ")
+	last := byte(0)
+	if number != "" {
+		last = number[len(number)-1]
+	}
+	if !isDigit(last) && last != '.' && !(last == ',' && d.decimalComma) {
+		return value
+	}
+	return number
+}
@@ -0,0 +1,78 @@
+package form
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync/atomic"
+)
+
+// DefaultMaxSliceLen is the maximum number of values a slice field accepts until another limit
+// is set with [SetMaxSliceLen] or [Decoder.MaxSliceLen]. It keeps a single indexed key such as
+// items[99999999999]=x from allocating a slice large enough to exhaust memory.
+const DefaultMaxSliceLen = 10000
+
+// maxSliceLen is the limit set with SetMaxSliceLen, or 0 for no limit.
+var maxSliceLen = func() *atomic.Int64 {
+	var n atomic.Int64
+	n.Store(DefaultMaxSliceLen)
+	return &n
+}()
+
+// SetMaxSliceLen sets the maximum number of values a slice field accepts for every Decoder
+// that has not set its own limit with [Decoder.MaxSliceLen], including the package level [Unmarshal] and [Bind].
+// The default is [DefaultMaxSliceLen]; before it was added slices had no limit. A value of 0 or less removes the limit, which lets a client
+// allocate as many elements as the largest index it sends.
+// It is safe to call concurrently with unmarshalling, and is meant to be called once when a program starts.
+func SetMaxSliceLen(n int) {
+	maxSliceLen.Store(int64(max(n, 0)))
+}
+
+// MaxSliceLen sets the maximum number of values a slice field accepts, protecting against
+// clients that send enormous numbers of repeated keys, separated elements or indices to force large allocations.
+// The limit applies to every source of values: repeated keys, keys with a trailing "[]", elements split by the sep option,
+// the largest index of indexed keys plus one, and the values of each element of a map of slices.
+// Exceeding it returns a [UnmarshalTypeError] before anything is allocated for the field.
+// A value of 0 or less removes the limit. Decoders without a limit of their own use the one set with [SetMaxSliceLen],
+// which is [DefaultMaxSliceLen] unless changed.
+func (d *Decoder) MaxSliceLen(n int) *Decoder {
+	d.maxSliceLen = max(n, 0)
+	d.hasMaxSliceLen = true
+	return d
+}
+
+// sliceLimit returns the maximum number of values a slice field accepts, or 0 for no limit.
+func (d *Decoder) sliceLimit() int {
+	if d.hasMaxSliceLen {
+		return d.maxSliceLen
+	}
+	return int(maxSliceLen.Load())
+}
+
+// checkSliceLen returns a [UnmarshalTypeError] if n values for the field of type t bound to key exceed the slice limit.
+// Arrays, and maps of arrays, are never checked: their length is fixed by the type, so values beyond it are
+// rejected as out of range and nothing is allocated for them.
+func (d *Decoder) checkSliceLen(t reflect.Type, key string, n int) *UnmarshalTypeError {
+	elem := t
+	if elem.Kind() == reflect.Map {
+		elem = elem.Elem()
+	}
+	limit := d.sliceLimit()
+	if elem.Kind() == reflect.Array || limit == 0 || n <= limit {
+		return nil
+	}
+	return &UnmarshalTypeError{
+		Value: key,
+		Type:  t,
+		Err:   fmt.Errorf("%d values exceed the maximum of %d", n, limit),
+	}
+}
+
+// splitLen returns the most elements splitValues can return for values and sep.
+func splitLen(values []string, sep string) int {
+	n := 0
+	for _, value := range values {
+		n += strings.Count(value, sep) + 1
+	}
+	return n
+}
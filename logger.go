@@ -0,0 +1,39 @@
+package form
+
+import (
+	"log/slog"
+	"reflect"
+	"strings"
+)
+
+// Logger makes the Decoder log diagnostic warnings to logger while binding, to catch mistakes in struct
+// definitions during development without failing requests: a field with a "form" tag that is unexported,
+// and so can never be set, and an exported field without a key whose name, or its lowercase form, is a key
+// of the form, which suggests a missing tag. Binding is unchanged. Nothing is logged by default.
+func (d *Decoder) Logger(logger *slog.Logger) *Decoder {
+	d.logger = logger
+	return d
+}
+
+// warnField logs a warning if the field f of the struct s looks like a mistake in the struct's definition.
+func (d *Decoder) warnField(s reflect.Value, f field, ds *decodeState) {
+	sf := s.Type().Field(f.index)
+	if sf.Anonymous {
+		return
+	}
+	if !sf.IsExported() && f.key != "" {
+		d.logger.Warn("form: tagged field is unexported and cannot be set",
+			"struct", s.Type().Name(), "field", f.name, "key", f.key)
+		return
+	}
+	if _, tagged := sf.Tag.Lookup("form"); tagged || f.key != "" || !sf.IsExported() {
+		return
+	}
+	for _, key := range []string{f.name, strings.ToLower(f.name)} {
+		if ds.form.Has(key) {
+			d.logger.Warn("form: field has no key but the form has a value for its name",
+				"struct", s.Type().Name(), "field", f.name, "key", key)
+			return
+		}
+	}
+}
@@ -0,0 +1,309 @@
+package form
+
+import (
+	"encoding"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// parseMap unmarshals the form keys nested under key with bracket notation into the map field f.
+// Each level of brackets indexes one level of nested maps, so config[db][host]=x sets
+// f["db"]["host"] for a field of type map[string]map[string]string.
+// Keys without brackets are ignored, and f is left unchanged if no nested keys are present.
+//
+// When the map's values are slices or arrays, repeated occurrences of the same key accumulate
+// into the value, and a trailing empty bracket is accepted, so groups[a]=1&groups[a][]=2 sets
+// f["a"] to [1 2].
+func (d *Decoder) parseMap(f reflect.Value, fld field, key string, ds *decodeState) *UnmarshalTypeError {
+	depth := mapDepth(f.Type())
+	if depth > d.maxMapDepth {
+		return &UnmarshalTypeError{
+			Value: key,
+			Type:  f.Type(),
+			Err:   fmt.Errorf("%s has %d levels of nested maps, more than the maximum of %d", f.Type(), depth, d.maxMapDepth),
+		}
+	}
+
+	keys := ds.mapKeys(key, d.nesting)
+	if len(keys) == 0 {
+		return nil
+	}
+
+	elemType := f.Type()
+	for i := 0; i < depth; i++ {
+		elemType = elemType.Elem()
+	}
+	repeated := fld.repeated(elemType) && d.decodeFunc(elemType) == nil
+
+	entries := make([]*mapEntry, 0, len(keys))
+	byPath := make(map[string]*mapEntry, len(keys))
+	for _, k := range keys {
+		path, err := d.nesting.parsePath(k[len(key):])
+		if err != nil {
+			return &UnmarshalTypeError{
+				Value: k,
+				Type:  f.Type(),
+				Err:   err,
+			}
+		}
+		if repeated && len(path) == depth+1 && path[depth] == "" {
+			path = path[:depth]
+		}
+		if len(path) != depth {
+			return &UnmarshalTypeError{
+				Value: k,
+				Type:  f.Type(),
+				Err:   fmt.Errorf("key has %d levels of %s but %s needs %d", len(path), d.nesting.name(), f.Type(), depth),
+			}
+		}
+
+		id := fmt.Sprintf("%q", path)
+		if e, ok := byPath[id]; ok {
+			e.values = append(e.values, ds.form[k]...)
+			if err := d.checkSliceLen(f.Type(), e.key, len(e.values)); err != nil {
+				return err
+			}
+			continue
+		}
+		if repeated {
+			if err := d.checkSliceLen(f.Type(), k, len(ds.form[k])); err != nil {
+				return err
+			}
+		}
+		e := &mapEntry{key: k, path: path, values: ds.form[k]}
+		byPath[id] = e
+		entries = append(entries, e)
+	}
+
+	m := reflect.MakeMapWithSize(f.Type(), len(entries))
+	for _, e := range entries {
+		inner := m
+		for _, seg := range e.path[:depth-1] {
+			mk, err := d.mapKey(seg, inner.Type().Key())
+			if err != nil {
+				err.Value = e.key
+				err.Type = f.Type()
+				return err
+			}
+			next := inner.MapIndex(mk)
+			if !next.IsValid() {
+				next = reflect.MakeMap(inner.Type().Elem())
+				inner.SetMapIndex(mk, next)
+			}
+			inner = next
+		}
+
+		elem := reflect.New(elemType).Elem()
+		var parseErr *UnmarshalTypeError
+		if repeated && elemType.Kind() == reflect.Slice {
+			parseErr = d.parseMapSlice(elem, fld, e)
+		} else if parseErr = d.parseFormValues(elem, fld, e.values); parseErr != nil {
+			parseErr.Value = e.key + "=" + parseErr.Value
+		}
+		if parseErr != nil {
+			parseErr.Type = f.Type()
+			return parseErr
+		}
+		mk, err := d.mapKey(e.path[depth-1], inner.Type().Key())
+		if err != nil {
+			err.Value = e.key
+			err.Type = f.Type()
+			return err
+		}
+		inner.SetMapIndex(mk, elem)
+	}
+	f.Set(m)
+	return nil
+}
+
+// A mapEntry holds the values of every form key that sets the same map element,
+// such as groups[a] and groups[a][] for a map of slices.
+type mapEntry struct {
+	key    string
+	path   []string
+	values []string
+}
+
+// parseMapSlice parses each value of e into a new element of the slice f,
+// reporting the key and value of the first element that fails to parse.
+func (d *Decoder) parseMapSlice(f reflect.Value, fld field, e *mapEntry) *UnmarshalTypeError {
+	s := reflect.MakeSlice(f.Type(), len(e.values), len(e.values))
+	for i, val := range e.values {
+		if err := d.parseFormValue(s.Index(i), fld, val); err != nil {
+			err.Value = e.key + "=" + val
+			return err
+		}
+	}
+	f.Set(s)
+	return nil
+}
+
+// mapKey returns the segment seg of a form key as a key of type t, through its
+// [encoding.TextUnmarshaler] if it implements one, by parsing it as a form value for integer keys,
+// or by converting the string otherwise.
+func (d *Decoder) mapKey(seg string, t reflect.Type) (reflect.Value, *UnmarshalTypeError) {
+	if implementsText(t) {
+		k := reflect.New(t)
+		if err := k.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(seg)); err != nil {
+			return k, &UnmarshalTypeError{Err: fmt.Errorf("invalid map key %q: %w", seg, err)}
+		}
+		return k.Elem(), nil
+	}
+	if isInteger(t) {
+		k := reflect.New(t).Elem()
+		if err := d.parseFormValue(k, field{}, seg); err != nil {
+			return k, &UnmarshalTypeError{Err: fmt.Errorf("invalid map key %q: %w", seg, err.Err)}
+		}
+		return k, nil
+	}
+	return reflect.ValueOf(seg).Convert(t), nil
+}
+
+// mapKeyText returns the map key k as a segment of a form key, through its [encoding.TextMarshaler]
+// if it implements one.
+// The keys of a map obtained from an unexported field are formatted through reflection, so a key type
+// implementing [encoding.TextMarshaler] cannot be marshalled from one.
+func mapKeyText(k reflect.Value) (string, error) {
+	if k.Type().Implements(textMarshalerType) {
+		if !k.CanInterface() {
+			return "", errUnexported
+		}
+		b, err := k.Interface().(encoding.TextMarshaler).MarshalText()
+		return string(b), err
+	}
+	if k.Kind() == reflect.String {
+		return k.String(), nil
+	}
+	return fmt.Sprint(k), nil
+}
+
+// mapDepth returns the number of levels of nested maps in t whose keys are strings, integers
+// or implement [encoding.TextUnmarshaler].
+func mapDepth(t reflect.Type) int {
+	depth := 0
+	for t.Kind() == reflect.Map && (t.Key().Kind() == reflect.String || isInteger(t.Key()) || implementsText(t.Key())) {
+		depth++
+		t = t.Elem()
+	}
+	return depth
+}
+
+// groupNestedKeys groups the keys in form that contain the delimiter delim, an opening bracket or a dot,
+// by the key before its first occurrence. Each group is sorted.
+func groupNestedKeys(form url.Values, delim byte) map[string][]string {
+	groups := make(map[string][]string)
+	for k := range form {
+		i := strings.IndexByte(k, delim)
+		if i <= 0 {
+			continue
+		}
+		groups[k[:i]] = append(groups[k[:i]], k)
+	}
+	for _, keys := range groups {
+		sort.Strings(keys)
+	}
+	return groups
+}
+
+// parseKeyPath splits bracket notation such as "[db][host]" into its segments.
+// Within a segment a backslash escapes a literal bracket or backslash, so "[a\[1\]]"
+// is the single segment "a[1]". A backslash before any other character is kept.
+func parseKeyPath(s string) ([]string, error) {
+	var path []string
+	for s != "" {
+		if s[0] != '[' {
+			return nil, fmt.Errorf("unexpected %q outside of brackets", s)
+		}
+		var seg strings.Builder
+		i := 1
+		for ; i < len(s) && s[i] != ']'; i++ {
+			switch {
+			case s[i] == '[':
+				return nil, fmt.Errorf("mismatched brackets")
+			case s[i] == '\\' && i+1 < len(s) && strings.IndexByte(`[]\`, s[i+1]) >= 0:
+				i++
+			}
+			seg.WriteByte(s[i])
+		}
+		if i == len(s) {
+			return nil, fmt.Errorf("mismatched brackets")
+		}
+		path = append(path, seg.String())
+		s = s[i+1:]
+	}
+	return path, nil
+}
+
+// keyEscaper escapes the brackets and backslashes of a map key for bracket notation,
+// as read by [parseKeyPath].
+var keyEscaper = strings.NewReplacer(`\`, `\\`, `[`, `\[`, `]`, `\]`)
+
+// intLess reports whether the integer a is less than b, both of the same signed or unsigned type.
+func intLess(a, b reflect.Value) bool {
+	if a.CanInt() {
+		return a.Int() < b.Int()
+	}
+	return a.Uint() < b.Uint()
+}
+
+// marshalMap adds the elements of the map field m to form under key, in the notation of the Encoder's
+// [Nesting], visiting keys in sorted order so the output is deterministic, numerically for integer keys.
+// Nested maps add one level per map.
+// The new form keys are appended to keys.
+func (e *Encoder) marshalMap(key string, m reflect.Value, fld field, form url.Values, keys []string) ([]string, *MarshalTypeError) {
+	type sortedKey struct {
+		value reflect.Value
+		text  string
+	}
+	mapKeys := make([]sortedKey, 0, m.Len())
+	for _, mk := range m.MapKeys() {
+		text, err := mapKeyText(mk)
+		if err != nil {
+			return keys, &MarshalTypeError{
+				Type:  m.Type(),
+				Value: marshalValue(mk),
+				Err:   fmt.Errorf("invalid map key: %w", err),
+			}
+		}
+		mapKeys = append(mapKeys, sortedKey{mk, text})
+	}
+	switch {
+	case e.mapKeyLess != nil:
+		sort.Slice(mapKeys, func(i, j int) bool { return e.mapKeyLess(mapKeys[i].text, mapKeys[j].text) })
+	case isInteger(m.Type().Key()) && !implementsText(m.Type().Key()):
+		sort.Slice(mapKeys, func(i, j int) bool { return intLess(mapKeys[i].value, mapKeys[j].value) })
+	default:
+		sort.Slice(mapKeys, func(i, j int) bool { return mapKeys[i].text < mapKeys[j].text })
+	}
+	for _, mk := range mapKeys {
+		text := mk.text
+		if e.nesting == NestingBracket {
+			text = keyEscaper.Replace(text)
+		}
+		fk := e.nesting.nestedKey(key, text)
+		v := m.MapIndex(mk.value)
+		if v.Kind() == reflect.Map && mapDepth(v.Type()) > 0 && e.encodeFunc(v.Type()) == nil {
+			var err *MarshalTypeError
+			keys, err = e.marshalMap(fk, v, fld, form, keys)
+			if err != nil {
+				return keys, err
+			}
+			continue
+		}
+		if e.bracketSlices && e.nesting == NestingBracket && fld.repeated(v.Type()) && e.encodeFunc(v.Type()) == nil {
+			fk += "[]"
+		}
+
+		_, seen := form[fk]
+		if err := e.marshalFormValues(fk, v, fld, form); err != nil {
+			return keys, err
+		}
+		if !seen && form.Has(fk) {
+			keys = append(keys, fk)
+		}
+	}
+	return keys, nil
+}
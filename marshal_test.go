@@ -1,8 +1,19 @@
 package form_test
 
 import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime/multipart"
 	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/hunterwilkins2/form"
 )
@@ -33,16 +44,118 @@ func TestInvalidMarshalError(t *testing.T) {
 func TestMarshalTypeError(t *testing.T) {
 	t.Parallel()
 	type s struct {
-		M map[string]string `form:"map"`
+		M map[float64]string `form:"map"`
 	}
 
 	r, _ := http.NewRequest(http.MethodGet, "/", nil)
-	err := form.Marshal(r, &s{M: map[string]string{"test": "123"}})
+	err := form.Marshal(r, &s{M: map[float64]string{1: "123"}})
 	if err == nil {
 		t.Fatalf("expected error from Marshal")
 	}
-	if err.Error() != "form: cannot marshal map[test:123] (map[string]string) of Go struct field s.M into form data" {
-		t.Fatalf("wrong error message. want=%s, got=%s", "form: cannot marshal map[test: 123] (map[string]string) of Go struct field s.M into form data", err.Error())
+	if err.Error() != "form: cannot marshal map[1:123] (map[float64]string) of Go struct field s.M into form data" {
+		t.Fatalf("wrong error message. want=%s, got=%s", "form: cannot marshal map[1:123] (map[float64]string) of Go struct field s.M into form data", err.Error())
+	}
+}
+
+func TestMapMarshal(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Meta   map[string]string            `form:"meta"`
+		Config map[string]map[string]string `form:"config"`
+		Groups map[string][]int             `form:"groups"`
+		Extra  url.Values                   `form:"extra"`
+		Empty  map[string]string            `form:"empty"`
+	}
+	value := s{
+		Meta:   map[string]string{"size": "m", "color": "red"},
+		Config: map[string]map[string]string{"db": {"host": "x", "port": "5432"}},
+		Groups: map[string][]int{"a": {1, 2}},
+		Extra:  url.Values{"b[c]": {"d"}},
+		Empty:  map[string]string{},
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, "/", nil)
+	if err := form.NewEncoder().PreserveOrder().Marshal(r, &value); err != nil {
+		t.Fatalf("unexpected marshal error: %s", err)
+	}
+	expected := "meta%5Bcolor%5D=red&meta%5Bsize%5D=m&config%5Bdb%5D%5Bhost%5D=x&config%5Bdb%5D%5Bport%5D=5432&groups%5Ba%5D=1&groups%5Ba%5D=2&extra%5Bb%5D%5Bc%5D=d"
+	if r.URL.RawQuery != expected {
+		t.Fatalf("wrong bracket query. want=%s, got=%s", expected, r.URL.RawQuery)
+	}
+	var actual s
+	if err := form.Unmarshal(r, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	value.Empty = nil
+	if !reflect.DeepEqual(actual, value) {
+		t.Fatalf("maps did not round trip. want=%v, got=%v", value, actual)
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "/", nil)
+	if err := form.NewEncoder().PreserveOrder().NestingMode(form.NestingDot).EmitEmpty().Marshal(r, &value); err != nil {
+		t.Fatalf("unexpected marshal error: %s", err)
+	}
+	expected = "meta.color=red&meta.size=m&config.db.host=x&config.db.port=5432&groups.a=1&groups.a=2&extra.b%5Bc%5D=d&empty="
+	if r.URL.RawQuery != expected {
+		t.Fatalf("wrong dot query. want=%s, got=%s", expected, r.URL.RawQuery)
+	}
+	actual = s{}
+	if err := form.NewDecoder().NestingMode(form.NestingDot).Unmarshal(r, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	if !reflect.DeepEqual(actual.Config, value.Config) || !reflect.DeepEqual(actual.Groups, value.Groups) {
+		t.Fatalf("maps did not round trip with dots. want=%v, got=%v", value, actual)
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "/", nil)
+	if err := form.NewEncoder().BracketSlices().Marshal(r, &s{Groups: map[string][]int{"a": {1}}}); err != nil {
+		t.Fatalf("unexpected marshal error: %s", err)
+	}
+	if r.URL.RawQuery != "groups%5Ba%5D%5B%5D=1" {
+		t.Fatalf("wrong query with BracketSlices. got=%s", r.URL.RawQuery)
+	}
+}
+
+func TestEncoderCollectErrors(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Name  string             `form:"name"`
+		M     map[float64]string `form:"map"`
+		Ch    []chan int         `form:"ch"`
+		Count int                `form:"count"`
+	}
+	value := s{Name: "a", M: map[float64]string{1: "x"}, Ch: []chan int{nil}, Count: 1}
+
+	r, _ := http.NewRequest(http.MethodGet, "/?keep=1", nil)
+	err := form.NewEncoder().CollectErrors().Marshal(r, &value)
+	var errs *form.MarshalErrors
+	if !errors.As(err, &errs) || len(errs.Errors) != 2 {
+		t.Fatalf("expected two collected errors. got=%v", err)
+	}
+	if errs.Errors[0].Field != "M" || errs.Errors[1].Field != "Ch" {
+		t.Fatalf("wrong fields. got=%s and %s", errs.Errors[0].Field, errs.Errors[1].Field)
+	}
+	expected := "form: cannot marshal map[1:x] (map[float64]string) of Go struct field s.M into form data\n" +
+		"form: cannot marshal <nil> ([]chan int) of Go struct field s.Ch into form data"
+	if err.Error() != expected {
+		t.Fatalf("wrong error message. want=%s, got=%s", expected, err.Error())
+	}
+	var typeErr *form.MarshalTypeError
+	if !errors.As(err, &typeErr) || typeErr.Field != "M" {
+		t.Fatalf("expected errors.As to find the first MarshalTypeError. got=%v", typeErr)
+	}
+	if r.URL.RawQuery != "keep=1" {
+		t.Fatalf("expected request to be unchanged. got=%s", r.URL.RawQuery)
+	}
+
+	err = form.Marshal(r, &value)
+	if !errors.As(err, &typeErr) || errors.As(err, &errs) {
+		t.Fatalf("expected a single MarshalTypeError by default. got=%v", err)
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "/", nil)
+	if err := form.NewEncoder().CollectErrors().Marshal(r, &s{Name: "a"}); err != nil || r.URL.RawQuery != "count=0&name=a" {
+		t.Fatalf("expected no error without bad fields. got=%s, err=%v", r.URL.RawQuery, err)
 	}
 }
 
@@ -112,13 +225,194 @@ func TestFloatMarshal(t *testing.T) {
 	testMarshalForm(t, &s{A: 5.349}, "a=5.349000")
 }
 
+func TestFloatFormatMarshal(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Default float64   `form:"default"`
+		Short   float64   `form:"short,format=g"`
+		Exp     float32   `form:"exp,format=e"`
+		Hex     []float64 `form:"hex,format=x"`
+	}
+
+	value := s{Default: 1e21, Short: 1e21, Exp: 5.349, Hex: []float64{0.25}}
+	testMarshalForm(t, &value, "default=1000000000000000000000.000000&exp=5.349e%2B00&hex=0x1p-02&short=1e%2B21")
+
+	r, _ := http.NewRequest(http.MethodGet, "/", nil)
+	if err := form.Marshal(r, &value); err != nil {
+		t.Fatalf("unexpected marshal error: %s", err)
+	}
+	var actual s
+	if err := form.Unmarshal(r, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	if !reflect.DeepEqual(actual, value) {
+		t.Fatalf("floats did not round trip. want=%+v, got=%+v", value, actual)
+	}
+
+	type mode struct {
+		Small float64 `form:"small"`
+		Fixed float64 `form:"fixed,format=f"`
+	}
+	r, _ = http.NewRequest(http.MethodGet, "/", nil)
+	err := form.NewEncoder().FloatFormatMode(form.FloatShortest).Marshal(r, &mode{Small: 1.0000001e-7, Fixed: 1e21})
+	if err != nil {
+		t.Fatalf("unexpected marshal error: %s", err)
+	}
+	if r.URL.RawQuery != "fixed=1000000000000000000000&small=1.0000001e-07" {
+		t.Fatalf("wrong query. got=%s", r.URL.RawQuery)
+	}
+
+	testUnmarshalFormError(t, "1", &struct {
+		Val float64 `form:"value,format=b"`
+	}{}, "form: invalid option format in tag of Go struct field .Val: \"b\" is not one of e, f, g or x")
+	testUnmarshalFormError(t, "1", &struct {
+		Val int `form:"value,format=g"`
+	}{}, "form: invalid option format in tag of Go struct field .Val: option only applies to float fields, not int")
+}
+
+func TestFloatPrecisionMarshal(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Price  float64   `form:"price,prec=2"`
+		Whole  float64   `form:"whole,prec=0"`
+		Rates  []float32 `form:"rates,prec=3"`
+		Exp    float64   `form:"exp,format=e,prec=1"`
+		Plain  float64   `form:"plain"`
+		PtrVal *float64  `form:"ptr,prec=4"`
+	}
+
+	half := 0.5
+	value := s{Price: 10.499, Whole: 2.5, Rates: []float32{0.1, 1.23456}, Exp: 12345, Plain: 0.1, PtrVal: &half}
+	testMarshalForm(t, &value, "exp=1.2e%2B04&plain=0.100000&price=10.50&ptr=0.5000&rates=0.100&rates=1.235&whole=2")
+
+	r, _ := http.NewRequest(http.MethodGet, "/", nil)
+	if err := form.NewEncoder().FloatFormatMode(form.FloatShortest).Marshal(r, &value); err != nil {
+		t.Fatalf("unexpected marshal error: %s", err)
+	}
+	if r.URL.RawQuery != "exp=1.2e%2B04&plain=0.1&price=10.50&ptr=0.5000&rates=0.100&rates=1.235&whole=2" {
+		t.Fatalf("prec should override the encoder's float format. got=%s", r.URL.RawQuery)
+	}
+
+	testUnmarshalFormError(t, "1", &struct {
+		Val float64 `form:"value,prec=-1"`
+	}{}, "form: invalid option prec in tag of Go struct field .Val: \"-1\" is not a non-negative integer")
+	testUnmarshalFormError(t, "1", &struct {
+		Val string `form:"value,prec=2"`
+	}{}, "form: invalid option prec in tag of Go struct field .Val: option only applies to float fields, not string")
+}
+
+func TestColorRoundTrip(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Fg      int      `form:"fg,color"`
+		Bg      uint32   `form:"bg,color"`
+		Palette []uint32 `form:"palette,color"`
+		Accent  *int     `form:"accent,color"`
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, "/?"+url.Values{
+		"fg":      {"#ff8800"},
+		"bg":      {"#F80"},
+		"palette": {"#000", "#0000ff"},
+		"accent":  {"#abc"},
+	}.Encode(), nil)
+	var actual s
+	if err := form.Unmarshal(r, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	accent := 0xaabbcc
+	expected := s{Fg: 0xff8800, Bg: 0xff8800, Palette: []uint32{0, 0xff}, Accent: &accent}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("wrong struct. want=%+v, got=%+v", expected, actual)
+	}
+
+	testMarshalForm(t, &expected, "accent=%23aabbcc&bg=%23ff8800&fg=%23ff8800&palette=%23000000&palette=%230000ff")
+
+	for _, value := range []string{"ff8800", "#ff880", "#ff88001", "#gg8800", "#+f8", ""} {
+		r, _ := http.NewRequest(http.MethodGet, "/?"+url.Values{"fg": {value}}.Encode(), nil)
+		err := form.Unmarshal(r, &s{})
+		want := fmt.Sprintf("form: cannot unmarshal %s into Go struct field s.Fg of type int: %q is not a color of the form #rgb or #rrggbb", value, value)
+		if err == nil || err.Error() != want {
+			t.Fatalf("wrong error for %q. want=%s, got=%v", value, want, err)
+		}
+	}
+
+	testUnmarshalFormError(t, "#fff", &struct {
+		Val uint8 `form:"value,color"`
+	}{}, "form: cannot unmarshal #fff into Go struct field .Val of type uint8: #fff overflows uint8 value")
+	testUnmarshalFormError(t, "#fff", &struct {
+		Val string `form:"value,color"`
+	}{}, "form: invalid option color in tag of Go struct field .Val: option only applies to integer fields, not string")
+
+	r, _ = http.NewRequest(http.MethodGet, "/", nil)
+	var typeErr *form.MarshalTypeError
+	if err := form.Marshal(r, &s{Fg: -1}); !errors.As(err, &typeErr) {
+		t.Fatalf("expected MarshalTypeError for a negative color, got=%v", err)
+	}
+	for _, v := range []s{{Bg: 0x1000000}, {Fg: 0x1000000}} {
+		err := form.Marshal(r, &v)
+		if !errors.As(err, &typeErr) || !strings.Contains(err.Error(), "value 0x1000000 is larger than the color #ffffff") {
+			t.Fatalf("expected MarshalTypeError for a color above #ffffff, got=%v", err)
+		}
+	}
+	r, _ = http.NewRequest(http.MethodGet, "/", nil)
+	if err := form.Marshal(r, &s{Fg: 0xffffff}); err != nil || r.URL.Query().Get("fg") != "#ffffff" {
+		t.Fatalf("wrong largest color. got=%s, err=%v", r.URL.RawQuery, err)
+	}
+}
+
+func TestDropDefaultMarshal(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Limit int       `form:"limit,default=20,dropdefault"`
+		Price float64   `form:"price,default=9.5,dropdefault"`
+		Sort  string    `form:"sort,default=name,dropdefault"`
+		Tags  []string  `form:"tags,sep,default=a\\,b,dropdefault"`
+		Page  *int      `form:"page,default=1,dropdefault"`
+		Kept  int       `form:"kept,default=20"`
+		Zero  int       `form:"zero,default=5,dropdefault,omitempty"`
+		Since time.Time `form:"since,layout=2006-01-02,default=2024-01-01,dropdefault"`
+	}
+
+	one := 1
+	value := s{
+		Limit: 20, Price: 9.5, Sort: "name", Tags: []string{"a", "b"}, Page: &one, Kept: 20,
+		Since: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	testMarshalForm(t, &value, "kept=20")
+
+	two := 2
+	value = s{
+		Limit: 50, Price: 10, Sort: "date", Tags: []string{"a"}, Page: &two, Kept: 50, Zero: 5,
+		Since: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+	}
+	testMarshalForm(t, &value, "kept=50&limit=50&page=2&price=10.000000&since=2024-02-01&sort=date&tags=a")
+
+	r, _ := http.NewRequest(http.MethodGet, "/", nil)
+	value = s{Limit: 20, Price: 9.5, Sort: "name", Tags: []string{"a", "b"}, Page: &one, Kept: 20}
+	if err := form.Marshal(r, &value); err != nil {
+		t.Fatalf("unexpected marshal error: %s", err)
+	}
+	var actual s
+	if err := form.Unmarshal(r, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	if actual.Limit != 20 || actual.Price != 9.5 || actual.Sort != "name" || *actual.Page != 1 || actual.Zero != 5 {
+		t.Fatalf("dropped fields should unmarshal to their defaults. got=%+v", actual)
+	}
+
+	testUnmarshalFormError(t, "1", &struct {
+		Val int `form:"value,dropdefault"`
+	}{}, "form: invalid option dropdefault in tag of Go struct field .Val: option needs the default option")
+}
+
 func TestComplexMarshal(t *testing.T) {
 	t.Parallel()
 	type s struct {
 		A complex64 `form:"a"`
 	}
 
-	testMarshalForm(t, &s{A: 9.421}, "a=%289.421000e%2B00%2B0.000000e%2B00i%29")
+	testMarshalForm(t, &s{A: 9.421}, "a=%289.421%2B0i%29")
 }
 
 func TestSliceMarshal(t *testing.T) {
@@ -155,6 +449,241 @@ func TestSliceMarshalTypeError(t *testing.T) {
 	}
 }
 
+func TestBracketSlicesMarshal(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Name string `form:"name"`
+		IDs  []int  `form:"ids"`
+		Pair [2]int `form:"pair"`
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, "/", nil)
+	err := form.NewEncoder().BracketSlices().Marshal(r, &s{Name: "a", IDs: []int{1, 2}, Pair: [2]int{3, 4}})
+	if err != nil {
+		t.Fatalf("unexpected error from Marshal: %s", err)
+	}
+
+	expected := "ids%5B%5D=1&ids%5B%5D=2&name=a&pair%5B%5D=3&pair%5B%5D=4"
+	if r.URL.RawQuery != expected {
+		t.Fatalf("wrong query. want=%s, got=%s", expected, r.URL.RawQuery)
+	}
+}
+
+func TestSeparatorMarshal(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Flags []bool   `form:"flags,sep"`
+		Tags  []string `form:"tags,sep=;"`
+		Empty []int    `form:"empty,sep"`
+	}
+
+	testMarshalForm(t, &s{Flags: []bool{true, false}, Tags: []string{"a", "b"}}, "flags=true%2Cfalse&tags=a%3Bb")
+}
+
+func TestDurationRoundTrip(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Timeout time.Duration   `form:"timeout"`
+		TTL     time.Duration   `form:"ttl,unit=s"`
+		Delays  []time.Duration `form:"delays,unit=ms"`
+	}
+
+	expected := s{
+		Timeout: 90 * time.Minute,
+		TTL:     90 * time.Minute,
+		Delays:  []time.Duration{5 * time.Millisecond, time.Second},
+	}
+	r, _ := http.NewRequest(http.MethodGet, "/", nil)
+	err := form.Marshal(r, &expected)
+	if err != nil {
+		t.Fatalf("unexpected error from Marshal: %s", err)
+	}
+	if r.URL.RawQuery != "delays=5&delays=1000&timeout=1h30m0s&ttl=5400" {
+		t.Fatalf("wrong query. want=%s, got=%s", "delays=5&delays=1000&timeout=1h30m0s&ttl=5400", r.URL.RawQuery)
+	}
+
+	var actual s
+	err = form.Unmarshal(r, &actual)
+	if err != nil {
+		t.Fatalf("unexpected error from Unmarshal: %s", err)
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("durations did not round trip. want=%+v, got=%+v", expected, actual)
+	}
+}
+
+func TestDurationUnitTruncates(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		TTL time.Duration `form:"ttl,unit=s"`
+	}
+
+	testMarshalForm(t, &s{TTL: 1500 * time.Millisecond}, "ttl=1")
+}
+
+func TestPreserveOrderMarshal(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Zeta  string   `form:"zeta"`
+		Alpha []int    `form:"alpha"`
+		Tags  []string `form:"tags,sep"`
+		Mid   string   `form:"mid"`
+		Skip  string
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, "/", nil)
+	err := form.NewEncoder().PreserveOrder().Marshal(r, &s{Zeta: "z z", Alpha: []int{3, 1, 2}, Tags: []string{"b", "a"}, Mid: "m&n", Skip: "x"})
+	if err != nil {
+		t.Fatalf("unexpected error from Marshal: %s", err)
+	}
+
+	expected := "zeta=z+z&alpha=3&alpha=1&alpha=2&tags=b%2Ca&mid=m%26n"
+	if r.URL.RawQuery != expected {
+		t.Fatalf("wrong query. want=%s, got=%s", expected, r.URL.RawQuery)
+	}
+}
+
+func TestKeyOrderMarshal(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Zeta  string `form:"zeta"`
+		Alpha []int  `form:"alpha"`
+		Mid   string `form:"mid"`
+		Beta  string `form:"beta"`
+		Nil   *int   `form:"nil"`
+	}
+	value := s{Zeta: "z", Alpha: []int{2, 1}, Mid: "m", Beta: "b"}
+
+	tests := []struct {
+		encoder  *form.Encoder
+		expected string
+	}{
+		{form.NewEncoder().KeyOrder([]string{"mid", "alpha"}), "mid=m&alpha=2&alpha=1&zeta=z&beta=b"},
+		{form.NewEncoder().KeyOrder([]string{"beta", "nil", "unknown", "beta"}), "beta=b&zeta=z&alpha=2&alpha=1&mid=m"},
+		{form.NewEncoder().KeyOrder([]string{}), "zeta=z&alpha=2&alpha=1&mid=m&beta=b"},
+		{form.NewEncoder().BracketSlices().KeyOrder([]string{"alpha"}), "alpha%5B%5D=2&alpha%5B%5D=1&zeta=z&mid=m&beta=b"},
+	}
+	for _, tt := range tests {
+		r, _ := http.NewRequest(http.MethodGet, "/", nil)
+		if err := tt.encoder.Marshal(r, &value); err != nil {
+			t.Fatalf("unexpected error from Marshal: %s", err)
+		}
+		if r.URL.RawQuery != tt.expected {
+			t.Fatalf("wrong query. want=%s, got=%s", tt.expected, r.URL.RawQuery)
+		}
+	}
+
+	order := []string{"mid"}
+	e := form.NewEncoder().KeyOrder(order)
+	order[0] = "beta"
+	r, _ := http.NewRequest(http.MethodGet, "/", nil)
+	if err := e.Marshal(r, &value); err != nil {
+		t.Fatalf("unexpected error from Marshal: %s", err)
+	}
+	if r.URL.RawQuery != "mid=m&zeta=z&alpha=2&alpha=1&beta=b" {
+		t.Fatalf("expected KeyOrder to copy its keys. got=%s", r.URL.RawQuery)
+	}
+}
+
+func TestPreserveOrderEscapesKeys(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Space  string `form:"first name"`
+		Amp    string `form:"a&b"`
+		Equals string `form:"x=y"`
+		IDs    []int  `form:"ids"`
+	}
+
+	expected := s{Space: "John Smith", Amp: "c&d", Equals: "1=2", IDs: []int{1, 2}}
+	r, _ := http.NewRequest(http.MethodGet, "/", nil)
+	err := form.NewEncoder().PreserveOrder().BracketSlices().Marshal(r, &expected)
+	if err != nil {
+		t.Fatalf("unexpected error from Marshal: %s", err)
+	}
+
+	query := "first+name=John+Smith&a%26b=c%26d&x%3Dy=1%3D2&ids%5B%5D=1&ids%5B%5D=2"
+	if r.URL.RawQuery != query {
+		t.Fatalf("wrong query. want=%s, got=%s", query, r.URL.RawQuery)
+	}
+
+	values, err := url.ParseQuery(r.URL.RawQuery)
+	if err != nil {
+		t.Fatalf("marshalled query does not parse: %s", err)
+	}
+	if values.Get("first name") != "John Smith" || values.Get("a&b") != "c&d" || values.Get("x=y") != "1=2" || len(values["ids[]"]) != 2 {
+		t.Fatalf("wrong parsed values. got=%v", values)
+	}
+
+	var actual s
+	err = form.Unmarshal(r, &actual)
+	if err != nil {
+		t.Fatalf("unexpected error from Unmarshal: %s", err)
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("special keys did not round trip. want=%+v, got=%+v", expected, actual)
+	}
+}
+
+func TestEncodedBytesRoundTrip(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Hash [4]byte `form:"hash,hex"`
+		Key  [3]byte `form:"key,base64"`
+		Data []byte  `form:"data,base64"`
+		None []byte  `form:"none,hex"`
+	}
+
+	expected := s{
+		Hash: [4]byte{0xde, 0xad, 0xbe, 0xef},
+		Key:  [3]byte{'k', 'e', 'y'},
+		Data: []byte("hello"),
+	}
+	r, _ := http.NewRequest(http.MethodGet, "/", nil)
+	err := form.NewEncoder().BracketSlices().Marshal(r, &expected)
+	if err != nil {
+		t.Fatalf("unexpected error from Marshal: %s", err)
+	}
+	if r.URL.RawQuery != "data=aGVsbG8%3D&hash=deadbeef&key=a2V5" {
+		t.Fatalf("wrong query. want=%s, got=%s", "data=aGVsbG8%3D&hash=deadbeef&key=a2V5", r.URL.RawQuery)
+	}
+
+	var actual s
+	err = form.Unmarshal(r, &actual)
+	if err != nil {
+		t.Fatalf("unexpected error from Unmarshal: %s", err)
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("bytes did not round trip. want=%+v, got=%+v", expected, actual)
+	}
+}
+
+func TestNilFieldsMarshal(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Map       map[string]string `form:"map"`
+		Slice     []int             `form:"slice"`
+		Interface interface{}       `form:"interface"`
+		Pointer   *int              `form:"pointer"`
+		Pointers  []*int            `form:"pointers"`
+		Name      string            `form:"name"`
+	}
+
+	testMarshalForm(t, &s{Pointers: []*int{nil}, Name: "a"}, "name=a")
+}
+
+func TestPointerAndInterfaceMarshal(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Interface interface{} `form:"interface"`
+		Pointer   *int        `form:"pointer"`
+		Slice     *[]string   `form:"slice"`
+	}
+
+	n := 5
+	slice := []string{"a", "b"}
+	testMarshalForm(t, &s{Interface: 3.5, Pointer: &n, Slice: &slice}, "interface=3.500000&pointer=5&slice=a&slice=b")
+}
+
 func testMarshalForm(t *testing.T, i interface{}, expectedQuery string) {
 	t.Helper()
 
@@ -168,3 +697,754 @@ func testMarshalForm(t *testing.T, i interface{}, expectedQuery string) {
 		t.Fatalf("wrong query. want=%s, got=%s", expectedQuery, r.URL.RawQuery)
 	}
 }
+
+func TestDropDefaultEncoderOptions(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Price float64      `form:"price,default=9\\,5,dropdefault"`
+		ID    registeredID `form:"id,default=id-1,dropdefault"`
+		limit int          `form:"limit,default=20,dropdefault"`
+	}
+
+	e := form.NewEncoder().DecimalComma().RegisterEncoder(reflect.TypeOf(registeredID{}), func(v interface{}) (string, error) {
+		return fmt.Sprintf("id-%d", v.(registeredID)[3]), nil
+	})
+	tests := []struct {
+		input    s
+		expected string
+	}{
+		{s{Price: 9.5, ID: registeredID{0, 0, 0, 1}, limit: 20}, "limit=20"},
+		{s{Price: 10.25, ID: registeredID{0, 0, 0, 2}, limit: 5}, "id=id-2&limit=5&price=10%2C250000"},
+	}
+	for _, tt := range tests {
+		r, _ := http.NewRequest(http.MethodGet, "/", nil)
+		if err := e.Marshal(r, &tt.input); err != nil {
+			t.Fatalf("unexpected marshal error: %s", err)
+		}
+		if r.URL.RawQuery != tt.expected {
+			t.Fatalf("wrong query. want=%s, got=%s", tt.expected, r.URL.RawQuery)
+		}
+	}
+}
+
+func TestRegisteredTypeMarshal(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		ID  registeredID   `form:"id"`
+		IDs []registeredID `form:"ids"`
+	}
+
+	testMarshalForm(t, &s{
+		ID:  registeredID{0x0a, 0x0b, 0x0c, 0x0d},
+		IDs: []registeredID{{0, 0, 0, 1}},
+	}, "id=0a0b0c0d&ids=00000001")
+
+	r, _ := http.NewRequest(http.MethodGet, "/", nil)
+	err := form.NewEncoder().BracketSlices().Marshal(r, &s{IDs: []registeredID{{}, {}}})
+	if err != nil {
+		t.Fatalf("unexpected marshal error: %s", err)
+	}
+	if r.URL.RawQuery != "id=00000000&ids%5B%5D=00000000&ids%5B%5D=00000000" {
+		t.Fatalf("wrong query. got=%s", r.URL.RawQuery)
+	}
+}
+
+func TestEncoderRegisterEncoder(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		ID registeredID `form:"id"`
+	}
+
+	errEncode := errors.New("cannot encode")
+	e := form.NewEncoder().RegisterEncoder(reflect.TypeOf(registeredID{}), func(v interface{}) (string, error) {
+		return "", errEncode
+	})
+	r, _ := http.NewRequest(http.MethodGet, "/", nil)
+	err := e.Marshal(r, &s{})
+	if !errors.Is(err, errEncode) {
+		t.Fatalf("expected error to wrap encoder error. got=%v", err)
+	}
+	if err.Error() != "form: cannot marshal [0 0 0 0] (form_test.registeredID) of Go struct field s.ID into form data: cannot encode" {
+		t.Fatalf("wrong error. got=%s", err)
+	}
+
+	e = form.NewEncoder().RegisterEncoder(reflect.TypeOf(0), func(v interface{}) (string, error) {
+		return fmt.Sprintf("#%d", v), nil
+	})
+	r, _ = http.NewRequest(http.MethodGet, "/", nil)
+	if err := e.Marshal(r, &struct {
+		N int `form:"n"`
+	}{N: 3}); err != nil {
+		t.Fatalf("unexpected marshal error: %s", err)
+	}
+	if r.URL.RawQuery != "n=%233" {
+		t.Fatalf("wrong query. got=%s", r.URL.RawQuery)
+	}
+}
+
+func TestSeparatorKindsMarshal(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Uints  []uint      `form:"uints,sep"`
+		Floats [2]float64  `form:"floats,sep=|"`
+		Bools  []bool      `form:"bools,sep"`
+		Dates  []time.Time `form:"dates,sep,layout=2006-01-02"`
+	}
+
+	testMarshalForm(t, &s{
+		Uints:  []uint{1, 2},
+		Floats: [2]float64{1.5, -2},
+		Bools:  []bool{true, false},
+		Dates:  []time.Time{time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)},
+	}, "bools=true%2Cfalse&dates=2024-01-02&floats=1.500000%7C-2.000000&uints=1%2C2")
+}
+
+func TestTimeMarshal(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Created time.Time `form:"created"`
+		Date    time.Time `form:"date,layout=2006-01-02"`
+	}
+
+	created := time.Date(2024, 3, 4, 5, 6, 7, 0, time.FixedZone("", 2*60*60))
+	testMarshalForm(t, &s{Created: created, Date: created}, "created=2024-03-04T05%3A06%3A07%2B02%3A00&date=2024-03-04")
+
+	type layouts struct {
+		Date time.Time `form:"date,layout:2006/01/02|2006-01-02"`
+	}
+	testMarshalForm(t, &layouts{Date: created}, "date=2024%2F03%2F04")
+}
+
+func TestUnexportedFieldsMarshal(t *testing.T) {
+	t.Parallel()
+	type times struct {
+		when time.Time `form:"when"`
+	}
+	type registered struct {
+		id registeredID `form:"id"`
+	}
+	type plain struct {
+		name string         `form:"name"`
+		vals url.Values     `form:"vals"`
+		meta map[string]int `form:"meta"`
+		tags []string       `form:"tags"`
+	}
+
+	e := form.NewEncoder().RegisterEncoder(reflect.TypeOf(registeredID{}), func(v interface{}) (string, error) {
+		return "id", nil
+	})
+	tests := []struct {
+		input    interface{}
+		expected string
+	}{
+		{&times{}, "form: cannot marshal <nil> (time.Time) of Go struct field times.when into form data: cannot read the value of an unexported field"},
+		{&registered{}, "form: cannot marshal <nil> (form_test.registeredID) of Go struct field registered.id into form data: cannot read the value of an unexported field"},
+	}
+	for _, tt := range tests {
+		r, _ := http.NewRequest(http.MethodGet, "/", nil)
+		err := e.Marshal(r, tt.input)
+		var typeErr *form.MarshalTypeError
+		if !errors.As(err, &typeErr) || err.Error() != tt.expected {
+			t.Fatalf("wrong error. want=%s, got=%v", tt.expected, err)
+		}
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, "/", nil)
+	err := e.Marshal(r, &plain{name: "a", vals: url.Values{"k": {"v"}}, meta: map[string]int{"m": 1}, tags: []string{"x", "y"}})
+	if err != nil {
+		t.Fatalf("unexpected marshal error: %s", err)
+	}
+	if r.URL.RawQuery != "meta%5Bm%5D=1&name=a&tags=x&tags=y&vals%5Bk%5D=v" {
+		t.Fatalf("wrong query. got=%s", r.URL.RawQuery)
+	}
+}
+
+func TestOmitEmptyMarshal(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Name  string    `form:"name,omitempty"`
+		N     int       `form:"n,omitempty"`
+		B     bool      `form:"b,omitempty"`
+		P     *int      `form:"p,omitempty"`
+		Tags  []string  `form:"tags,omitempty"`
+		Date  time.Time `form:"date,omitempty"`
+		Count int       `form:"count"`
+	}
+
+	testMarshalForm(t, &s{Tags: []string{}}, "count=0")
+	p := 0
+	date := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	testMarshalForm(t, &s{Name: "a", N: 1, B: true, P: &p, Tags: []string{"x"}, Date: date}, "b=true&count=0&date=2024-01-02T00%3A00%3A00Z&n=1&name=a&p=0&tags=x")
+
+	// The zero time in another location is not a zero struct, but is still the zero time.
+	loc := time.FixedZone("EST", -5*60*60)
+	testMarshalForm(t, &s{Date: time.Time{}.In(loc)}, "count=0")
+
+	type ptr struct {
+		Date *time.Time `form:"date,omitempty"`
+	}
+	testMarshalForm(t, &ptr{}, "")
+	testMarshalForm(t, &ptr{Date: &date}, "date=2024-01-02T00%3A00%3A00Z")
+
+	type unexported struct {
+		name string `form:"name,omitempty"`
+		n    int    `form:"n,omitempty"`
+		Tag  string `form:"tag"`
+	}
+	testMarshalForm(t, &unexported{Tag: "t"}, "tag=t")
+	testMarshalForm(t, &unexported{name: "a", n: 2}, "n=2&name=a&tag=")
+}
+
+func TestEmitEmptyMarshal(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		P     *int     `form:"p"`
+		Name  string   `form:"name"`
+		N     int      `form:"n"`
+		Tags  []string `form:"tags,sep"`
+		Ids   []int    `form:"ids"`
+		Omit  *int     `form:"omit,omitempty"`
+		Value *string  `form:"value"`
+	}
+
+	value := "v"
+	r, _ := http.NewRequest(http.MethodGet, "/", nil)
+	err := form.NewEncoder().EmitEmpty().Marshal(r, &s{Value: &value})
+	if err != nil {
+		t.Fatalf("unexpected marshal error: %s", err)
+	}
+	if r.URL.RawQuery != "ids=&n=0&name=&p=&tags=&value=v" {
+		t.Fatalf("wrong query. got=%s", r.URL.RawQuery)
+	}
+
+	testMarshalForm(t, &s{Value: &value}, "n=0&name=&value=v")
+}
+
+func TestDecimalCommaMarshal(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Price  float64   `form:"price"`
+		Prices []float32 `form:"prices,sep=;"`
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, "/", nil)
+	err := form.NewEncoder().DecimalComma().Marshal(r, &s{Price: 10.49, Prices: []float32{1.5, 2}})
+	if err != nil {
+		t.Fatalf("unexpected marshal error: %s", err)
+	}
+	if r.URL.RawQuery != "price=10%2C490000&prices=1%2C500000%3B2%2C000000" {
+		t.Fatalf("wrong query. got=%s", r.URL.RawQuery)
+	}
+}
+
+func TestNumericBoolMarshal(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Active bool   `form:"active,numeric"`
+		Flags  []bool `form:"flags,numeric"`
+		Ptr    *bool  `form:"ptr,numeric"`
+		Plain  bool   `form:"plain"`
+	}
+
+	yes := true
+	testMarshalForm(t, &s{Active: true, Flags: []bool{false, true}, Ptr: &yes}, "active=1&flags=0&flags=1&plain=false&ptr=1")
+
+	r, _ := http.NewRequest(http.MethodGet, "/", nil)
+	err := form.NewEncoder().NumericBools().Marshal(r, &s{Plain: true})
+	if err != nil {
+		t.Fatalf("unexpected marshal error: %s", err)
+	}
+	if r.URL.RawQuery != "active=0&plain=1" {
+		t.Fatalf("wrong query. got=%s", r.URL.RawQuery)
+	}
+
+	var actual s
+	r, _ = http.NewRequest(http.MethodGet, "/?active=1&flags=0&flags=1&plain=1", nil)
+	if err := form.Unmarshal(r, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	if !actual.Active || !reflect.DeepEqual(actual.Flags, []bool{false, true}) || !actual.Plain {
+		t.Fatalf("wrong struct. got=%+v", actual)
+	}
+
+	type invalid struct {
+		N int `form:"n,numeric"`
+	}
+	err = form.Marshal(r, &invalid{})
+	if err == nil || err.Error() != "form: invalid option numeric in tag of Go struct field invalid.N: option only applies to bool fields, not int" {
+		t.Fatalf("wrong error. got=%v", err)
+	}
+}
+
+func TestKeyFuncMarshal(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Name   string `form:"name"`
+		Nick   string
+		Secret string `form:"secret"`
+	}
+
+	e := form.NewEncoder().KeyFunc(func(fieldName, tag string) string {
+		switch {
+		case tag == "secret":
+			return ""
+		case tag == "":
+			return "x_" + fieldName
+		}
+		return tag
+	})
+	r, _ := http.NewRequest(http.MethodGet, "/", nil)
+	if err := e.Marshal(r, &s{Name: "John", Nick: "JJ", Secret: "pw"}); err != nil {
+		t.Fatalf("unexpected marshal error: %s", err)
+	}
+	if r.URL.RawQuery != "name=John&x_Nick=JJ" {
+		t.Fatalf("wrong query. got=%s", r.URL.RawQuery)
+	}
+}
+
+func TestNestedStructsMarshal(t *testing.T) {
+	t.Parallel()
+	type address struct {
+		Street string `form:"street"`
+		Zip    string `form:"zip,omitempty"`
+	}
+	type Meta struct {
+		Source string `form:"source"`
+	}
+	type s struct {
+		Meta
+		Name    string  `form:"name"`
+		Address address `form:"address"`
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, "/", nil)
+	if err := form.Marshal(r, &s{Meta: Meta{Source: "web"}, Name: "ann", Address: address{Street: "Main St"}}); err != nil {
+		t.Fatalf("unexpected marshal error: %s", err)
+	}
+	if r.URL.RawQuery != "address.street=Main+St&name=ann&source=web" {
+		t.Fatalf("wrong query. got=%s", r.URL.RawQuery)
+	}
+}
+
+func TestMapKeyOrderMarshal(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Name string                       `form:"name"`
+		Meta map[string]map[string]string `form:"meta"`
+	}
+
+	rank := map[string]int{"z": 0, "b": 1, "a": 2}
+	e := form.NewEncoder().PreserveOrder().MapKeyOrder(func(a, b string) bool { return rank[a] < rank[b] })
+	r, _ := http.NewRequest(http.MethodGet, "/", nil)
+	v := &s{Name: "ann", Meta: map[string]map[string]string{"a": {"b": "1", "z": "2"}, "z": {"a": "3"}}}
+	if err := e.Marshal(r, v); err != nil {
+		t.Fatalf("unexpected marshal error: %s", err)
+	}
+	expected := "name=ann&meta%5Bz%5D%5Ba%5D=3&meta%5Ba%5D%5Bz%5D=2&meta%5Ba%5D%5Bb%5D=1"
+	if r.URL.RawQuery != expected {
+		t.Fatalf("wrong query. got=%s", r.URL.RawQuery)
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "/", nil)
+	if err := form.NewEncoder().PreserveOrder().Marshal(r, v); err != nil {
+		t.Fatalf("unexpected marshal error: %s", err)
+	}
+	expected = "name=ann&meta%5Ba%5D%5Bb%5D=1&meta%5Ba%5D%5Bz%5D=2&meta%5Bz%5D%5Ba%5D=3"
+	if r.URL.RawQuery != expected {
+		t.Fatalf("wrong query. got=%s", r.URL.RawQuery)
+	}
+}
+
+func TestFlattenMarshal(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		IDs  []int    `form:"ids,flatten"`
+		Tags []string `form:"tags"`
+	}
+
+	tests := []struct {
+		value    s
+		encoder  *form.Encoder
+		expected string
+	}{
+		{s{}, form.NewEncoder().BracketSlices(), ""},
+		{s{IDs: []int{1}, Tags: []string{"a"}}, form.NewEncoder().BracketSlices(), "ids=1&tags%5B%5D=a"},
+		{s{IDs: []int{1, 2}, Tags: []string{"a", "b"}}, form.NewEncoder().BracketSlices(), "ids%5B%5D=1&ids%5B%5D=2&tags%5B%5D=a&tags%5B%5D=b"},
+		{s{IDs: []int{1}, Tags: []string{"a"}}, form.NewEncoder().BracketSlices().Flatten(), "ids=1&tags=a"},
+		{s{IDs: []int{1, 2}, Tags: []string{"a"}}, form.NewEncoder(), "ids=1&ids=2&tags=a"},
+	}
+	for _, tt := range tests {
+		r, _ := http.NewRequest(http.MethodGet, "/", nil)
+		if err := tt.encoder.Marshal(r, &tt.value); err != nil {
+			t.Fatalf("unexpected marshal error: %s", err)
+		}
+		if r.URL.RawQuery != tt.expected {
+			t.Errorf("wrong query for %+v. expected=%s, got=%s", tt.value, tt.expected, r.URL.RawQuery)
+		}
+	}
+
+	type invalid struct {
+		ID int `form:"id,flatten"`
+	}
+	err := form.ValidateStruct(&invalid{})
+	want := "form: invalid option flatten in tag of Go struct field invalid.ID: option only applies to slice and array fields, not int"
+	if err == nil || err.Error() != want {
+		t.Fatalf("wrong error. expected=%s, got=%v", want, err)
+	}
+}
+
+func TestDecimalCommaRoundTrip(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Price    float64         `form:"price"`
+		Discount *float32        `form:"discount"`
+		Rates    []float64       `form:"rates,sep=;"`
+		Tax      sql.NullFloat64 `form:"tax"`
+		Ratio    float64         `form:"ratio,format=g"`
+		Count    int             `form:"count"`
+		Active   bool            `form:"active"`
+	}
+
+	values := url.Values{
+		"price":    {"10,49"},
+		"discount": {"0,5"},
+		"rates":    {"1,25;2"},
+		"tax":      {"0,2"},
+		"ratio":    {"1,5"},
+		"count":    {"1000"},
+		"active":   {"true"},
+	}
+	var decoded s
+	if err := form.NewDecoder().DecimalComma().DecodeFrom(values, &decoded); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, "/", nil)
+	if err := form.NewEncoder().DecimalComma().Marshal(r, &decoded); err != nil {
+		t.Fatalf("unexpected marshal error: %s", err)
+	}
+	var roundTrip s
+	if err := form.NewDecoder().DecimalComma().DecodeFrom(r.URL.Query(), &roundTrip); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	if !reflect.DeepEqual(roundTrip, decoded) {
+		t.Fatalf("wrong round trip. want=%+v, got=%+v", decoded, roundTrip)
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "/", nil)
+	if err := form.Marshal(r, &decoded); err != nil {
+		t.Fatalf("unexpected marshal error: %s", err)
+	}
+	expected := "active=true&count=1000&discount=0.500000&price=10.490000&rates=1.250000%3B2.000000&ratio=1.5&tax=0.200000"
+	if r.URL.RawQuery != expected {
+		t.Fatalf("expected locale neutral output by default. got=%s", r.URL.RawQuery)
+	}
+}
+
+func TestDecimalCommaMarshalCommaSep(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Vals []float64 `form:"vals,sep"`
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, "/", nil)
+	err := form.NewEncoder().DecimalComma().Marshal(r, &s{Vals: []float64{1.5}})
+	want := "form: cannot marshal [1.5] ([]float64) of Go struct field s.Vals into form data: sep cannot join with commas when DecimalComma is set"
+	if err == nil || err.Error() != want {
+		t.Fatalf("wrong error. expected=%s, got=%v", want, err)
+	}
+}
+
+func TestComplexRoundTrip(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Slice     []complex128 `form:"slice"`
+		Array     [2]complex64 `form:"array"`
+		Separated []complex128 `form:"separated,sep=;"`
+	}
+
+	expected := s{
+		Slice:     []complex128{1 + 2i, complex(-1.2345678901234567e-10, math.MaxFloat64), complex(math.Inf(1), math.NaN())},
+		Array:     [2]complex64{complex(0.1, -0.2), complex(math.MaxFloat32, math.SmallestNonzeroFloat32)},
+		Separated: []complex128{complex(1.0/3, 2.0/3), 3},
+	}
+	r, _ := http.NewRequest(http.MethodGet, "/", nil)
+	if err := form.Marshal(r, &expected); err != nil {
+		t.Fatalf("unexpected marshal error: %s", err)
+	}
+	var actual s
+	if err := form.Unmarshal(r, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	if fmt.Sprint(actual) != fmt.Sprint(expected) {
+		t.Fatalf("wrong round trip of %s. want=%v, got=%v", r.URL.RawQuery, expected, actual)
+	}
+	if actual.Array != expected.Array || actual.Separated[0] != expected.Separated[0] || actual.Slice[1] != expected.Slice[1] {
+		t.Fatalf("expected exact values after round trip. want=%v, got=%v", expected, actual)
+	}
+}
+
+type impedance complex128
+
+type phasor complex64
+
+func TestNamedComplexRoundTrip(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Load   impedance    `form:"load"`
+		Ptr    *impedance   `form:"ptr"`
+		Phases []phasor     `form:"phases"`
+		Pair   [2]impedance `form:"pair,sep=;"`
+	}
+
+	z := impedance(complex(50, -1.0/3))
+	expected := s{
+		Load:   impedance(complex(75, 0.5)),
+		Ptr:    &z,
+		Phases: []phasor{phasor(complex(0.1, -0.2)), phasor(complex(math.MaxFloat32, 1))},
+		Pair:   [2]impedance{1, impedance(complex(0, 1))},
+	}
+	r, _ := http.NewRequest(http.MethodGet, "/", nil)
+	if err := form.Marshal(r, &expected); err != nil {
+		t.Fatalf("unexpected marshal error: %s", err)
+	}
+	if r.URL.Query().Get("load") != "(75+0.5i)" {
+		t.Fatalf("wrong query. got=%s", r.URL.RawQuery)
+	}
+	var actual s
+	if err := form.Unmarshal(r, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("wrong round trip of %s. want=%v, got=%v", r.URL.RawQuery, expected, actual)
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "/?phases=1e39", nil)
+	err := form.Unmarshal(r, &s{})
+	want := "form: cannot unmarshal [1e39] into Go struct field s.Phases of type []form_test.phasor: 1e39 overflows form_test.phasor value"
+	if err == nil || err.Error() != want {
+		t.Fatalf("wrong error. want=%s, got=%v", want, err)
+	}
+}
+
+func TestJSONArrayRoundTrip(t *testing.T) {
+	t.Parallel()
+	type point struct {
+		X int `json:"x"`
+		Y int `json:"y"`
+	}
+	type s struct {
+		IDs    []int     `form:"ids,json"`
+		Tags   [2]string `form:"tags,json"`
+		Points []point   `form:"points,json"`
+		Empty  []int     `form:"empty,json"`
+		Nil    []float64 `form:"nil,json"`
+	}
+
+	expected := s{
+		IDs:    []int{1, 2, 3},
+		Tags:   [2]string{"a,b", "c"},
+		Points: []point{{1, 2}, {3, 4}},
+		Empty:  []int{},
+	}
+	r, _ := http.NewRequest(http.MethodGet, "/", nil)
+	if err := form.Marshal(r, &expected); err != nil {
+		t.Fatalf("unexpected marshal error: %s", err)
+	}
+	query := r.URL.Query()
+	if query.Get("ids") != "[1,2,3]" || query.Get("tags") != `["a,b","c"]` || query.Get("points") != `[{"x":1,"y":2},{"x":3,"y":4}]` {
+		t.Fatalf("wrong query. got=%s", r.URL.RawQuery)
+	}
+	if query.Get("empty") != "[]" || query.Has("nil") {
+		t.Fatalf("wrong query for empty slices. got=%s", r.URL.RawQuery)
+	}
+
+	var actual s
+	if err := form.Unmarshal(r, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("wrong round trip of %s. want=%v, got=%v", r.URL.RawQuery, expected, actual)
+	}
+}
+
+func TestMarshalCanonical(t *testing.T) {
+	t.Parallel()
+	type payment struct {
+		Nonce    string   `form:"nonce"`
+		Amount   float64  `form:"amount,prec=2"`
+		Currency string   `form:"currency"`
+		Memo     string   `form:"memo"`
+		Tags     []string `form:"tags"`
+		Note     string   `form:"note,omitempty"`
+	}
+	type empty struct {
+		Note string `form:"note,omitempty"`
+	}
+
+	tests := []struct {
+		name     string
+		input    interface{}
+		expected string
+	}{
+		{"payment", &payment{Nonce: "n-1", Amount: 12.5, Currency: "EUR", Memo: "rent & bills/May*", Tags: []string{"b", "a~z"}},
+			"amount=12.50&currency=EUR&memo=rent%20%26%20bills%2FMay%2A&nonce=n-1&tags=b&tags=a~z"},
+		{"unicode", &payment{Memo: "café+1", Tags: []string{""}},
+			"amount=0.00&currency=&memo=caf%C3%A9%2B1&nonce=&tags="},
+		{"empty", &empty{}, ""},
+	}
+
+	for _, tt := range tests {
+		actual, err := form.MarshalCanonical(tt.input)
+		if err != nil {
+			t.Fatalf("unexpected error for %s: %s", tt.name, err)
+		}
+		if actual != tt.expected {
+			t.Fatalf("wrong canonical string for %s. want=%s, got=%s", tt.name, tt.expected, actual)
+		}
+	}
+
+	ordered, err := form.NewEncoder().KeyOrder([]string{"tags", "nonce"}).MarshalCanonical(&payment{Nonce: "x", Tags: []string{"t"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if ordered != "amount=0.00&currency=&memo=&nonce=x&tags=t" {
+		t.Fatalf("expected KeyOrder to be ignored. got=%s", ordered)
+	}
+
+	if _, err := form.MarshalCanonical(payment{}); err == nil {
+		t.Fatalf("expected an error for a non-pointer")
+	}
+}
+
+func TestEmptySliceModeMarshal(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Tags []string `form:"tags"`
+		IDs  []int    `form:"ids,sep"`
+		Keep []int    `form:"keep,omitempty"`
+	}
+
+	tests := []struct {
+		value    s
+		encoder  *form.Encoder
+		expected string
+	}{
+		{s{}, form.NewEncoder().EmptySliceMode(form.EmptySliceEmit), ""},
+		{s{Tags: []string{}, IDs: []int{}, Keep: []int{}}, form.NewEncoder().EmptySliceMode(form.EmptySliceEmit), "ids=&tags="},
+		{s{Tags: []string{}, IDs: []int{}}, form.NewEncoder(), ""},
+		{s{Tags: []string{}, IDs: []int{}}, form.NewEncoder().EmptySliceMode(form.EmptySliceOmit), ""},
+		{s{Tags: []string{"a"}, IDs: []int{}}, form.NewEncoder().EmptySliceMode(form.EmptySliceEmit).BracketSlices(), "ids=&tags%5B%5D=a"},
+		{s{Tags: []string{}}, form.NewEncoder().EmptySliceMode(form.EmptySliceEmit).BracketSlices(), "tags%5B%5D="},
+		{s{IDs: []int{}}, form.NewEncoder().EmptySliceMode(form.EmptySliceEmit).EmitEmpty(), "ids=&tags="},
+	}
+	for _, tt := range tests {
+		r, _ := http.NewRequest(http.MethodGet, "/", nil)
+		if err := tt.encoder.Marshal(r, &tt.value); err != nil {
+			t.Fatalf("unexpected marshal error: %s", err)
+		}
+		if r.URL.RawQuery != tt.expected {
+			t.Errorf("wrong query for %#v. expected=%s, got=%s", tt.value, tt.expected, r.URL.RawQuery)
+		}
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, "/", nil)
+	if err := form.NewEncoder().EmptySliceMode(form.EmptySliceEmit).Marshal(r, &s{Tags: []string{}}); err != nil {
+		t.Fatalf("unexpected marshal error: %s", err)
+	}
+	var actual s
+	if err := form.NewDecoder().EmptyValueAsEmptySlice().Unmarshal(r, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	if actual.Tags == nil || len(actual.Tags) != 0 || actual.IDs != nil {
+		t.Fatalf("expected the empty slice to round trip. got=%#v", actual)
+	}
+}
+
+func TestMarshalMultipart(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Title      string                  `form:"title"`
+		Tags       []string                `form:"tags"`
+		Page       int                     `form:"page"`
+		Doc        *multipart.FileHeader   `form:"doc"`
+		Images     []*multipart.FileHeader `form:"images"`
+		Attachment io.Reader               `form:"attachment"`
+		Missing    *multipart.FileHeader   `form:"missing"`
+	}
+
+	// Build file headers the way a server receives them.
+	var upload bytes.Buffer
+	uw := multipart.NewWriter(&upload)
+	doc, _ := uw.CreateFormFile("doc", "report.txt")
+	doc.Write([]byte("quarterly report"))
+	for _, name := range []string{"a.png", "b.png"} {
+		img, _ := uw.CreateFormFile("images", name)
+		img.Write([]byte("image " + name))
+	}
+	uw.Close()
+	received, err := multipart.NewReader(&upload, uw.Boundary()).ReadForm(1 << 20)
+	if err != nil {
+		t.Fatalf("unexpected error reading upload: %s", err)
+	}
+
+	value := s{
+		Title:      "report",
+		Tags:       []string{"q1", "finance"},
+		Page:       2,
+		Doc:        received.File["doc"][0],
+		Images:     received.File["images"],
+		Attachment: strings.NewReader("notes"),
+	}
+	r, _ := http.NewRequest(http.MethodPost, "/upload?lang=en", nil)
+	if err := form.MarshalMultipart(r, &value); err != nil {
+		t.Fatalf("unexpected marshal error: %s", err)
+	}
+	if r.URL.RawQuery != "lang=en" {
+		t.Fatalf("the query should be unchanged. got=%s", r.URL.RawQuery)
+	}
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data; boundary=") {
+		t.Fatalf("wrong content type. got=%s", r.Header.Get("Content-Type"))
+	}
+	body, _ := r.GetBody()
+	data, _ := io.ReadAll(body)
+	if r.ContentLength != int64(len(data)) {
+		t.Fatalf("wrong content length. want=%d, got=%d", len(data), r.ContentLength)
+	}
+
+	type fields struct {
+		Title string   `form:"title"`
+		Tags  []string `form:"tags"`
+		Page  int      `form:"page"`
+		Lang  string   `form:"lang"`
+	}
+	var actual fields
+	cleanup, err := form.NewDecoder().Multipart(1<<20).UnmarshalMultipart(r, &actual)
+	defer cleanup()
+	if err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	expected := fields{Title: "report", Tags: []string{"q1", "finance"}, Page: 2, Lang: "en"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("wrong struct. want=%+v, got=%+v", expected, actual)
+	}
+
+	files := map[string]string{}
+	for key, headers := range r.MultipartForm.File {
+		for _, fh := range headers {
+			f, _ := fh.Open()
+			content, _ := io.ReadAll(f)
+			f.Close()
+			files[key+"/"+fh.Filename] = string(content)
+		}
+	}
+	expectedFiles := map[string]string{
+		"doc/report.txt":        "quarterly report",
+		"images/a.png":          "image a.png",
+		"images/b.png":          "image b.png",
+		"attachment/attachment": "notes",
+	}
+	if !reflect.DeepEqual(files, expectedFiles) {
+		t.Fatalf("wrong files. want=%v, got=%v", expectedFiles, files)
+	}
+}
@@ -0,0 +1,67 @@
+package form
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+)
+
+// parseMatch parses the value key of the match option of the i'th field of the struct type t,
+// which names the form key of another field of t, declared before or after the i'th field.
+// A key that is no field's tag key is left for [Decoder.resolveCondition] to find.
+func parseMatch(t reflect.Type, i int, key string) (*condition, *InvalidTagError) {
+	if key == "" {
+		return nil, &InvalidTagError{
+			Option: "match",
+			Err:    fmt.Errorf("option needs the form key of a field"),
+		}
+	}
+	for j := 0; j < t.NumField(); j++ {
+		if k, _ := parseTag(t.Field(j).Tag.Get("form")); k != key {
+			continue
+		}
+		if j == i {
+			return nil, &InvalidTagError{
+				Option: "match",
+				Err:    fmt.Errorf("struct has no other field with key %q", key),
+			}
+		}
+		return &condition{index: j, key: key}, nil
+	}
+	return &condition{index: -1, key: key}, nil
+}
+
+// checkMatch returns a [ValidationError] if the field f of s does not equal the field named by its match
+// option, both formatted as by [fmt.Sprint]. Nothing is checked when the form has no value for that field's key,
+// so a body may leave out an id given by the URL path.
+func checkMatch(s reflect.Value, f field, form url.Values) error {
+	if missing(form[f.match.key]) {
+		return nil
+	}
+	value := formatIndirect(s.Field(f.index))
+	c := *f.match
+	c.value = value
+	if c.holds(s) {
+		return nil
+	}
+	return &ValidationError{
+		Value:  value,
+		Option: "match",
+		Struct: s.Type().Name(),
+		Field:  f.name,
+		Key:    f.key,
+		Err:    fmt.Errorf("does not match %s=%s", f.match.key, formatIndirect(s.Field(f.match.index))),
+	}
+}
+
+// formatIndirect formats the value v points to as by [fmt.Sprint], or "" for a nil pointer.
+// v is formatted as a [reflect.Value], so the value of an unexported field is formatted too.
+func formatIndirect(v reflect.Value) string {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,48 @@
+package form
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+)
+
+// UnmarshalMulti parses the [*http.Request] form once and unmarshals it into each of targets in order,
+// like [Unmarshal] does for one, so concerns such as pagination and filtering can live in separate structs:
+//
+//	err := form.UnmarshalMulti(r, &pagination, &filter)
+//
+// Every target reads the whole form, so a key may bind fields of several targets.
+// Unmarshalling stops at the first target that fails, whose error is wrapped in a [TargetError].
+func UnmarshalMulti(r *http.Request, targets ...interface{}) error {
+	return defaultDecoder.UnmarshalMulti(r, targets...)
+}
+
+// UnmarshalMulti is like the package level [UnmarshalMulti] with the options of d applied.
+// Validators added with [Decoder.Validator] run on each target.
+func (d *Decoder) UnmarshalMulti(r *http.Request, targets ...interface{}) error {
+	n, err := d.unmarshalTargets(r, targets, nil, nil)
+	if err != nil && n >= 0 {
+		return &TargetError{
+			Index: n,
+			Type:  reflect.TypeOf(targets[n]),
+			Err:   err,
+		}
+	}
+	return err
+}
+
+// A TargetError describes which target of [UnmarshalMulti] failed to unmarshal.
+// Errors from parsing the request's form, which no target is responsible for, are not wrapped.
+type TargetError struct {
+	Index int          // position of the target in the arguments
+	Type  reflect.Type // type of the target
+	Err   error        // error unmarshalling the target
+}
+
+func (e *TargetError) Error() string {
+	return fmt.Sprintf("%s (target %d, %s)", e.Err, e.Index, e.Type)
+}
+
+func (e *TargetError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,52 @@
+package form
+
+import (
+	"mime"
+	"net/http"
+)
+
+// Multipart makes the Decoder also accept requests with a Content-Type of multipart/form-data,
+// parsing them with [http.Request.ParseMultipartForm]. The values of the non-file parts are unmarshalled
+// like a URL encoded form, after which values in the URL query are added as usual.
+// Up to maxMemory bytes of file parts are held in memory and the rest are written to temporary files on disk,
+// which remain until [mime/multipart.Form.RemoveAll] is called. Use [Decoder.UnmarshalMultipart] to get a function
+// that removes them. A body that cannot be parsed returns a [ParseError].
+func (d *Decoder) Multipart(maxMemory int64) *Decoder {
+	d.multipart = true
+	d.maxMemory = maxMemory
+	return d
+}
+
+// UnmarshalMultipart behaves like [Decoder.Unmarshal] and also returns a function that removes the temporary
+// files written while parsing a multipart body. The function is never nil, even when an error is returned
+// or the request has no multipart body, so it can be deferred straight away:
+//
+//	cleanup, err := d.UnmarshalMultipart(r, &upload)
+//	defer cleanup()
+//
+// The [http.Server] also removes these files once the handler returns, so cleanup is only needed to free
+// the disk space sooner, or when the request is handled outside of a server.
+func (d *Decoder) UnmarshalMultipart(r *http.Request, i interface{}) (cleanup func() error, err error) {
+	err = d.unmarshal(r, i, nil, nil)
+	return func() error {
+		if r.MultipartForm == nil {
+			return nil
+		}
+		return r.MultipartForm.RemoveAll()
+	}, err
+}
+
+// isMultipart reports whether r has a multipart/form-data body.
+func isMultipart(r *http.Request) bool {
+	ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return ct == "multipart/form-data"
+}
+
+// parseMultipart parses the multipart body of r into r.Form, holding up to maxMemory bytes of files in memory.
+func parseMultipart(r *http.Request, maxMemory int64) error {
+	err := r.ParseMultipartForm(maxMemory)
+	if err != nil {
+		return &ParseError{Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package form
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"sort"
+)
+
+var (
+	fileHeaderType = reflect.TypeOf((*multipart.FileHeader)(nil))
+	readerType     = reflect.TypeOf((*io.Reader)(nil)).Elem()
+)
+
+// A filePart is a field marshalled as a file part of a multipart body.
+type filePart struct {
+	key    string
+	value  reflect.Value
+	field  field
+	parent reflect.Value
+}
+
+// isFileType reports whether fields of type t are marshalled as file parts of a multipart body:
+// a [*multipart.FileHeader], a slice of them, or an [io.Reader].
+func isFileType(t reflect.Type) bool {
+	return t == fileHeaderType || (t.Kind() == reflect.Slice && t.Elem() == fileHeaderType) || t.Implements(readerType)
+}
+
+// MarshalMultipart encodes the fields with the "form" struct tag into a multipart/form-data body on the request,
+// like [Marshal] does into the URL query, which is left unchanged. It behaves like [Encoder.MarshalMultipart]
+// with the default options.
+func MarshalMultipart(r *http.Request, i interface{}) error {
+	return defaultEncoder.MarshalMultipart(r, i)
+}
+
+// MarshalMultipart encodes the fields with the "form" struct tag into a multipart/form-data body on the request,
+// replacing its body and setting its Content-Type, with the part boundary, and ContentLength.
+// Each value is written as a form part, in the order of [Encoder.Marshal]'s query. Fields of type
+// [*multipart.FileHeader], or a slice of them, are written as file parts with the name and content type of each
+// header, and fields holding an [io.Reader] are read into a file part named after the reader's Name method,
+// as for an [os.File], or the field's key otherwise. Nil file fields are skipped.
+// The body is held in memory, so it can be sent again by redirects through [http.Request.GetBody].
+func (e *Encoder) MarshalMultipart(r *http.Request, i interface{}) error {
+	var files []filePart
+	ec := *e
+	ec.fileParts = &files
+	form, keys, err := ec.encode(i)
+	if err != nil {
+		return err
+	}
+	if e.keyOrder != nil {
+		keys = orderKeys(keys, e.keyOrder)
+	} else if !e.preserveOrder {
+		sort.Strings(keys)
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, k := range keys {
+		for _, v := range form[k] {
+			if err := mw.WriteField(k, v); err != nil {
+				return err
+			}
+		}
+	}
+	for _, fp := range files {
+		if err := writeFilePart(mw, fp); err != nil {
+			return err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return err
+	}
+
+	data := body.Bytes()
+	r.Body = io.NopCloser(bytes.NewReader(data))
+	r.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+	r.ContentLength = int64(len(data))
+	if r.Header == nil {
+		r.Header = make(http.Header)
+	}
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	return nil
+}
+
+// writeFilePart writes the file field fp to mw, returning a [MarshalTypeError] if its content cannot be read.
+func writeFilePart(mw *multipart.Writer, fp filePart) error {
+	if !fp.value.CanInterface() {
+		return fp.wrap(errUnexported)
+	}
+	var headers []*multipart.FileHeader
+	switch v := fp.value.Interface().(type) {
+	case *multipart.FileHeader:
+		headers = []*multipart.FileHeader{v}
+	case []*multipart.FileHeader:
+		headers = v
+	case io.Reader:
+		name := fp.key
+		if n, ok := v.(interface{ Name() string }); ok {
+			name = filepath.Base(n.Name())
+		}
+		part, err := mw.CreateFormFile(fp.key, name)
+		if err == nil {
+			_, err = io.Copy(part, v)
+		}
+		return fp.wrap(err)
+	}
+
+	for _, fh := range headers {
+		if fh == nil {
+			continue
+		}
+		h := make(map[string][]string, len(fh.Header))
+		for k, v := range fh.Header {
+			h[k] = v
+		}
+		h["Content-Disposition"] = []string{fmt.Sprintf(`form-data; name=%q; filename=%q`, fp.key, fh.Filename)}
+		if len(h["Content-Type"]) == 0 {
+			h["Content-Type"] = []string{"application/octet-stream"}
+		}
+		part, err := mw.CreatePart(h)
+		if err != nil {
+			return fp.wrap(err)
+		}
+		f, err := fh.Open()
+		if err != nil {
+			return fp.wrap(err)
+		}
+		_, err = io.Copy(part, f)
+		f.Close()
+		if err != nil {
+			return fp.wrap(err)
+		}
+	}
+	return nil
+}
+
+// wrap returns err, if not nil, as a [MarshalTypeError] for the file field.
+func (fp filePart) wrap(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &MarshalTypeError{
+		Type:   fp.value.Type(),
+		Value:  marshalValue(fp.value),
+		Struct: fp.parent.Type().Name(),
+		Field:  fp.field.name,
+		Err:    err,
+	}
+}
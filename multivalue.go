@@ -0,0 +1,44 @@
+package form
+
+// MultiValue controls how fields that hold a single value are unmarshalled when their key has several values.
+type MultiValue int
+
+const (
+	// MultiValueError returns a [UnmarshalTypeError] when a single value field receives several values.
+	MultiValueError MultiValue = iota
+	// MultiValueFirst unmarshals the first of the values and ignores the others.
+	MultiValueFirst
+	// MultiValueLast unmarshals the last of the values and ignores the others.
+	MultiValueLast
+)
+
+// MultiValueMode sets how fields that are not slices or arrays are unmarshalled when their key is
+// repeated, such as page=1&page=2. The default is [MultiValueError]. [MultiValueFirst] and
+// [MultiValueLast] accept parameters that an upstream client harmlessly duplicates.
+//
+// MultiValueLast is the recommended mode for HTML forms that pair a checkbox with a hidden input of the
+// same name holding its unchecked value, as the browser then submits agree=0&agree=1 when it is checked.
+// The last tag option selects the last value for a single field whatever the mode.
+func (d *Decoder) MultiValueMode(m MultiValue) *Decoder {
+	d.multiValue = m
+	return d
+}
+
+// singleValue returns the value of values selected by the Decoder's MultiValue mode, or [MultiValueLast]
+// for a field with the last option, or values itself if it does not hold several values or the mode is [MultiValueError].
+func (d *Decoder) singleValue(fld field, values []string) []string {
+	if len(values) <= 1 {
+		return values
+	}
+	mode := d.multiValue
+	if fld.opts.Has("last") {
+		mode = MultiValueLast
+	}
+	switch mode {
+	case MultiValueFirst:
+		return values[:1]
+	case MultiValueLast:
+		return values[len(values)-1:]
+	}
+	return values
+}
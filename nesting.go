@@ -0,0 +1,54 @@
+package form
+
+import (
+	"strings"
+)
+
+// Nesting controls how the keys of map fields are written in the form.
+type Nesting int
+
+const (
+	// NestingBracket writes each level of a map in brackets, such as config[db][host].
+	NestingBracket Nesting = iota
+	// NestingDot separates each level of a map with a dot, such as config.db.host.
+	NestingDot
+)
+
+// NestingMode sets how the Decoder reads the keys of map fields. The default is [NestingBracket].
+// With [NestingDot] a field tagged `form:"meta"` binds meta.color=red, and keys in bracket notation
+// are not read. Indexed slice keys such as items[0] always use brackets.
+func (d *Decoder) NestingMode(m Nesting) *Decoder {
+	d.nesting = m
+	return d
+}
+
+// NestingMode sets how the Encoder writes the keys of map and [net/url.Values] fields,
+// matching [Decoder.NestingMode]. The default is [NestingBracket].
+func (e *Encoder) NestingMode(m Nesting) *Encoder {
+	e.nesting = m
+	return e
+}
+
+// name returns the delimiter used in error messages.
+func (m Nesting) name() string {
+	if m == NestingDot {
+		return "dots"
+	}
+	return "brackets"
+}
+
+// nestedKey returns the key of the segment seg nested under key.
+func (m Nesting) nestedKey(key, seg string) string {
+	if m == NestingDot {
+		return key + "." + seg
+	}
+	return key + "[" + seg + "]"
+}
+
+// parsePath splits the nested part of a key, such as "[db][host]" or ".db.host", into its segments.
+func (m Nesting) parsePath(s string) ([]string, error) {
+	if m == NestingDot {
+		return strings.Split(s[1:], "."), nil
+	}
+	return parseKeyPath(s)
+}
@@ -0,0 +1,36 @@
+package form
+
+import (
+	"database/sql"
+	"reflect"
+	"strings"
+)
+
+var scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+
+// isNullType reports whether t is one of the nullable types of database/sql, such as [database/sql.NullString]
+// or [database/sql.Null], which hold their value in the first field and whether it is set in a Valid field.
+func isNullType(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && t.PkgPath() == "database/sql" && strings.HasPrefix(t.Name(), "Null") &&
+		t.NumField() == 2 && t.Field(1).Name == "Valid" && t.Field(1).Type.Kind() == reflect.Bool
+}
+
+// nullValueType returns the type of the value held by t if it is a nullable database/sql type, and t otherwise.
+func nullValueType(t reflect.Type) reflect.Type {
+	if isNullType(t) {
+		return t.Field(0).Type
+	}
+	return t
+}
+
+// parseNull parses value into the value of the nullable database/sql field f and marks it valid.
+func (d *Decoder) parseNull(f reflect.Value, fld field, value string) *UnmarshalTypeError {
+	v := reflect.New(f.Type()).Elem()
+	if err := d.parseFormValue(v.Field(0), fld, value); err != nil {
+		err.Type = f.Type()
+		return err
+	}
+	v.Field(1).SetBool(true)
+	f.Set(v)
+	return nil
+}
@@ -0,0 +1,71 @@
+package form
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Overflow controls how numbers too large or small for their field's type are unmarshalled.
+type Overflow int
+
+const (
+	// OverflowError returns a [UnmarshalTypeError] for numbers that overflow their field's type.
+	OverflowError Overflow = iota
+	// OverflowClamp sets numbers that overflow their field's type to the nearest value the type can hold.
+	// Signed integers clamp to [-2^(n-1), 2^(n-1)-1] and unsigned integers to [0, 2^n-1], where n is the type's size in bits,
+	// so a negative integer sets an unsigned field to 0.
+	// Floats clamp to ±[math.MaxFloat32] or ±[math.MaxFloat64].
+	OverflowClamp
+)
+
+// OverflowMode sets how numbers that overflow int, uint and float fields are unmarshalled.
+// The default is [OverflowError]. With [OverflowClamp] negative integers such as "-1" set an unsigned
+// field to 0, its minimum. Values that are not numbers are always an error.
+func (d *Decoder) OverflowMode(m Overflow) *Decoder {
+	d.overflow = m
+	return d
+}
+
+// clampRange reports whether err is a [strconv.ErrRange] that should be clamped rather than returned.
+func (d *Decoder) clampRange(err error) bool {
+	return d.overflow == OverflowClamp && errors.Is(err, strconv.ErrRange)
+}
+
+// clampInt returns v clamped to the range of a signed integer with the given number of bits.
+func clampInt(v int64, bits int) int64 {
+	max := int64(1)<<(bits-1) - 1
+	min := -max - 1
+	if v > max {
+		return max
+	}
+	if v < min {
+		return min
+	}
+	return v
+}
+
+// clampNegative reports whether value is a negative integer that an unsigned field clamps to 0.
+func (d *Decoder) clampNegative(value string, underscores bool) bool {
+	if d.overflow != OverflowClamp || !strings.HasPrefix(value, "-") {
+		return false
+	}
+	_, err := parseInt(value, underscores)
+	return err == nil || errors.Is(err, strconv.ErrRange)
+}
+
+// clampUint returns the maximum value of an unsigned integer with the given number of bits,
+// the clamped value of a positive number too large for it.
+func clampUint(bits int) uint64 {
+	return math.MaxUint64 >> (64 - bits)
+}
+
+// clampFloat returns v clamped to the finite range of a float with the given number of bits.
+func clampFloat(v float64, bits int) float64 {
+	max := math.MaxFloat64
+	if bits == 32 {
+		max = math.MaxFloat32
+	}
+	return math.Max(-max, math.Min(v, max))
+}
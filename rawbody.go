@@ -0,0 +1,54 @@
+package form
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"reflect"
+)
+
+// readBody reads all of body, up to maxBodySize bytes.
+func readBody(body io.Reader) ([]byte, error) {
+	b, err := io.ReadAll(io.LimitReader(body, maxBodySize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > maxBodySize {
+		return nil, ErrBodyTooLarge
+	}
+	return b, nil
+}
+
+// readRawBody reads the body of r for a rawbody field and replaces it with a reader over the bytes read,
+// so the form can still be parsed from it. It returns nil if r has no body.
+func readRawBody(r *http.Request) ([]byte, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, nil
+	}
+	body, err := readBody(r.Body)
+	r.Body.Close()
+	if err != nil {
+		return nil, &ParseError{Err: err}
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// setRawBody stores body in the rawbody field f, which is a byte slice or string.
+func setRawBody(f reflect.Value, body []byte) {
+	if f.Kind() == reflect.String {
+		f.SetString(string(body))
+		return
+	}
+	f.SetBytes(body)
+}
+
+// rawBodyField returns the field of fields with the rawbody option, if any.
+func rawBodyField(fields []field) (field, bool) {
+	for _, f := range fields {
+		if f.rawBody {
+			return f, true
+		}
+	}
+	return field{}, false
+}
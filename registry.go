@@ -0,0 +1,159 @@
+package form
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// A DecodeFunc parses a single form value into a value of the type it is registered for.
+// The returned value must be assignable to that type.
+type DecodeFunc func(value string) (interface{}, error)
+
+// A EncodeFunc formats a value of the type it is registered for as a single form value.
+type EncodeFunc func(v interface{}) (string, error)
+
+// registry holds the functions registered with [RegisterType] for every Decoder and Encoder.
+var registry = struct {
+	sync.RWMutex
+	decoders map[reflect.Type]DecodeFunc
+	encoders map[reflect.Type]EncodeFunc
+}{
+	decoders: make(map[reflect.Type]DecodeFunc),
+	encoders: make(map[reflect.Type]EncodeFunc),
+}
+
+// RegisterType registers functions used by every [Decoder] and [Encoder], including [Unmarshal] and [Marshal],
+// to unmarshal and marshal values of type t. Either function may be nil to only register one direction.
+// This is intended for application wide types, such as a UUID, registered once at init:
+//
+//	form.RegisterType(reflect.TypeOf(uuid.UUID{}),
+//		func(value string) (interface{}, error) { return uuid.Parse(value) },
+//		func(v interface{}) (string, error) { return v.(uuid.UUID).String(), nil },
+//	)
+//
+// A registered type is treated as a single value, even if it is a slice or array, and each element of a slice
+// or array of the type is parsed separately. Functions registered on a Decoder or Encoder take precedence over
+// those registered with RegisterType, which in turn take precedence over [Unmarshaler], [sql.Scanner] and the
+// built-in handling of the type's kind.
+//
+// RegisterType is safe to call concurrently with unmarshalling and marshalling.
+func RegisterType(t reflect.Type, dec DecodeFunc, enc EncodeFunc) {
+	registry.Lock()
+	defer registry.Unlock()
+	if dec != nil {
+		registry.decoders[t] = dec
+	}
+	if enc != nil {
+		registry.encoders[t] = enc
+	}
+}
+
+// RegisterDecoder registers a function the Decoder uses to unmarshal values of type t.
+// It takes precedence over functions registered with [RegisterType].
+func (d *Decoder) RegisterDecoder(t reflect.Type, dec DecodeFunc) *Decoder {
+	if d.decoders == nil {
+		d.decoders = make(map[reflect.Type]DecodeFunc)
+	}
+	d.decoders[t] = dec
+	delete(d.ctxDecoders, t)
+	return d
+}
+
+// RegisterEncoder registers a function the Encoder uses to marshal values of type t.
+// It takes precedence over functions registered with [RegisterType].
+func (e *Encoder) RegisterEncoder(t reflect.Type, enc EncodeFunc) *Encoder {
+	if e.encoders == nil {
+		e.encoders = make(map[reflect.Type]EncodeFunc)
+	}
+	e.encoders[t] = enc
+	return e
+}
+
+// decodeFunc returns the function registered to unmarshal values of type t, or nil if there is none.
+func (d *Decoder) decodeFunc(t reflect.Type) DecodeFunc {
+	if dec, ok := d.decoders[t]; ok {
+		return dec
+	}
+	if dec := d.contextDecodeFunc(t); dec != nil {
+		return dec
+	}
+	registry.RLock()
+	defer registry.RUnlock()
+	return registry.decoders[t]
+}
+
+// encodeFunc returns the function registered to marshal values of type t, or nil if there is none.
+func (e *Encoder) encodeFunc(t reflect.Type) EncodeFunc {
+	if enc, ok := e.encoders[t]; ok {
+		return enc
+	}
+	registry.RLock()
+	defer registry.RUnlock()
+	return registry.encoders[t]
+}
+
+// decodeRegistered parses value into f with dec.
+func decodeRegistered(f reflect.Value, dec DecodeFunc, value string) *UnmarshalTypeError {
+	v, err := dec(value)
+	if err != nil {
+		return &UnmarshalTypeError{
+			Value: value,
+			Type:  f.Type(),
+			Err:   err,
+		}
+	}
+
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() || !rv.Type().AssignableTo(f.Type()) {
+		return &UnmarshalTypeError{
+			Value: value,
+			Type:  f.Type(),
+			Err:   fmt.Errorf("registered decoder returned %T, not %s", v, f.Type()),
+		}
+	}
+	f.Set(rv)
+	return nil
+}
+
+// RegisterNormalizer registers a function the Decoder calls on every value of type t it unmarshals,
+// after the value is parsed and set, to put it in a canonical form, such as lowercasing an email
+// address or rounding a float:
+//
+//	d.RegisterNormalizer(reflect.TypeOf(Email("")), func(v reflect.Value) {
+//		v.SetString(strings.ToLower(v.String()))
+//	})
+//
+// The function receives the settable field, or each element of a slice or array field and the value
+// of a non-nil pointer field, whose type is t. Normalizers run before the field's tag validation options,
+// such as minlen and pattern, and before the functions added with [Decoder.Validator],
+// so those check the normalized value. Fields whose key is absent from the form are not normalized.
+func (d *Decoder) RegisterNormalizer(t reflect.Type, fn func(reflect.Value)) *Decoder {
+	if d.normalizers == nil {
+		d.normalizers = make(map[reflect.Type]func(reflect.Value))
+	}
+	d.normalizers[t] = fn
+	return d
+}
+
+// normalize calls the normalizer registered for the type of v, or for the elements of v if it is a slice or
+// array, or for the value v points to.
+func (d *Decoder) normalize(v reflect.Value) {
+	if len(d.normalizers) == 0 {
+		return
+	}
+	if fn, ok := d.normalizers[v.Type()]; ok {
+		fn(v)
+		return
+	}
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			d.normalize(v.Index(i))
+		}
+	case reflect.Pointer:
+		if !v.IsNil() {
+			d.normalize(v.Elem())
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package form
+
+import (
+	"net/http"
+	"reflect"
+	"time"
+)
+
+// A Report describes the outcome of [Decoder.UnmarshalReport].
+type Report struct {
+	Skipped []SkippedField // fields that failed to unmarshal and were left at their zero value, in struct order
+
+	// Duration is how long binding took, from reading the request's form through the last validator.
+	Duration time.Duration
+	// DecoderDurations holds, by form key, the time spent binding each field whose type,
+	// or element type, has a function registered with [Decoder.RegisterDecoder] or [RegisterType],
+	// to find expensive custom decoders. Nested fields use their full key, such as "retry.timeout",
+	// and the fields of an element of a slice of structs are keyed under its index, as in "items[0].timeout".
+	// It is nil if no field has one.
+	DecoderDurations map[string]time.Duration
+}
+
+// A SkippedField is a field that failed to unmarshal while [Decoder.SkipErrors] was set.
+type SkippedField struct {
+	Field string // name of the Go struct field
+	Key   string // form key the field is bound to
+	Err   error  // error that would otherwise have been returned, such as a [UnmarshalTypeError]
+}
+
+// SkipErrors makes the Decoder leave a field that fails to unmarshal or validate at its zero value
+// and continue with the next field, rather than returning the error.
+// Use [Decoder.UnmarshalReport] to find out which fields were skipped and why.
+// Errors not caused by a single field's value, such as a [ParseError], an [InvalidTagError] or
+// an error from a [Decoder.Validator], are still returned.
+func (d *Decoder) SkipErrors() *Decoder {
+	d.skipErrors = true
+	return d
+}
+
+// UnmarshalReport behaves like [Decoder.Unmarshal] and also returns a Report of the fields skipped
+// because of [Decoder.SkipErrors] and of how long binding took. The Report is nil if an error is returned.
+// Timings are only taken for a Report, so [Decoder.Unmarshal] does not pay for them.
+func (d *Decoder) UnmarshalReport(r *http.Request, i interface{}) (*Report, error) {
+	report := &Report{}
+	start := time.Now()
+	err := d.unmarshal(r, i, report, nil)
+	if err != nil {
+		return nil, err
+	}
+	report.Duration = time.Since(start)
+	return report, nil
+}
+
+// timeDecoder records in the report of ds the time since start spent binding the field f of type t,
+// under the key of f, if a decode function is registered for t or its element type.
+func (d *Decoder) timeDecoder(ds *decodeState, f field, t reflect.Type, start time.Time) {
+	report := ds.report
+	if d.decodeFunc(t) == nil && d.decodeFunc(baseType(elemType(t))) == nil {
+		return
+	}
+	if report.DecoderDurations == nil {
+		report.DecoderDurations = make(map[string]time.Duration)
+	}
+	report.DecoderDurations[ds.keyPrefix+f.key] += time.Since(start)
+}
+
+// skipField resets the field f of the struct s, and its count field, to their zero values
+// and records err in report if it is not nil.
+func skipField(s reflect.Value, f field, err error, report *Report) {
+	fv := s.Field(f.index)
+	fv.Set(reflect.Zero(fv.Type()))
+	if f.count >= 0 {
+		count := s.Field(f.count)
+		count.Set(reflect.Zero(count.Type()))
+	}
+	if report != nil {
+		report.Skipped = append(report.Skipped, SkippedField{
+			Field: f.name,
+			Key:   errorKey(f, err),
+			Err:   err,
+		})
+	}
+}
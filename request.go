@@ -0,0 +1,66 @@
+package form
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// requestAttrs are the tag options that bind a field to an attribute of the request rather than the form.
+var requestAttrs = []string{"method", "path", "segment", "pathvalue"}
+
+// requestAttr returns the value of the attribute name of r, which is one of requestAttrs, and whether
+// r has it. arg is the value of the segment and pathvalue options: a segment's index or a path value's name.
+func requestAttr(r *http.Request, name, arg string) (string, bool) {
+	switch name {
+	case "method":
+		return r.Method, true
+	case "path":
+		if r.URL == nil {
+			return "", true
+		}
+		return r.URL.Path, true
+	case "segment":
+		if r.URL == nil {
+			return "", false
+		}
+		i, _ := strconv.Atoi(arg)
+		segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		if i >= len(segments) || segments[i] == "" {
+			return "", false
+		}
+		return segments[i], true
+	case "pathvalue":
+		v := r.PathValue(arg)
+		return v, v != ""
+	}
+	return "", false
+}
+
+// setRequestAttrs stores the request attributes of r in the fields of s bound to them.
+// The values of the segment and pathvalue options are parsed like form values, so they may bind any
+// single value type, and fields are left unchanged when r has no such segment or path value.
+func (d *Decoder) setRequestAttrs(r *http.Request, s reflect.Value, fields []field) error {
+	for _, f := range fields {
+		if f.request == "" {
+			continue
+		}
+		v, ok := requestAttr(r, f.request, f.requestArg)
+		ps := f.parentOf(s)
+		if f.request == "method" || f.request == "path" {
+			ps.Field(f.index).SetString(v)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if err := d.parseFormValue(ps.Field(f.index), f, v); err != nil {
+			err.Struct = ps.Type().Name()
+			err.Field = f.name
+			err.Key = f.key
+			return f.withMessage(err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package form
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// A condition is the parsed value of a requiredif or group tag option, such as payment=card.
+type condition struct {
+	index int    // index of the field the condition depends on, or -1 until it is resolved by a Decoder
+	key   string // form key of that field
+	value string // value the field must have for the condition to hold
+}
+
+// parseCondition parses the value cond of the requiredif or group option of the i'th field of the struct type t.
+// The condition must name the form key of a field declared before the i'th field,
+// so that field is already unmarshalled when the condition is evaluated.
+// A key that is no field's tag key is left for [Decoder.resolveCondition] to find.
+func parseCondition(t reflect.Type, i int, option, cond string) (*condition, *InvalidTagError) {
+	key, value, ok := strings.Cut(cond, "=")
+	if !ok || key == "" {
+		return nil, &InvalidTagError{
+			Option: option,
+			Err:    fmt.Errorf("condition %q is not of the form key=value", cond),
+		}
+	}
+
+	for j := 0; j < t.NumField(); j++ {
+		if k, _ := parseTag(t.Field(j).Tag.Get("form")); k != key {
+			continue
+		}
+		if j > i {
+			return nil, &InvalidTagError{
+				Option: option,
+				Err:    fmt.Errorf("field %s with key %q must be declared before the field that depends on it", t.Field(j).Name, key),
+			}
+		}
+		if j == i {
+			return nil, &InvalidTagError{
+				Option: option,
+				Err:    fmt.Errorf("struct has no other field with key %q", key),
+			}
+		}
+		return &condition{index: j, key: key, value: value}, nil
+	}
+	return &condition{index: -1, key: key, value: value}, nil
+}
+
+// resolveConditions returns f with its match, requiredif and group conditions resolved by
+// [Decoder.resolveCondition] among the fields of its struct s.
+func (d *Decoder) resolveConditions(s reflect.Value, fields []field, f field) (field, error) {
+	conds := []struct {
+		option string
+		c      **condition
+	}{{"match", &f.match}, {"requiredif", &f.requiredIf}, {"group", &f.group}}
+	for _, cond := range conds {
+		if *cond.c == nil {
+			continue
+		}
+		c, err := d.resolveCondition(s, fields, f, cond.option, *cond.c)
+		if err != nil {
+			return f, err
+		}
+		*cond.c = c
+	}
+	return f, nil
+}
+
+// resolveCondition returns the condition c of the option of the field f of the struct s, naming the field
+// it depends on by the key d binds it from, among the fields of fields declared in the same struct as f.
+// A key that is no field's tag key is looked up among the keys given by [Decoder.KeyFunc],
+// [Decoder.FallbackTag] and [Decoder.UntaggedFieldNames], and a [InvalidTagError] is returned if no field has it.
+func (d *Decoder) resolveCondition(s reflect.Value, fields []field, f field, option string, c *condition) (*condition, error) {
+	if c.index >= 0 && d.keyFunc == nil && d.fallbackTag == "" && !d.untaggedNames {
+		return c, nil
+	}
+	for _, g := range fields {
+		if g.index == f.index || !slices.Equal(g.parent, f.parent) {
+			continue
+		}
+		key := d.fieldKey(s, g)
+		switch {
+		case c.index >= 0 && g.index == c.index:
+			if key == "" {
+				return c, nil
+			}
+			return &condition{index: g.index, key: key, value: c.value}, nil
+		case c.index < 0 && key == c.key:
+			if option != "match" && g.index > f.index {
+				return nil, &InvalidTagError{
+					Option: option,
+					Struct: s.Type().Name(),
+					Field:  f.name,
+					Err:    fmt.Errorf("field %s with key %q must be declared before the field that depends on it", g.name, key),
+				}
+			}
+			return &condition{index: g.index, key: key, value: c.value}, nil
+		}
+	}
+	if c.index >= 0 {
+		return c, nil
+	}
+	return nil, &InvalidTagError{
+		Option: option,
+		Struct: s.Type().Name(),
+		Field:  f.name,
+		Err:    fmt.Errorf("struct has no other field with key %q", c.key),
+	}
+}
+
+// holds reports whether the field of s the condition depends on has the condition's value,
+// formatted as by [fmt.Sprint]. A nil pointer has the value "".
+func (c *condition) holds(s reflect.Value) bool {
+	return formatIndirect(s.Field(c.index)) == c.value
+}
+
+// missing reports whether values holds no value other than empty strings,
+// as sent by an HTML form for an input left blank.
+func missing(values []string) bool {
+	for _, v := range values {
+		if v != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// checkRequired returns a [MissingFieldError] if f is required in the struct s, either always
+// with the required option or because the condition of its requiredif option holds.
+func (f field) checkRequired(s reflect.Value) error {
+	switch {
+	case f.required:
+		return &MissingFieldError{
+			Option: "required",
+			Struct: s.Type().Name(),
+			Field:  f.name,
+			Key:    f.key,
+		}
+	case f.requiredIf != nil && f.requiredIf.holds(s):
+		return &MissingFieldError{
+			Option:    "requiredif",
+			Condition: f.requiredIf.key + "=" + f.requiredIf.value,
+			Struct:    s.Type().Name(),
+			Field:     f.name,
+			Key:       f.key,
+		}
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package form
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// Sources a field can be restricted to with the "in" tag option, or read from in order with the "source" option.
+// The header source is only available to the "source" option.
+const (
+	sourceBody   = "body"
+	sourceQuery  = "query"
+	sourceHeader = "header"
+)
+
+// requestSources returns the decode states of the body, query string and header sources of r, sharing the
+// report and record of ds. body holds the values parsed from the request's body. The header source holds the
+// header values of r under their canonical keys.
+func requestSources(r *http.Request, body url.Values, ds *decodeState) map[string]*decodeState {
+	var query url.Values
+	if r.URL != nil {
+		query = r.URL.Query()
+	}
+	return map[string]*decodeState{
+		sourceBody:   {form: body, report: ds.report, record: ds.record},
+		sourceQuery:  {form: query, report: ds.report, record: ds.record},
+		sourceHeader: {form: url.Values(r.Header), report: ds.report, record: ds.record},
+	}
+}
+
+// source returns the decode state f reads its values from: that of the source named by its "in"
+// option, the first source of its "source" option holding its key, or ds itself if f has neither
+// or the values were not read from a request. If no source of the "source" option holds the key,
+// the first source is returned so that the field is treated as absent.
+func (ds *decodeState) source(f field) *decodeState {
+	if ds.sources == nil {
+		return ds
+	}
+	if f.sources != nil {
+		for _, name := range f.sources {
+			if src := ds.sources[name].lookup(name, f.key); src != nil {
+				return src
+			}
+		}
+		return ds.sources[f.sources[0]]
+	}
+	if f.in == "" {
+		return ds
+	}
+	return ds.sources[f.in]
+}
+
+// lookup returns the decode state of the source name if it holds key, or nil. Header keys are matched
+// case insensitively, so the values are returned in a state of their own under key.
+func (ds *decodeState) lookup(name, key string) *decodeState {
+	if name != sourceHeader {
+		if len(ds.form[key]) == 0 && len(ds.nestedKeys(key)) == 0 {
+			return nil
+		}
+		return ds
+	}
+	values := http.Header(ds.form).Values(key)
+	if len(values) == 0 {
+		return nil
+	}
+	return &decodeState{form: url.Values{key: values}, report: ds.report, record: ds.record}
+}
@@ -0,0 +1,179 @@
+package form
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// isStructSlice reports whether t, after any pointer indirections, is a slice or array whose elements,
+// or the values they point to, are structs with tagged fields of their own.
+func isStructSlice(t reflect.Type) bool {
+	t = baseType(t)
+	return (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) && hasTaggedFields(baseType(t.Elem()))
+}
+
+// elementForm holds the values of one element of a slice or array of structs.
+type elementForm struct {
+	values url.Values        // values keyed by the keys of the element's fields, such as name
+	sent   map[string]string // keys of values as sent by the client, such as items[0][name]
+}
+
+// structSliceForms groups the indexed keys nested under key, such as items[0].name or items[0][name],
+// into a form per index holding the keys of the element's fields, such as name.
+// Deeper levels within an element use the keys of the element's own fields, such as items[0].address.city.
+func structSliceForms(ds *decodeState, key string) (map[int]*elementForm, *UnmarshalTypeError) {
+	forms := make(map[int]*elementForm)
+	for _, k := range ds.nestedKeys(key) {
+		rest := k[len(key):]
+		end := strings.IndexByte(rest, ']')
+		if end < 0 {
+			return nil, &UnmarshalTypeError{Value: k, Err: fmt.Errorf("mismatched brackets")}
+		}
+		index, err := parseIndex(rest[:end+1])
+		if err != nil {
+			return nil, &UnmarshalTypeError{Value: k, Err: err}
+		}
+
+		sub := rest[end+1:]
+		switch {
+		case strings.HasPrefix(sub, "."):
+			sub = sub[1:]
+		case strings.HasPrefix(sub, "["):
+			close := strings.IndexByte(sub, ']')
+			if close < 0 {
+				return nil, &UnmarshalTypeError{Value: k, Err: fmt.Errorf("mismatched brackets")}
+			}
+			sub = sub[1:close] + sub[close+1:]
+		default:
+			return nil, &UnmarshalTypeError{Value: k, Err: fmt.Errorf("key does not name a field after index %d", index)}
+		}
+		if sub == "" {
+			return nil, &UnmarshalTypeError{Value: k, Err: fmt.Errorf("key does not name a field after index %d", index)}
+		}
+
+		ef := forms[index]
+		if ef == nil {
+			ef = &elementForm{values: make(url.Values), sent: make(map[string]string)}
+			forms[index] = ef
+		}
+		ef.values[sub] = append(ef.values[sub], ds.form[k]...)
+		if _, ok := ef.sent[sub]; !ok {
+			ef.sent[sub] = k
+		}
+	}
+	return forms, nil
+}
+
+// parseStructSlice unmarshals the indexed keys nested under the key of fld into the slice or array of structs f,
+// which may be behind pointers that are allocated when any key is present. Each element's tagged fields are
+// unmarshalled from the keys of its index, as if they were the fields of a struct of their own.
+// Slices grow to fit the largest index, while indices beyond the length of an array are an error.
+// It returns the number of elements that were bound, which is zero if no key is present.
+// Errors of an element are reported as errors of the field fld of the struct named structName.
+func (d *Decoder) parseStructSlice(structName string, f reflect.Value, fld field, ds *decodeState) (int, error) {
+	forms, typeErr := structSliceForms(ds, fld.key)
+	if typeErr != nil {
+		typeErr.Type = f.Type()
+		return 0, typeErr
+	}
+	if len(forms) == 0 {
+		return 0, nil
+	}
+
+	indices := make([]int, 0, len(forms))
+	for index := range forms {
+		indices = append(indices, index)
+	}
+	sort.Ints(indices)
+
+	t := baseType(f.Type())
+	length := 0
+	for _, index := range indices {
+		if t.Kind() == reflect.Array && index >= t.Len() {
+			return 0, &UnmarshalTypeError{
+				Value: fmt.Sprintf("%s[%d]", fld.key, index),
+				Type:  f.Type(),
+				Err:   fmt.Errorf("index %d out of range for %s", index, t),
+			}
+		}
+		length = max(length, index+1)
+	}
+	if err := d.checkSliceLen(f.Type(), fmt.Sprintf("%s[%d]", fld.key, length-1), length); err != nil {
+		return 0, err
+	}
+	if d.sparseIndex == SparseError && length != len(forms) {
+		return 0, &UnmarshalTypeError{
+			Value: fld.key,
+			Type:  f.Type(),
+			Err:   fmt.Errorf("indices are not contiguous from 0 to %d", length-1),
+		}
+	}
+
+	var s reflect.Value
+	if t.Kind() == reflect.Slice {
+		s = reflect.MakeSlice(t, length, length)
+	} else {
+		s = reflect.New(t).Elem()
+	}
+	for _, index := range indices {
+		elem := s.Index(index)
+		for elem.Kind() == reflect.Pointer {
+			elem.Set(reflect.New(elem.Type().Elem()))
+			elem = elem.Elem()
+		}
+		// Errors of the element are returned rather than skipped, so SkipErrors skips the whole field
+		// and records the error under the key of the element's field.
+		dc := *d
+		dc.skipErrors = false
+		err := dc.unmarshalFields(elem, &decodeState{
+			form:      forms[index].values,
+			report:    ds.report,
+			record:    ds.record,
+			keyPrefix: fmt.Sprintf("%s%s[%d].", ds.keyPrefix, fld.key, index),
+		})
+		if err != nil {
+			elementError(err, structName, fld, index, forms[index].sent)
+			return 0, err
+		}
+	}
+
+	for f.Kind() == reflect.Pointer {
+		if f.IsNil() {
+			f.Set(reflect.New(f.Type().Elem()))
+		}
+		f = f.Elem()
+	}
+	f.Set(s)
+	return len(forms), nil
+}
+
+// elementError rewrites the struct, field and key of err, an error of a field of the index'th element of fld,
+// to those of fld in the struct named structName, such as s.Items[0].Qty bound to items[0][qty].
+// The key is the one sent by the client, or items[0].qty for a field that was not sent.
+func elementError(err error, structName string, fld field, index int, sent map[string]string) {
+	rewrite := func(structField, field, key *string) {
+		*structField = structName
+		*field = fmt.Sprintf("%s[%d].%s", fld.name, index, *field)
+		if k, ok := sent[*key]; ok {
+			*key = k
+		} else if *key != "" {
+			*key = fmt.Sprintf("%s[%d].%s", fld.key, index, *key)
+		}
+	}
+
+	var typeErr *UnmarshalTypeError
+	var validationErr *ValidationError
+	var missingErr *MissingFieldError
+	switch {
+	case errors.As(err, &typeErr):
+		rewrite(&typeErr.Struct, &typeErr.Field, &typeErr.Key)
+	case errors.As(err, &validationErr):
+		rewrite(&validationErr.Struct, &validationErr.Field, &validationErr.Key)
+	case errors.As(err, &missingErr):
+		rewrite(&missingErr.Struct, &missingErr.Field, &missingErr.Key)
+	}
+}
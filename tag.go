@@ -0,0 +1,69 @@
+package form
+
+import "strings"
+
+// tagOptions is the comma separated list of options following
+// the key in a "form" struct tag, mapped from option name to value.
+// Options without a value, such as "omitempty", map to the empty string.
+// The name is separated from the value by its first "=" or ":", as in layout:2006-01-02, so a value
+// may itself contain "=" or ":". A value may also contain a comma escaped with a backslash. As the struct tag value is itself a quoted string,
+// the backslash is doubled in source: `form:"name,msg=Sorry\\, try again"`.
+type tagOptions map[string]string
+
+// parseTag splits a "form" struct tag into its key and options.
+func parseTag(tag string) (string, tagOptions) {
+	parts := splitTag(tag)
+	if len(parts) == 1 {
+		return parts[0], nil
+	}
+
+	opts := make(tagOptions)
+	for _, opt := range parts[1:] {
+		if opt == "" {
+			continue
+		}
+		name, value := opt, ""
+		if i := strings.IndexAny(opt, "=:"); i >= 0 {
+			name, value = opt[:i], opt[i+1:]
+		}
+		opts[name] = value
+	}
+	return parts[0], opts
+}
+
+// splitTag splits tag around the commas that are not escaped with a backslash,
+// replacing each escaped comma with a plain comma. Other backslashes are kept,
+// so regular expressions such as ^\d+$ are unchanged.
+func splitTag(tag string) []string {
+	if !strings.Contains(tag, `\,`) {
+		return strings.Split(tag, ",")
+	}
+
+	var parts []string
+	var part strings.Builder
+	for i := 0; i < len(tag); i++ {
+		switch {
+		case tag[i] == '\\' && i+1 < len(tag) && tag[i+1] == ',':
+			part.WriteByte(',')
+			i++
+		case tag[i] == ',':
+			parts = append(parts, part.String())
+			part.Reset()
+		default:
+			part.WriteByte(tag[i])
+		}
+	}
+	return append(parts, part.String())
+}
+
+// Has reports whether the option name is present.
+func (o tagOptions) Has(name string) bool {
+	_, ok := o[name]
+	return ok
+}
+
+// Get returns the value of the option name and whether it is present.
+func (o tagOptions) Get(name string) (string, bool) {
+	v, ok := o[name]
+	return v, ok
+}
@@ -0,0 +1,44 @@
+package form
+
+import (
+	"encoding"
+	"reflect"
+)
+
+var (
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// textUnmarshaler returns the [encoding.TextUnmarshaler] of the addressable value f, if its pointer
+// implements it. UnmarshalText is almost always declared on the pointer receiver, so the method set
+// of f.Addr() is checked rather than that of f, which can only be used when f is addressable.
+// time.Time is excluded as it is parsed with the layouts of its field.
+func textUnmarshaler(f reflect.Value) (encoding.TextUnmarshaler, bool) {
+	if f.Type() == timeType || !f.CanAddr() || !f.Addr().Type().Implements(textUnmarshalerType) {
+		return nil, false
+	}
+	if !allocEmbedded(f) {
+		return nil, false
+	}
+	return f.Addr().Interface().(encoding.TextUnmarshaler), true
+}
+
+// implementsText reports whether a pointer to a value of type t implements [encoding.TextUnmarshaler],
+// so such a value is unmarshalled from a single value even if t is a slice or array, as [net.IP] is.
+func implementsText(t reflect.Type) bool {
+	return t != timeType && reflect.PointerTo(t).Implements(textUnmarshalerType)
+}
+
+// unmarshalText calls the UnmarshalText method of u with value, wrapping any error in a [UnmarshalTypeError] for f.
+func unmarshalText(u encoding.TextUnmarshaler, f reflect.Value, value string) *UnmarshalTypeError {
+	err := u.UnmarshalText([]byte(value))
+	if err != nil {
+		return &UnmarshalTypeError{
+			Value: value,
+			Type:  f.Type(),
+			Err:   err,
+		}
+	}
+	return nil
+}
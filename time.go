@@ -0,0 +1,30 @@
+package form
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// defaultLayout is the layout of time.Time fields without a layout option.
+const defaultLayout = time.RFC3339
+
+// parseTime parses value with the first of layouts that matches it.
+// With a single layout the error from [time.Parse] is returned as is.
+func parseTime(value string, layouts []string) (time.Time, error) {
+	var err error
+	for _, layout := range layouts {
+		var t time.Time
+		t, err = time.Parse(layout, value)
+		if err == nil {
+			return t, nil
+		}
+	}
+	if len(layouts) == 1 {
+		return time.Time{}, err
+	}
+	return time.Time{}, fmt.Errorf("parsing time %q: does not match any of the layouts %s", value, strings.Join(layouts, ", "))
+}
@@ -0,0 +1,74 @@
+package form
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Underscores makes the Decoder accept underscores between the digits of integer fields, as in Go source,
+// so 1_000_000 unmarshals as 1000000. The underscore tag option does the same for a single field.
+// Integers are still parsed in base 10, and an underscore at the start or end of the digits, or next to
+// another underscore, is a [UnmarshalTypeError].
+func (d *Decoder) Underscores() *Decoder {
+	d.underscores = true
+	return d
+}
+
+// parseInt parses value as a base 10 integer, first removing underscores between digits if underscores is set.
+// Errors report the value as given.
+func parseInt(value string, underscores bool) (int64, error) {
+	if !underscores {
+		return strconv.ParseInt(value, 10, 64)
+	}
+	v, err := strconv.ParseInt(stripUnderscores(value), 10, 64)
+	return v, withNum(err, value)
+}
+
+// parseUint is like parseInt for unsigned integers.
+func parseUint(value string, underscores bool) (uint64, error) {
+	if !underscores {
+		return strconv.ParseUint(value, 10, 64)
+	}
+	v, err := strconv.ParseUint(stripUnderscores(value), 10, 64)
+	return v, withNum(err, value)
+}
+
+// withNum sets the input reported by err to value if err is a [strconv.NumError].
+func withNum(err error, value string) error {
+	if ne, ok := err.(*strconv.NumError); ok {
+		ne.Num = value
+	}
+	return err
+}
+
+// stripUnderscores removes the underscores from value if each one is between two digits,
+// and otherwise returns value unchanged so it fails to parse.
+func stripUnderscores(value string) string {
+	if !strings.Contains(value, "_") {
+		return value
+	}
+	for i := 0; i < len(value); i++ {
+		if value[i] != '_' {
+			continue
+		}
+		if i == 0 || i == len(value)-1 || !isDigit(value[i-1]) || !isDigit(value[i+1]) {
+			return value
+		}
+	}
+	return strings.ReplaceAll(value, "_", "")
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+// isInteger reports whether t is a signed or unsigned integer type.
+func isInteger(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
@@ -1,14 +1,27 @@
 package form_test
 
 import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"mime/multipart"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
-	"sort"
+	"reflect"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"testing/iotest"
+	"time"
 
 	"github.com/hunterwilkins2/form"
 	"golang.org/x/exp/constraints"
@@ -108,6 +121,98 @@ func TestUnmarshalBoolError(t *testing.T) {
 	testUnmarshalFormError(t, "notABool", &s{}, "form: cannot unmarshal notABool into Go struct field s.Val of type bool: strconv.ParseBool: parsing \"notABool\": invalid syntax")
 }
 
+func TestDecoderLenientNumbers(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Width   int           `form:"width"`
+		Percent uint8         `form:"percent"`
+		Scale   float64       `form:"scale"`
+		Weight  float32       `form:"weight"`
+		Limit   float64       `form:"limit"`
+		Timeout time.Duration `form:"timeout"`
+	}
+
+	decoder := form.NewDecoder().LenientNumbers()
+	r, _ := http.NewRequest(http.MethodGet, "/?width=30px&percent=100%25&scale=1.5em&weight=12+kg&limit=Inf&timeout=2s", nil)
+	var actual s
+	if err := decoder.Unmarshal(r, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	expected := s{Width: 30, Percent: 100, Scale: 1.5, Weight: 12, Limit: math.Inf(1), Timeout: 2 * time.Second}
+	if actual != expected {
+		t.Fatalf("wrong struct. want=%+v, got=%+v", expected, actual)
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "/?width=42&scale=1e3", nil)
+	actual = s{}
+	if err := decoder.Unmarshal(r, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	if actual.Width != 42 || actual.Scale != 1000 {
+		t.Fatalf("wrong pure numbers. got=%+v", actual)
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "/?scale=10,5kg&weight=2,+t&width=7px", nil)
+	actual = s{}
+	if err := form.NewDecoder().LenientNumbers().DecimalComma().Unmarshal(r, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error with DecimalComma: %s", err)
+	}
+	if actual.Scale != 10.5 || actual.Weight != 2 || actual.Width != 7 {
+		t.Fatalf("wrong numbers with DecimalComma. got=%+v", actual)
+	}
+
+	tests := []struct {
+		query    string
+		decoder  *form.Decoder
+		expected string
+	}{
+		{"width=px", decoder, `form: cannot unmarshal px into Go struct field s.Width of type int: strconv.ParseInt: parsing "px": invalid syntax`},
+		{"scale=%25", decoder, `form: cannot unmarshal % into Go struct field s.Scale of type float64: strconv.ParseFloat: parsing "%": invalid syntax`},
+		{"width=30px", form.NewDecoder(), `form: cannot unmarshal 30px into Go struct field s.Width of type int: strconv.ParseInt: parsing "30px": invalid syntax`},
+	}
+	for _, tt := range tests {
+		r, _ := http.NewRequest(http.MethodGet, "/?"+tt.query, nil)
+		err := tt.decoder.Unmarshal(r, &s{})
+		if err == nil || err.Error() != tt.expected {
+			t.Fatalf("wrong error for %s. want=%s, got=%v", tt.query, tt.expected, err)
+		}
+	}
+}
+
+func TestDecoderBoolTokens(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Agree   bool   `form:"agree"`
+		Opts    []bool `form:"opts"`
+		Ptr     *bool  `form:"ptr"`
+		Flag    int    `form:"flag,bool"`
+		Default bool   `form:"default"`
+	}
+
+	d := form.NewDecoder().BoolTokens([]string{"yes", "Ja"}, []string{"no", "nein"})
+	r, _ := http.NewRequest(http.MethodGet, "/?agree=YES&opts=ja&opts=No&opts=NEIN&ptr=no&flag=Yes&default=true", nil)
+	var actual s
+	if err := d.Unmarshal(r, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	no := false
+	expected := s{Agree: true, Opts: []bool{true, false, false}, Ptr: &no, Flag: 1, Default: true}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("wrong struct. want=%+v, got=%+v", expected, actual)
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "/?agree=maybe", nil)
+	want := `form: cannot unmarshal maybe into Go struct field s.Agree of type bool: strconv.ParseBool: parsing "maybe": invalid syntax`
+	if err := d.Unmarshal(r, &s{}); err == nil || err.Error() != want {
+		t.Fatalf("wrong error. want=%s, got=%v", want, err)
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "/?agree=yes", nil)
+	if err := form.Unmarshal(r, &s{}); err == nil {
+		t.Fatalf("expected an error for yes without BoolTokens")
+	}
+}
+
 func TestUnmarshalInt(t *testing.T) {
 	t.Parallel()
 	data := UrlFormData[int]{
@@ -161,6 +266,35 @@ func TestUnmarshalFloat(t *testing.T) {
 	testUnmarshalFormData(t, data)
 }
 
+func TestUnmarshalFloatNotation(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Val float64 `form:"value"`
+	}
+
+	tests := []struct {
+		value    string
+		expected float64
+	}{
+		{"1e3", 1000},
+		{"-1.5E-3", -0.0015},
+		{"0x1p-2", 0.25},
+		{"0X1.8P1", 3},
+		{".5", 0.5},
+		{"1_000.5", 1000.5},
+	}
+	for _, tt := range tests {
+		r, _ := http.NewRequest(http.MethodGet, "/?value="+url.QueryEscape(tt.value), nil)
+		var actual s
+		if err := form.Unmarshal(r, &actual); err != nil {
+			t.Fatalf("unexpected unmarshal error for %s: %s", tt.value, err)
+		}
+		if actual.Val != tt.expected {
+			t.Fatalf("wrong value for %s. want=%v, got=%v", tt.value, tt.expected, actual.Val)
+		}
+	}
+}
+
 func TestUnmarshalFloatError(t *testing.T) {
 	t.Parallel()
 	type s struct {
@@ -289,120 +423,4268 @@ func TestMultipleFieldsForSingleValueError(t *testing.T) {
 	testUnmarshalFormError(t, "5,6", &s{}, "form: cannot unmarshal [5, 6] into Go struct field s.Val of type int: cannot unmarshal more than one value for non-slice field")
 }
 
-func testUnmarshalFormData[T constraints.Ordered](t *testing.T, expected UrlFormData[T]) {
-	t.Helper()
-
-	var actual UrlFormData[T]
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+func TestDecoderMultiValueMode(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Page *int     `form:"page"`
+		Name string   `form:"name"`
+		Tags []string `form:"tags"`
+	}
 
-		err := form.Unmarshal(r, &actual)
+	values := url.Values{"page": {"1", "2", "3"}, "name": {"a", "b"}, "tags": {"x", "y"}}
+	tests := []struct {
+		mode form.MultiValue
+		page int
+		name string
+	}{
+		{form.MultiValueFirst, 1, "a"},
+		{form.MultiValueLast, 3, "b"},
+	}
+	for _, tt := range tests {
+		var actual s
+		err := form.NewDecoder().MultiValueMode(tt.mode).DecodeFrom(values, &actual)
 		if err != nil {
 			t.Fatalf("unexpected unmarshal error: %s", err)
 		}
-	}))
-	defer server.Close()
+		if actual.Page == nil || *actual.Page != tt.page || actual.Name != tt.name || !reflect.DeepEqual(actual.Tags, []string{"x", "y"}) {
+			t.Errorf("wrong struct for mode %d. got=%+v", tt.mode, actual)
+		}
+	}
 
-	r, err := http.NewRequest(http.MethodPost, server.URL, nil)
+	err := form.NewDecoder().MultiValueMode(form.MultiValueError).DecodeFrom(values, &s{})
+	want := "form: cannot unmarshal [1, 2, 3] into Go struct field s.Page of type *int: cannot unmarshal more than one value for non-slice field"
+	if err == nil || err.Error() != want {
+		t.Fatalf("wrong error. expected=%s, got=%v", want, err)
+	}
+}
+
+func TestUnmarshalCheckboxWithHiddenDefault(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Agree     bool `form:"agree,last"`
+		Subscribe int  `form:"subscribe,bool,last"`
+	}
+
+	submit := func(checked bool) *http.Request {
+		values := url.Values{}
+		values.Add("agree", "false")
+		values.Add("subscribe", "0")
+		if checked {
+			values.Add("agree", "true")
+			values.Add("subscribe", "on")
+		}
+		r, _ := http.NewRequest(http.MethodPost, "/", strings.NewReader(values.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return r
+	}
+
+	var actual s
+	if err := form.Unmarshal(submit(true), &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	if actual != (s{Agree: true, Subscribe: 1}) {
+		t.Fatalf("wrong struct for checked boxes. got=%+v", actual)
+	}
+
+	actual = s{}
+	if err := form.Unmarshal(submit(false), &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	if actual != (s{}) {
+		t.Fatalf("wrong struct for unchecked boxes. got=%+v", actual)
+	}
+
+	type untagged struct {
+		Agree bool `form:"agree"`
+	}
+	var u untagged
+	if err := form.NewDecoder().MultiValueMode(form.MultiValueLast).Unmarshal(submit(true), &u); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	if !u.Agree {
+		t.Fatalf("expected MultiValueLast to take the checkbox value. got=%+v", u)
+	}
+
+	type invalid struct {
+		Agree []bool `form:"agree,last"`
+	}
+	err := form.ValidateStruct(&invalid{})
+	want := "form: invalid option last in tag of Go struct field invalid.Agree: option only applies to non-slice fields, not []bool"
+	if err == nil || err.Error() != want {
+		t.Fatalf("wrong error. expected=%s, got=%v", want, err)
+	}
+}
+
+func TestUnmarshalConcat(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Data  string  `form:"data,concat"`
+		Lines *string `form:"lines,concat=\\,"`
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, "/?data=abc&data=def&data=gh&lines=a&lines=b&lines=c", nil)
+	var actual s
+	if err := form.Unmarshal(r, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	if actual.Data != "abcdefgh" || actual.Lines == nil || *actual.Lines != "a,b,c" {
+		t.Fatalf("wrong struct. got=%+v", actual)
+	}
+
+	type invalid struct {
+		Data []string `form:"data,concat"`
+	}
+	type combined struct {
+		Data string `form:"data,concat,last"`
+	}
+	testUnmarshalFormError(t, "1", &invalid{}, "form: invalid option concat in tag of Go struct field invalid.Data: option only applies to string fields, not []string")
+	testUnmarshalFormError(t, "1", &combined{}, "form: invalid option concat in tag of Go struct field combined.Data: option cannot be combined with last")
+}
+
+func TestUnmarshalStringLength(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Val string `form:"value,minlen=3,maxlen=5"`
+	}
+
+	testUnmarshalFormError(t, "ab", &s{}, "form: invalid value ab for Go struct field s.Val: length 2 is less than minlen 3")
+	testUnmarshalFormError(t, "abcdef", &s{}, "form: invalid value abcdef for Go struct field s.Val: length 6 is greater than maxlen 5")
+
+	r, _ := http.NewRequest(http.MethodGet, "/?value=%C3%A9t%C3%A9s", nil)
+	var actual s
+	err := form.Unmarshal(r, &actual)
 	if err != nil {
-		t.Fatalf("unexpected error creating request: %s", err)
+		t.Fatalf("unexpected unmarshal error: %s", err)
 	}
-	form := make(url.Values)
-	form.Add("single", fmt.Sprintf("%v", expected.Single))
-	for _, val := range expected.Slice {
-		form.Add("slice", fmt.Sprintf("%v", val))
+	if actual.Val != "étés" {
+		t.Fatalf("wrong value. want=%s, got=%s", "étés", actual.Val)
 	}
-	for _, val := range expected.Array {
-		form.Add("array", fmt.Sprintf("%v", val))
+}
+
+func TestUnmarshalStringLengthError(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Val string `form:"value,minlen=3"`
 	}
-	r.URL.RawQuery = form.Encode()
-	r.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 
-	resp, err := http.DefaultClient.Do(r)
+	r, _ := http.NewRequest(http.MethodGet, "/?value=ab", nil)
+	err := form.Unmarshal(r, &s{})
+	var validationErr *form.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("expected *form.ValidationError; got %T", err)
+	}
+	if validationErr.Option != "minlen" || validationErr.Field != "Val" {
+		t.Fatalf("wrong validation error. want=minlen on Val, got=%s on %s", validationErr.Option, validationErr.Field)
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "/", nil)
+	err = form.Unmarshal(r, &s{})
 	if err != nil {
-		t.Fatalf("unexpected error sending request: %s", err)
+		t.Fatalf("unexpected error for absent value: %s", err)
 	}
-	defer resp.Body.Close()
+}
 
-	if actual.Single != expected.Single {
-		t.Fatalf("single value does not match. want=%v, got=%v", expected.Single, actual.Single)
+func TestInvalidStringLengthTag(t *testing.T) {
+	t.Parallel()
+	type notString struct {
+		Val int `form:"value,maxlen=3"`
 	}
-	if len(actual.Slice) != len(expected.Slice) {
-		t.Fatalf("slices do not have the same length. want=%d, got=%d", len(expected.Slice), len(actual.Slice))
+	type notNumber struct {
+		Val string `form:"value,minlen=three"`
 	}
-	sort.Slice(actual.Slice, func(i, j int) bool {
-		return actual.Slice[i] < actual.Slice[j]
-	})
-	sort.Slice(expected.Slice, func(i, j int) bool {
-		return expected.Slice[i] < expected.Slice[j]
-	})
 
-	for i := 0; i < len(actual.Slice); i++ {
-		if actual.Slice[i] != expected.Slice[i] {
-			t.Fatalf("mismatch value in slice. want=%v, got=%v", expected.Slice[i], actual.Slice[i])
-		}
+	testUnmarshalFormError(t, "1", &notString{}, "form: invalid option maxlen in tag of Go struct field notString.Val: option only applies to string fields, not int")
+	testUnmarshalFormError(t, "a", &notNumber{}, "form: invalid option minlen in tag of Go struct field notNumber.Val: \"three\" is not a non-negative integer")
+}
+
+func TestUnmarshalRange(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Ages   []int     `form:"ages,min=0,max=120"`
+		Ratio  *float64  `form:"ratio,min=0,max=1"`
+		Counts [2]uint8  `form:"counts,max=10"`
+		Names  []string  `form:"names,maxlen=3"`
+		Scores []float32 `form:"scores,sep,min=-1.5"`
 	}
 
-	sortArray(actual.Array)
-	sortArray(expected.Array)
+	r, _ := http.NewRequest(http.MethodGet, "/?ages=0&ages=42&ages=120&ratio=0.5&counts=1&counts=10&names=ann&scores=-1.5,2", nil)
+	var actual s
+	if err := form.Unmarshal(r, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	if !reflect.DeepEqual(actual.Ages, []int{0, 42, 120}) || *actual.Ratio != 0.5 {
+		t.Fatalf("wrong struct. got=%+v", actual)
+	}
 
-	for i := 0; i < len(actual.Slice); i++ {
-		if actual.Array[i] != expected.Array[i] {
-			t.Fatalf("mismatch value in array. want=%v, got=%v", expected.Array[i], actual.Array[i])
+	tests := []struct {
+		query  string
+		option string
+		err    string
+	}{
+		{"ages=30&ages=130&ages=-1", "max", "form: invalid value 130 for Go struct field s.Ages: element 1: 130 is greater than max 120"},
+		{"ages=-1", "min", "form: invalid value -1 for Go struct field s.Ages: element 0: -1 is less than min 0"},
+		{"ratio=1.25", "max", "form: invalid value 1.25 for Go struct field s.Ratio: 1.25 is greater than max 1"},
+		{"counts=1&counts=11", "max", "form: invalid value 11 for Go struct field s.Counts: element 1: 11 is greater than max 10"},
+		{"names=ann&names=anna", "maxlen", "form: invalid value anna for Go struct field s.Names: element 1: length 4 is greater than maxlen 3"},
+		{"scores=0,-2", "min", "form: invalid value -2 for Go struct field s.Scores: element 1: -2 is less than min -1.5"},
+	}
+	for _, tt := range tests {
+		r, _ := http.NewRequest(http.MethodGet, "/?"+tt.query, nil)
+		err := form.Unmarshal(r, &s{})
+		var validationErr *form.ValidationError
+		if !errors.As(err, &validationErr) || validationErr.Option != tt.option {
+			t.Fatalf("expected %s ValidationError for %s, got=%v", tt.option, tt.query, err)
+		}
+		if err.Error() != tt.err {
+			t.Fatalf("wrong error for %s. want=%s, got=%s", tt.query, tt.err, err)
 		}
 	}
+
+	testUnmarshalFormError(t, "1", &struct {
+		Val []string `form:"value,min=1"`
+	}{}, "form: invalid option min in tag of Go struct field .Val: option only applies to number fields, not []string")
+	testUnmarshalFormError(t, "1", &struct {
+		Val int `form:"value,max=ten"`
+	}{}, "form: invalid option max in tag of Go struct field .Val: \"ten\" is not a number")
+	testUnmarshalFormError(t, "1", &struct {
+		Val int `form:"value,min=1.5"`
+	}{}, "form: invalid option min in tag of Go struct field .Val: \"1.5\" is not an integer")
+	testUnmarshalFormError(t, "1", &struct {
+		Val uint `form:"value,min=-1"`
+	}{}, "form: invalid option min in tag of Go struct field .Val: \"-1\" is not a non-negative integer")
 }
 
-func TestParseFormError(t *testing.T) {
+func TestUnmarshalRangeLargeIntegers(t *testing.T) {
 	t.Parallel()
+	// Both bounds and values are beyond 2^53, where float64 cannot tell neighbouring integers apart.
 	type s struct {
-		Val int `form:"value"`
+		ID   int64  `form:"id,max=9223372036854775806"`
+		Size uint64 `form:"size,min=18446744073709551615"`
 	}
 
-	r, err := http.NewRequest(http.MethodPost, "/", nil)
+	testUnmarshalFormError(t, "9223372036854775807", &struct {
+		ID int64 `form:"value,max=9223372036854775806"`
+	}{}, "form: invalid value 9223372036854775807 for Go struct field .ID: 9223372036854775807 is greater than max 9223372036854775806")
+	testUnmarshalFormError(t, "18446744073709551614", &struct {
+		Size uint64 `form:"value,min=18446744073709551615"`
+	}{}, "form: invalid value 18446744073709551614 for Go struct field .Size: 18446744073709551614 is less than min 18446744073709551615")
+
+	r, _ := http.NewRequest(http.MethodGet, "/?id=9223372036854775806&size=18446744073709551615", nil)
+	var actual s
+	if err := form.Unmarshal(r, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	if actual.ID != math.MaxInt64-1 || actual.Size != math.MaxUint64 {
+		t.Fatalf("wrong struct. got=%+v", actual)
+	}
+}
+
+func TestUnmarshalPattern(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Val string `form:"value,pattern=^[a-z0-9-]+$"`
+	}
+
+	testUnmarshalFormError(t, "Not A Slug", &s{}, "form: invalid value Not A Slug for Go struct field s.Val: value does not match pattern ^[a-z0-9-]+$")
+
+	r, _ := http.NewRequest(http.MethodGet, "/?value=a-slug-2", nil)
+	var actual s
+	err := form.Unmarshal(r, &actual)
 	if err != nil {
-		t.Fatalf("unexpected error creating request: %s", err)
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	if actual.Val != "a-slug-2" {
+		t.Fatalf("wrong value. want=%s, got=%s", "a-slug-2", actual.Val)
 	}
+}
 
-	err = form.Unmarshal(r, &s{})
-	if err == nil {
-		t.Fatalf("expected error from r.ParseForm()")
+func TestValidateStruct(t *testing.T) {
+	t.Parallel()
+	type valid struct {
+		Val string `form:"value,pattern=^a+$,minlen=1"`
+	}
+	type invalid struct {
+		Val string `form:"value,pattern=a(b"`
+	}
+
+	if err := form.ValidateStruct(valid{}); err != nil {
+		t.Fatalf("unexpected error validating struct: %s", err)
+	}
+	if err := form.ValidateStruct((*valid)(nil)); err != nil {
+		t.Fatalf("unexpected error validating struct pointer: %s", err)
+	}
+
+	err := form.ValidateStruct(&invalid{})
+	var tagErr *form.InvalidTagError
+	if !errors.As(err, &tagErr) {
+		t.Fatalf("expected *form.InvalidTagError; got %T", err)
+	}
+	want := "form: invalid option pattern in tag of Go struct field invalid.Val: error parsing regexp: missing closing ): `a(b`"
+	if err.Error() != want {
+		t.Fatalf("wrong error message. want=%s, got=%s", want, err.Error())
+	}
+
+	var invalidUnmarshalErr *form.InvalidUnmarshalError
+	if err := form.ValidateStruct(5); !errors.As(err, &invalidUnmarshalErr) {
+		t.Fatalf("expected *form.InvalidUnmarshalError; got %T", err)
 	}
 }
 
-func testUnmarshalFormError(t *testing.T, value string, i interface{}, expectedError string) {
-	t.Helper()
+func TestUnmarshalBracketKeys(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		IDs   []int    `form:"ids"`
+		Pair  [2]int   `form:"pair"`
+		Name  string   `form:"name"`
+		Mixed []string `form:"mixed"`
+	}
 
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	r, _ := http.NewRequest(http.MethodGet, "/?ids[]=1&ids[]=2&pair[]=3&pair[]=4&name[]=John&mixed=a&mixed[]=b", nil)
+	var actual s
+	err := form.Unmarshal(r, &actual)
+	if err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
 
-		err := form.Unmarshal(r, i)
-		if err == nil || err.Error() != expectedError {
-			t.Errorf("wrong expected error. want=%s, got=%s", expectedError, err)
-		}
-	}))
-	defer server.Close()
+	if fmt.Sprint(actual.IDs) != "[1 2]" {
+		t.Fatalf("wrong ids. want=%s, got=%v", "[1 2]", actual.IDs)
+	}
+	if actual.Pair != [2]int{3, 4} {
+		t.Fatalf("wrong pair. want=%v, got=%v", [2]int{3, 4}, actual.Pair)
+	}
+	if actual.Name != "" {
+		t.Fatalf("expected non-slice field to ignore bracket key. got=%s", actual.Name)
+	}
+	if fmt.Sprint(actual.Mixed) != "[a b]" {
+		t.Fatalf("wrong mixed values. want=%s, got=%v", "[a b]", actual.Mixed)
+	}
+}
 
-	r, err := http.NewRequest(http.MethodPost, server.URL, nil)
+func TestUnmarshalMessage(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Val   int    `form:"value,msg=Please enter a number"`
+		Email string `form:"email,minlen=3,msg=Please enter your email"`
+	}
+
+	testUnmarshalFormError(t, "abc", &s{}, "Please enter a number")
+
+	r, _ := http.NewRequest(http.MethodGet, "/?email=a", nil)
+	err := form.Unmarshal(r, &s{})
+	var msgErr *form.MessageError
+	if !errors.As(err, &msgErr) {
+		t.Fatalf("expected *form.MessageError; got %T", err)
+	}
+	if msgErr.Message != "Please enter your email" {
+		t.Fatalf("wrong message. want=%s, got=%s", "Please enter your email", msgErr.Message)
+	}
+	var validationErr *form.ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Option != "minlen" {
+		t.Fatalf("expected wrapped minlen *form.ValidationError; got %v", errors.Unwrap(err))
+	}
+}
+
+type upperString string
+
+func (u *upperString) Scan(src interface{}) error {
+	s, ok := src.(string)
+	if !ok {
+		return fmt.Errorf("cannot scan %T into upperString", src)
+	}
+	if s == "" {
+		return fmt.Errorf("empty upperString")
+	}
+	*u = upperString(strings.ToUpper(s))
+	return nil
+}
+
+func TestUnmarshalSQLNull(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		String  sql.NullString  `form:"string"`
+		Int64   sql.NullInt64   `form:"int64"`
+		Int32   sql.NullInt32   `form:"int32"`
+		Float64 sql.NullFloat64 `form:"float64"`
+		Bool    sql.NullBool    `form:"bool"`
+		Time    sql.NullTime    `form:"time,layout=2006-01-02"`
+		Generic sql.Null[uint8] `form:"generic"`
+		Ptr     *sql.NullString `form:"ptr"`
+		List    []sql.NullInt64 `form:"list"`
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, "/?string=&int64=-5&int32=7&float64=1.5&bool=true&time=2024-03-04&generic=9&ptr=p&list=1&list=2", nil)
+	var actual s
+	if err := form.Unmarshal(r, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	expected := s{
+		String:  sql.NullString{String: "", Valid: true},
+		Int64:   sql.NullInt64{Int64: -5, Valid: true},
+		Int32:   sql.NullInt32{Int32: 7, Valid: true},
+		Float64: sql.NullFloat64{Float64: 1.5, Valid: true},
+		Bool:    sql.NullBool{Bool: true, Valid: true},
+		Time:    sql.NullTime{Time: time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC), Valid: true},
+		Generic: sql.Null[uint8]{V: 9, Valid: true},
+		Ptr:     &sql.NullString{String: "p", Valid: true},
+		List:    []sql.NullInt64{{Int64: 1, Valid: true}, {Int64: 2, Valid: true}},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("wrong struct. want=%+v, got=%+v", expected, actual)
+	}
+	testMarshalForm(t, &actual, "bool=true&float64=1.500000&generic=9&int32=7&int64=-5&list=1&list=2&ptr=p&string=&time=2024-03-04")
+
+	r, _ = http.NewRequest(http.MethodGet, "/", nil)
+	absent := s{}
+	if err := form.Unmarshal(r, &absent); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	if !reflect.DeepEqual(absent, s{}) {
+		t.Fatalf("expected absent keys to leave Valid false. got=%+v", absent)
+	}
+	testMarshalForm(t, &s{String: sql.NullString{String: "ignored"}}, "")
+
+	testUnmarshalFormError(t, "x", &struct {
+		Int sql.NullInt64 `form:"value"`
+	}{}, "form: cannot unmarshal x into Go struct field .Int of type sql.NullInt64: strconv.ParseInt: parsing \"x\": invalid syntax")
+}
+
+func TestUnmarshalScanner(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Name  upperString   `form:"name"`
+		Tags  []upperString `form:"tags"`
+		Count sql.NullInt64 `form:"count"`
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, "/?name=john&tags=a&tags=b&count=5", nil)
+	var actual s
+	err := form.NewDecoder().UseScanner().Unmarshal(r, &actual)
 	if err != nil {
-		t.Fatalf("unexpected error creating request: %s", err)
+		t.Fatalf("unexpected unmarshal error: %s", err)
 	}
-	form := make(url.Values)
-	for _, val := range strings.Split(value, ",") {
-		form.Add("value", val)
+	if actual.Name != "JOHN" {
+		t.Fatalf("wrong name. want=%s, got=%s", "JOHN", actual.Name)
+	}
+	if fmt.Sprint(actual.Tags) != "[A B]" {
+		t.Fatalf("wrong tags. want=%s, got=%v", "[A B]", actual.Tags)
+	}
+	if !actual.Count.Valid || actual.Count.Int64 != 5 {
+		t.Fatalf("wrong count. want=%v, got=%v", sql.NullInt64{Int64: 5, Valid: true}, actual.Count)
 	}
-	r.URL.RawQuery = form.Encode()
-	r.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 
-	resp, err := http.DefaultClient.Do(r)
+	r, _ = http.NewRequest(http.MethodGet, "/?name=john", nil)
+	actual = s{}
+	err = form.Unmarshal(r, &actual)
 	if err != nil {
-		t.Fatalf("unexpected error sending request: %s", err)
+		t.Fatalf("unexpected unmarshal error: %s", err)
 	}
-	resp.Body.Close()
+	if actual.Name != "john" {
+		t.Fatalf("expected Scanner to be ignored without UseScanner. got=%s", actual.Name)
+	}
+}
+
+func TestUnmarshalScannerError(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Name upperString `form:"name"`
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, "/?name=", nil)
+	err := form.NewDecoder().UseScanner().Unmarshal(r, &s{})
+	if err == nil || err.Error() != "form: cannot unmarshal  into Go struct field s.Name of type form_test.upperString: empty upperString" {
+		t.Fatalf("wrong error. got=%v", err)
+	}
+}
+
+type registeredID [4]byte
+
+func init() {
+	form.RegisterType(reflect.TypeOf(registeredID{}),
+		func(value string) (interface{}, error) {
+			var id registeredID
+			b, err := hex.DecodeString(value)
+			if err != nil || len(b) != len(id) {
+				return nil, errors.New("want 8 hex digits")
+			}
+			copy(id[:], b)
+			return id, nil
+		},
+		func(v interface{}) (string, error) {
+			return fmt.Sprintf("%x", v.(registeredID)), nil
+		},
+	)
+}
+
+func TestUnmarshalRegisteredType(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		ID  registeredID   `form:"id"`
+		IDs []registeredID `form:"ids"`
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, "/?id=0a0b0c0d&ids=00000001&ids=ffffffff", nil)
+	var actual s
+	if err := form.Unmarshal(r, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	expected := s{
+		ID:  registeredID{0x0a, 0x0b, 0x0c, 0x0d},
+		IDs: []registeredID{{0, 0, 0, 1}, {0xff, 0xff, 0xff, 0xff}},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("wrong struct. want=%v, got=%v", expected, actual)
+	}
+
+	testUnmarshalFormError(t, "abc", &struct {
+		ID registeredID `form:"value"`
+	}{}, "form: cannot unmarshal abc into Go struct field .ID of type form_test.registeredID: want 8 hex digits")
 }
 
-func sortArray[T constraints.Ordered](a [2]T) {
-	if a[0] > a[1] {
-		temp := a[0]
-		a[0] = a[1]
-		a[1] = temp
+type hostname string
+
+func TestDecoderRegisterDecoderContext(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Host  hostname   `form:"host"`
+		Hosts []hostname `form:"hosts"`
+	}
+
+	// lookup resolves immediately unless the host is "slow", which waits for the context to be done.
+	lookup := func(ctx context.Context, value string) (interface{}, error) {
+		if value == "slow" {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return hostname(value + ".internal"), nil
+	}
+	d := form.NewDecoder().RegisterDecoderContext(reflect.TypeOf(hostname("")), lookup)
+
+	r, _ := http.NewRequest(http.MethodGet, "/?host=db&hosts=a&hosts=b", nil)
+	var actual s
+	if err := d.UnmarshalContext(context.Background(), r, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	expected := s{Host: "db.internal", Hosts: []hostname{"a.internal", "b.internal"}}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("wrong struct. want=%+v, got=%+v", expected, actual)
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "/?host=slow", nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := d.UnmarshalContext(ctx, r, &s{})
+	var typeErr *form.UnmarshalTypeError
+	if !errors.Is(err, context.DeadlineExceeded) || !errors.As(err, &typeErr) || typeErr.Field != "Host" {
+		t.Fatalf("expected the deadline to stop the decoder, got=%v", err)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	r, _ = http.NewRequestWithContext(ctx, http.MethodGet, "/?hosts=a&hosts=slow", nil)
+	if err := d.Unmarshal(r, &s{}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected Unmarshal to pass the request's context, got=%v", err)
+	}
+}
+
+func TestDecoderRegisterDecoder(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		ID   registeredID `form:"id"`
+		Name string       `form:"name"`
+	}
+
+	d := form.NewDecoder().
+		RegisterDecoder(reflect.TypeOf(registeredID{}), func(value string) (interface{}, error) {
+			return registeredID{1, 2, 3, 4}, nil
+		}).
+		RegisterDecoder(reflect.TypeOf(""), func(value string) (interface{}, error) {
+			return strings.ToUpper(value), nil
+		})
+
+	r, _ := http.NewRequest(http.MethodGet, "/?id=zz&name=john", nil)
+	var actual s
+	if err := d.Unmarshal(r, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	if actual.ID != (registeredID{1, 2, 3, 4}) {
+		t.Fatalf("expected Decoder registration to take precedence. got=%v", actual.ID)
+	}
+	if actual.Name != "JOHN" {
+		t.Fatalf("wrong name. want=%s, got=%s", "JOHN", actual.Name)
+	}
+
+	d = form.NewDecoder().RegisterDecoder(reflect.TypeOf(0), func(value string) (interface{}, error) {
+		return value, nil
+	})
+	r, _ = http.NewRequest(http.MethodGet, "/?value=1", nil)
+	err := d.Unmarshal(r, &struct {
+		Value int `form:"value"`
+	}{})
+	if err == nil || err.Error() != "form: cannot unmarshal 1 into Go struct field .Value of type int: registered decoder returned string, not int" {
+		t.Fatalf("wrong error. got=%v", err)
+	}
+}
+
+type email string
+
+func TestDecoderRegisterNormalizer(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Email  email    `form:"email,pattern=^[a-z@.]+$"`
+		CC     []email  `form:"cc"`
+		Price  float64  `form:"price"`
+		Rating *float64 `form:"rating"`
+		Name   string   `form:"name"`
+	}
+
+	d := form.NewDecoder().
+		RegisterNormalizer(reflect.TypeOf(email("")), func(v reflect.Value) {
+			v.SetString(strings.ToLower(strings.TrimSpace(v.String())))
+		}).
+		RegisterNormalizer(reflect.TypeOf(0.0), func(v reflect.Value) {
+			v.SetFloat(math.Round(v.Float()*100) / 100)
+		}).
+		Validator(func(i interface{}, values url.Values) error {
+			if i.(*s).Price != 10.49 {
+				return fmt.Errorf("validator saw price %v", i.(*s).Price)
+			}
+			return nil
+		})
+
+	r, _ := http.NewRequest(http.MethodGet, "/?email=+Ann@Example.COM&cc=B@X.io&cc=c@y.io&price=10.4899&rating=4.256&name=Ann", nil)
+	var actual s
+	if err := d.Unmarshal(r, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	rating := 4.26
+	expected := s{Email: "ann@example.com", CC: []email{"b@x.io", "c@y.io"}, Price: 10.49, Rating: &rating, Name: "Ann"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("wrong struct. want=%+v, got=%+v", expected, actual)
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "/?email=Ann@Example.COM&price=10.49", nil)
+	if err := form.Unmarshal(r, &s{}); err == nil {
+		t.Fatalf("expected pattern to fail without the normalizer")
+	}
+}
+
+type Shape interface {
+	Area() float64
+}
+
+type circle struct {
+	Radius float64 `form:"radius"`
+}
+
+func (c *circle) Area() float64 { return math.Pi * c.Radius * c.Radius }
+
+type side float64
+
+func (s side) Area() float64 { return float64(s * s) }
+
+func TestDecoderRegisterConcrete(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Shape `form:"Shape"`
+		Name  string `form:"name"`
+	}
+	type scalar struct {
+		Square Shape       `form:"square"`
+		Raw    interface{} `form:"raw"`
+	}
+
+	d := form.NewDecoder().RegisterConcrete(reflect.TypeOf((*Shape)(nil)).Elem(), func() interface{} { return &circle{} })
+	r, _ := http.NewRequest(http.MethodGet, "/?radius=2&name=c", nil)
+	var actual s
+	if err := d.Unmarshal(r, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	c, ok := actual.Shape.(*circle)
+	if !ok || c.Radius != 2 || actual.Name != "c" {
+		t.Fatalf("wrong struct. got=%+v", actual)
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "/?radius=big", nil)
+	err := d.Unmarshal(r, &s{})
+	if err == nil || err.Error() != "form: cannot unmarshal big into Go struct field circle.Radius of type float64: strconv.ParseFloat: parsing \"big\": invalid syntax" {
+		t.Fatalf("wrong error. got=%v", err)
+	}
+
+	d = form.NewDecoder().RegisterConcrete(reflect.TypeOf((*Shape)(nil)).Elem(), func() interface{} { return side(0) })
+	r, _ = http.NewRequest(http.MethodGet, "/?square=3&raw=text", nil)
+	var sc scalar
+	if err := d.Unmarshal(r, &sc); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	if sc.Square != side(3) || sc.Raw != "text" {
+		t.Fatalf("wrong struct. got=%+v", sc)
+	}
+
+	err = form.Unmarshal(r, &scalar{})
+	if err == nil || err.Error() != "form: cannot unmarshal 3 into Go struct field scalar.Square of type form_test.Shape: type form_test.Shape cannot be unmarshalled from form without a concrete type" {
+		t.Fatalf("wrong error without a concrete type. got=%v", err)
+	}
+
+	d = form.NewDecoder().RegisterConcrete(reflect.TypeOf((*Shape)(nil)).Elem(), func() interface{} { return circle{} })
+	err = d.Unmarshal(r, &scalar{})
+	if err == nil || err.Error() != "form: cannot unmarshal square into Go struct field scalar.Square of type form_test.Shape: concrete factory for form_test.Shape returned form_test.circle, which does not implement it" {
+		t.Fatalf("wrong error for invalid factory. got=%v", err)
+	}
+}
+
+type point struct {
+	X, Y int
+}
+
+func (p *point) UnmarshalForm(values []string) error {
+	if len(values) != 2 {
+		return fmt.Errorf("want 2 coordinates, got %d", len(values))
+	}
+	var err error
+	if p.X, err = strconv.Atoi(values[0]); err != nil {
+		return err
+	}
+	p.Y, err = strconv.Atoi(values[1])
+	return err
+}
+
+type label string
+
+func (l *label) UnmarshalForm(values []string) error {
+	*l = label(strings.ToUpper(values[0]))
+	return nil
+}
+
+type namedPoint struct {
+	point
+	Name string `form:"name"`
+}
+
+type Coord = point
+
+type pointerPoint struct {
+	*Coord
+}
+
+type unexportedPointer struct {
+	*point
+}
+
+type requestWithPoint struct {
+	point
+	Name string `form:"name"`
+}
+
+func TestUnmarshalUnmarshaler(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Point   point            `form:"point"`
+		Labels  []label          `form:"labels"`
+		Named   namedPoint       `form:"named"`
+		Pointer pointerPoint     `form:"pointer"`
+		ByGroup map[string]point `form:"group"`
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, "/?point=1&point=2&labels=a&labels=b&named=3&named=4&name=ignored&pointer=5&pointer=6&group[a]=7&group[a]=8", nil)
+	var actual s
+	if err := form.Unmarshal(r, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	if actual.Point != (point{1, 2}) {
+		t.Fatalf("wrong point. got=%v", actual.Point)
+	}
+	if !reflect.DeepEqual(actual.Labels, []label{"A", "B"}) {
+		t.Fatalf("wrong labels. got=%v", actual.Labels)
+	}
+	if actual.Named != (namedPoint{point: point{3, 4}}) {
+		t.Fatalf("expected promoted UnmarshalForm to bind only the embedded point. got=%+v", actual.Named)
+	}
+	if actual.Pointer.Coord == nil || *actual.Pointer.Coord != (point{5, 6}) {
+		t.Fatalf("expected nil embedded pointer to be allocated. got=%+v", actual.Pointer.Coord)
+	}
+	if actual.ByGroup["a"] != (point{7, 8}) {
+		t.Fatalf("wrong map of points. got=%v", actual.ByGroup)
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "/?point=1", nil)
+	err := form.Unmarshal(r, &s{})
+	if err == nil || err.Error() != "form: cannot unmarshal 1 into Go struct field s.Point of type form_test.point: want 2 coordinates, got 1" {
+		t.Fatalf("wrong error. got=%v", err)
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "/?value=1", nil)
+	err = form.Unmarshal(r, &struct {
+		P unexportedPointer `form:"value"`
+	}{})
+	if err == nil || err.Error() != "form: cannot unmarshal 1 into Go struct field .P of type form_test.unexportedPointer: type form_test.unexportedPointer cannot be unmarshalled from form" {
+		t.Fatalf("wrong error for nil unexported embedded pointer. got=%v", err)
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "/?name=john", nil)
+	var req requestWithPoint
+	if err := form.Unmarshal(r, &req); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	if req.Name != "john" || req.point != (point{}) {
+		t.Fatalf("expected struct passed to Unmarshal to be bound field by field. got=%+v", req)
+	}
+}
+
+type level int
+
+func (l *level) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "low":
+		*l = 1
+	case "high":
+		*l = 2
+	default:
+		return fmt.Errorf("unknown level %q", text)
+	}
+	return nil
+}
+
+type version struct {
+	Major, Minor int
+}
+
+func (v *version) UnmarshalText(text []byte) error {
+	_, err := fmt.Sscanf(string(text), "v%d.%d", &v.Major, &v.Minor)
+	return err
+}
+
+func TestUnmarshalTextUnmarshaler(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Level    level     `form:"level"`
+		Levels   []level   `form:"levels"`
+		Version  version   `form:"version"`
+		Optional *version  `form:"optional"`
+		IP       net.IP    `form:"ip"`
+		Start    time.Time `form:"start,layout=2006-01-02"`
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, "/?level=high&levels=low&levels=high&version=v1.2&optional=v3.4&ip=10.0.0.1&start=2024-05-06", nil)
+	var actual s
+	if err := form.Unmarshal(r, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	expected := s{
+		Level:    2,
+		Levels:   []level{1, 2},
+		Version:  version{1, 2},
+		Optional: &version{3, 4},
+		IP:       net.ParseIP("10.0.0.1"),
+		Start:    time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC),
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("wrong struct. want=%+v, got=%+v", expected, actual)
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "/?level=mid", nil)
+	err := form.Unmarshal(r, &s{})
+	if err == nil || err.Error() != "form: cannot unmarshal mid into Go struct field s.Level of type form_test.level: unknown level \"mid\"" {
+		t.Fatalf("wrong error. got=%v", err)
+	}
+}
+
+type customID [4]byte
+
+func (id *customID) UnmarshalText(text []byte) error {
+	if len(text) != 4 {
+		return fmt.Errorf("id %q is not 4 characters", text)
+	}
+	copy(id[:], text)
+	return nil
+}
+
+func TestUnmarshalTextUnmarshalerArray(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		IDs      [3]customID `form:"ids"`
+		Versions [2]*version `form:"versions"`
+		Levels   [2]level    `form:"levels,sep"`
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, "/?ids=abcd&ids=efgh&ids=ijkl&versions=v1.2&versions=v3.4&levels=low,high", nil)
+	var actual s
+	if err := form.Unmarshal(r, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	expected := s{
+		IDs:      [3]customID{{'a', 'b', 'c', 'd'}, {'e', 'f', 'g', 'h'}, {'i', 'j', 'k', 'l'}},
+		Versions: [2]*version{{1, 2}, {3, 4}},
+		Levels:   [2]level{1, 2},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("wrong struct. want=%+v, got=%+v", expected, actual)
+	}
+
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{"ids=abcd&ids=efgh", "form: cannot unmarshal [abcd, efgh] into Go struct field s.IDs of type [3]form_test.customID: cannot use [2]form_test.customID as [3]form_test.customID value in struct"},
+		{"ids=abcd&ids=efgh&ids=ijkl&ids=mnop", "form: cannot unmarshal [abcd, efgh, ijkl, mnop] into Go struct field s.IDs of type [3]form_test.customID: cannot use [4]form_test.customID as [3]form_test.customID value in struct"},
+		{"ids=abcd&ids=ef&ids=ijkl", `form: cannot unmarshal [abcd, ef, ijkl] into Go struct field s.IDs of type [3]form_test.customID: id "ef" is not 4 characters`},
+		{"levels=low,mid", `form: cannot unmarshal [low, mid] into Go struct field s.Levels of type [2]form_test.level: unknown level "mid"`},
+	}
+	for _, tt := range tests {
+		r, _ := http.NewRequest(http.MethodGet, "/?"+tt.query, nil)
+		err := form.Unmarshal(r, &s{})
+		if err == nil || err.Error() != tt.expected {
+			t.Fatalf("wrong error for %s. want=%s, got=%v", tt.query, tt.expected, err)
+		}
+	}
+}
+
+type region struct {
+	Country, Code string
+}
+
+func (r *region) UnmarshalText(text []byte) error {
+	country, code, ok := strings.Cut(string(text), "-")
+	if !ok {
+		return fmt.Errorf("region %q is not of the form country-code", text)
+	}
+	*r = region{country, code}
+	return nil
+}
+
+func (r region) MarshalText() ([]byte, error) {
+	return []byte(r.Country + "-" + r.Code), nil
+}
+
+type quantity int
+
+func (q *quantity) UnmarshalText(text []byte) error {
+	n, err := strconv.Atoi(strings.TrimSuffix(string(text), "x"))
+	if err != nil || !strings.HasSuffix(string(text), "x") {
+		return fmt.Errorf("quantity %q is not of the form Nx", text)
+	}
+	*q = quantity(n)
+	return nil
+}
+
+func TestUnmarshalTextUnmarshalerMap(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Stock  map[region]quantity            `form:"stock"`
+		Nested map[region]map[string]quantity `form:"nested"`
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, "/?stock[US-CA]=3x&stock[FR-75]=1x&nested[US-NY][a]=2x", nil)
+	var actual s
+	if err := form.Unmarshal(r, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	expected := s{
+		Stock:  map[region]quantity{{"US", "CA"}: 3, {"FR", "75"}: 1},
+		Nested: map[region]map[string]quantity{{"US", "NY"}: {"a": 2}},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("wrong struct. want=%+v, got=%+v", expected, actual)
+	}
+
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{"stock[CA]=3x", "form: cannot unmarshal stock[CA] into Go struct field s.Stock of type map[form_test.region]form_test.quantity: invalid map key \"CA\": region \"CA\" is not of the form country-code"},
+		{"stock[US-CA]=3", "form: cannot unmarshal stock[US-CA]=3 into Go struct field s.Stock of type map[form_test.region]form_test.quantity: quantity \"3\" is not of the form Nx"},
+	}
+	for _, tt := range tests {
+		r, _ := http.NewRequest(http.MethodGet, "/?"+tt.query, nil)
+		err := form.Unmarshal(r, &s{})
+		if err == nil || err.Error() != tt.expected {
+			t.Fatalf("wrong error for %s. want=%s, got=%v", tt.query, tt.expected, err)
+		}
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "/", nil)
+	if err := form.Marshal(r, &s{Stock: map[region]quantity{{"US", "CA"}: 3}}); err != nil {
+		t.Fatalf("unexpected marshal error: %s", err)
+	}
+	if r.URL.RawQuery != "stock%5BUS-CA%5D=3" {
+		t.Fatalf("wrong query. got=%s", r.URL.RawQuery)
+	}
+}
+
+func TestUnmarshalStructSlices(t *testing.T) {
+	t.Parallel()
+	type address struct {
+		City string `form:"city"`
+		Zip  string `form:"zip"`
+	}
+	type item struct {
+		Name    string   `form:"name,required"`
+		Qty     int      `form:"qty,default=1"`
+		Tags    []string `form:"tags"`
+		Address address  `form:"address"`
+	}
+	type s struct {
+		Items *[]item `form:"items"`
+		Refs  []*item `form:"refs,count=Count"`
+		Count int     `form:"-"`
+		Pair  [2]item `form:"pair"`
+		Title string  `form:"title"`
+	}
+
+	query := "items[0][name]=bolt&items[0][qty]=12&items[0][tags]=steel&items[0][tags]=m4" +
+		"&items[1].name=nut&items[1].address.city=Oslo&items[1].address.zip=0150" +
+		"&refs[0].name=washer&pair[1].name=screw&title=order"
+	r, _ := http.NewRequest(http.MethodGet, "/?"+query, nil)
+	var actual s
+	if err := form.Unmarshal(r, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+
+	expected := []item{
+		{Name: "bolt", Qty: 12, Tags: []string{"steel", "m4"}},
+		{Name: "nut", Qty: 1, Address: address{City: "Oslo", Zip: "0150"}},
+	}
+	if actual.Items == nil || !reflect.DeepEqual(*actual.Items, expected) {
+		t.Fatalf("wrong items. want=%+v, got=%+v", expected, actual.Items)
+	}
+	if len(actual.Refs) != 1 || actual.Refs[0].Name != "washer" || actual.Count != 1 {
+		t.Fatalf("wrong refs. got=%+v, count=%d", actual.Refs, actual.Count)
+	}
+	if actual.Pair[0].Name != "" || actual.Pair[1].Name != "screw" || actual.Title != "order" {
+		t.Fatalf("wrong struct. got=%+v", actual)
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "/?title=none", nil)
+	actual = s{}
+	if err := form.Unmarshal(r, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	if actual.Items != nil || actual.Refs != nil {
+		t.Fatalf("expected absent items to be left nil. got=%+v", actual)
+	}
+
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{"items[0][qty]=x&items[0][name]=a", `form: cannot unmarshal x into Go struct field s.Items[0].Qty of type int: strconv.ParseInt: parsing "x": invalid syntax`},
+		{"items[0][qty]=2", "form: missing value for required Go struct field s.Items[0].Name"},
+		{"items[x][name]=a", `form: cannot unmarshal items[x][name] into Go struct field s.Items of type *[]form_test.item: invalid index "x"`},
+		{"items[0]=a", "form: cannot unmarshal items[0] into Go struct field s.Items of type *[]form_test.item: key does not name a field after index 0"},
+		{"pair[2][name]=a", "form: cannot unmarshal pair[2] into Go struct field s.Pair of type [2]form_test.item: index 2 out of range for [2]form_test.item"},
+		{"items[99999999999].name=a", fmt.Sprintf("form: cannot unmarshal items[99999999999] into Go struct field s.Items of type *[]form_test.item: 100000000000 values exceed the maximum of %d", form.DefaultMaxSliceLen)},
+	}
+	for _, tt := range tests {
+		r, _ := http.NewRequest(http.MethodGet, "/?"+tt.query, nil)
+		err := form.Unmarshal(r, &s{})
+		if err == nil || err.Error() != tt.expected {
+			t.Fatalf("wrong error for %s. want=%s, got=%v", tt.query, tt.expected, err)
+		}
+	}
+}
+
+func TestUnmarshalStructSliceErrorKeys(t *testing.T) {
+	t.Parallel()
+	type line struct {
+		SKU string `form:"sku,required"`
+		Qty int    `form:"qty,min=1"`
+	}
+	type s struct {
+		Lines  []line            `form:"lines"`
+		Note   string            `form:"note"`
+		Errors map[string]string `form:",errors"`
+	}
+
+	tests := []struct {
+		query  string
+		err    interface{}
+		struc  string
+		field  string
+		key    string
+		errMsg string
+	}{
+		{"lines[0][sku]=a&lines[1][sku]=b&lines[1][qty]=0", &form.ValidationError{}, "s", "Lines[1].Qty", "lines[1][qty]",
+			"form: invalid value 0 for Go struct field s.Lines[1].Qty: 0 is less than min 1"},
+		{"lines[0].sku=a&lines[0].qty=-2", &form.ValidationError{}, "s", "Lines[0].Qty", "lines[0].qty",
+			"form: invalid value -2 for Go struct field s.Lines[0].Qty: -2 is less than min 1"},
+		{"lines[0][qty]=3", &form.MissingFieldError{}, "s", "Lines[0].SKU", "lines[0].sku",
+			"form: missing value for required Go struct field s.Lines[0].SKU"},
+	}
+	for _, tt := range tests {
+		r, _ := http.NewRequest(http.MethodGet, "/?"+tt.query, nil)
+		err := form.Unmarshal(r, &s{})
+		if err == nil || err.Error() != tt.errMsg {
+			t.Fatalf("wrong error for %s. want=%s, got=%v", tt.query, tt.errMsg, err)
+		}
+		var struc, field, key string
+		switch e := err.(type) {
+		case *form.ValidationError:
+			struc, field, key = e.Struct, e.Field, e.Key
+		case *form.MissingFieldError:
+			struc, field, key = e.Struct, e.Field, e.Key
+		}
+		if reflect.TypeOf(err) != reflect.TypeOf(tt.err) || struc != tt.struc || field != tt.field || key != tt.key {
+			t.Fatalf("wrong error fields for %s. got=%T %s.%s key=%s", tt.query, err, struc, field, key)
+		}
+
+		r, _ = http.NewRequest(http.MethodGet, "/?note=n&"+tt.query, nil)
+		var actual s
+		report, err := form.NewDecoder().SkipErrors().UnmarshalReport(r, &actual)
+		if err != nil {
+			t.Fatalf("unexpected unmarshal error for %s: %s", tt.query, err)
+		}
+		if actual.Errors[tt.key] != tt.errMsg || len(actual.Errors) != 1 || actual.Lines != nil || actual.Note != "n" {
+			t.Fatalf("wrong skipped struct for %s. got=%+v", tt.query, actual)
+		}
+		if len(report.Skipped) != 1 || report.Skipped[0].Key != tt.key || report.Skipped[0].Field != "Lines" {
+			t.Fatalf("wrong report for %s. got=%+v", tt.query, report.Skipped)
+		}
+	}
+}
+
+func TestUnmarshalIntKeyedMaps(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Scores map[int]string           `form:"scores"`
+		Counts map[uint8]int            `form:"counts"`
+		Grid   map[int64]map[int]string `form:"grid"`
+		Tags   map[int][]string         `form:"tags"`
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, "/?"+url.Values{
+		"scores[1]":   {"a"},
+		"scores[-20]": {"b"},
+		"counts[7]":   {"3"},
+		"grid[0][1]":  {"x"},
+		"tags[2]":     {"p", "q"},
+	}.Encode(), nil)
+	var actual s
+	if err := form.Unmarshal(r, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	expected := s{
+		Scores: map[int]string{1: "a", -20: "b"},
+		Counts: map[uint8]int{7: 3},
+		Grid:   map[int64]map[int]string{0: {1: "x"}},
+		Tags:   map[int][]string{2: {"p", "q"}},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("wrong struct. want=%+v, got=%+v", expected, actual)
+	}
+
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{"scores[one]=a", `form: cannot unmarshal scores[one] into Go struct field s.Scores of type map[int]string: invalid map key "one": strconv.ParseInt: parsing "one": invalid syntax`},
+		{"counts[-1]=1", `form: cannot unmarshal counts[-1] into Go struct field s.Counts of type map[uint8]int: invalid map key "-1": strconv.ParseUint: parsing "-1": invalid syntax`},
+		{"counts[300]=1", `form: cannot unmarshal counts[300] into Go struct field s.Counts of type map[uint8]int: invalid map key "300": 300 overflows uint8 value`},
+		{"grid[0][x]=1", `form: cannot unmarshal grid[0][x] into Go struct field s.Grid of type map[int64]map[int]string: invalid map key "x": strconv.ParseInt: parsing "x": invalid syntax`},
+	}
+	for _, tt := range tests {
+		r, _ := http.NewRequest(http.MethodGet, "/?"+tt.query, nil)
+		err := form.Unmarshal(r, &s{})
+		if err == nil || err.Error() != tt.expected {
+			t.Fatalf("wrong error for %s. want=%s, got=%v", tt.query, tt.expected, err)
+		}
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "/", nil)
+	err := form.NewEncoder().PreserveOrder().Marshal(r, &s{Scores: map[int]string{10: "c", 2: "b", -1: "a"}})
+	if err != nil {
+		t.Fatalf("unexpected marshal error: %s", err)
+	}
+	if r.URL.RawQuery != "scores%5B-1%5D=a&scores%5B2%5D=b&scores%5B10%5D=c" {
+		t.Fatalf("integer keys should be marshalled in numeric order. got=%s", r.URL.RawQuery)
+	}
+}
+
+type pair[K comparable, V any] struct {
+	Key   K `form:"key"`
+	Value V `form:"value"`
+}
+
+type generic[T any] struct {
+	Items  []T             `form:"items"`
+	Ptr    *T              `form:"ptr"`
+	Lookup map[string]T    `form:"lookup"`
+	Pair   pair[string, T] `form:"pair"`
+	hidden T               `form:"hidden"`
+}
+
+func TestUnmarshalGenericStructs(t *testing.T) {
+	t.Parallel()
+
+	r, _ := http.NewRequest(http.MethodGet, "/?items=1&items=2&ptr=3&lookup[a]=4&pair.key=k&pair.value=5&hidden=6", nil)
+	var ints generic[int]
+	if err := form.Unmarshal(r, &ints); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	three := 3
+	expected := generic[int]{
+		Items:  []int{1, 2},
+		Ptr:    &three,
+		Lookup: map[string]int{"a": 4},
+		Pair:   pair[string, int]{Key: "k", Value: 5},
+	}
+	if !reflect.DeepEqual(ints, expected) {
+		t.Fatalf("wrong struct. want=%+v, got=%+v", expected, ints)
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "/?items=1m&pair.key=k&pair.value=1h30m", nil)
+	var durations generic[time.Duration]
+	if err := form.Unmarshal(r, &durations); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	if !reflect.DeepEqual(durations.Items, []time.Duration{time.Minute}) || durations.Pair.Value != 90*time.Minute {
+		t.Fatalf("wrong struct. got=%+v", durations)
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "/?pair.value=x", nil)
+	err := form.Unmarshal(r, &generic[float64]{})
+	want := `form: cannot unmarshal x into Go struct field pair[string,float64].Value of type float64: strconv.ParseFloat: parsing "x": invalid syntax`
+	if err == nil || err.Error() != want {
+		t.Fatalf("wrong error. want=%s, got=%v", want, err)
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "/", nil)
+	if err := form.Marshal(r, &pair[string, []bool]{Key: "k", Value: []bool{true, false}}); err != nil {
+		t.Fatalf("unexpected marshal error: %s", err)
+	}
+	if r.URL.RawQuery != "key=k&value=true&value=false" {
+		t.Fatalf("wrong query. got=%s", r.URL.RawQuery)
+	}
+}
+
+type color int
+
+const (
+	colorNone color = iota
+	colorRed
+	colorGreen
+)
+
+func init() {
+	form.RegisterEnum(reflect.TypeOf(colorNone), map[string]interface{}{"red": colorRed, "green": colorGreen})
+}
+
+func TestUnmarshalUnknownEnum(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Color  color   `form:"color"`
+		Colors []color `form:"colors"`
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, "/?color=green&colors=red&colors=green", nil)
+	var actual s
+	if err := form.Unmarshal(r, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	expected := s{Color: colorGreen, Colors: []color{colorRed, colorGreen}}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("wrong struct. want=%+v, got=%+v", expected, actual)
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "/?color=blue", nil)
+	err := form.Unmarshal(r, &s{})
+	if !errors.Is(err, form.ErrUnknownEnum) {
+		t.Fatalf("expected ErrUnknownEnum, got=%v", err)
+	}
+	want := `form: cannot unmarshal blue into Go struct field s.Color of type form_test.color: unknown enum value "blue"`
+	if err.Error() != want {
+		t.Fatalf("wrong error. want=%s, got=%s", want, err)
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "/?color=blue&colors=red&colors=blue", nil)
+	actual = s{Color: colorRed}
+	if err := form.NewDecoder().UnknownEnumZero().Unmarshal(r, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	expected = s{Color: colorNone, Colors: []color{colorRed, colorNone}}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("wrong struct. want=%+v, got=%+v", expected, actual)
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "/", nil)
+	if err := form.Marshal(r, &s{Color: colorRed, Colors: []color{colorGreen}}); err != nil {
+		t.Fatalf("unexpected marshal error: %s", err)
+	}
+	if r.URL.RawQuery != "color=red&colors=green" {
+		t.Fatalf("wrong query. got=%s", r.URL.RawQuery)
+	}
+}
+
+func TestDecoderValidator(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Password string `form:"password"`
+		Confirm  string `form:"confirm"`
+	}
+
+	errMismatch := errors.New("passwords do not match")
+	var calls []string
+	d := form.NewDecoder().Validator(func(i interface{}, values url.Values) error {
+		calls = append(calls, "first")
+		p := i.(*s)
+		if p.Password != p.Confirm {
+			return errMismatch
+		}
+		return nil
+	}).Validator(func(i interface{}, values url.Values) error {
+		calls = append(calls, "second")
+		if values.Has("admin") {
+			return errors.New("unexpected admin key")
+		}
+		return nil
+	})
+
+	r, _ := http.NewRequest(http.MethodGet, "/?password=secret&confirm=secret", nil)
+	if err := d.Unmarshal(r, &s{}); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	if fmt.Sprint(calls) != "[first second]" {
+		t.Fatalf("wrong validator calls. want=%s, got=%v", "[first second]", calls)
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "/?password=secret&confirm=other", nil)
+	if err := d.Unmarshal(r, &s{}); err != errMismatch {
+		t.Fatalf("expected validator error to be returned unchanged. got=%v", err)
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "/?admin=true", nil)
+	if err := d.Unmarshal(r, &s{}); err == nil || err.Error() != "unexpected admin key" {
+		t.Fatalf("expected raw value validator error. got=%v", err)
+	}
+}
+
+func TestDecoderSkipErrors(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Name  string `form:"name,minlen=3"`
+		Age   int    `form:"age"`
+		Email string `form:"email,msg=Please enter your email,pattern=@"`
+		Vals  []int  `form:"vals,count=NVals"`
+		NVals int
+		City  string `form:"city"`
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, "/?name=jo&age=old&email=none&vals=1&vals=x&city=Paris", nil)
+	actual := s{Age: 7}
+	report, err := form.NewDecoder().SkipErrors().UnmarshalReport(r, &actual)
+	if err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	if !reflect.DeepEqual(actual, s{City: "Paris"}) {
+		t.Fatalf("expected skipped fields to be zero. got=%+v", actual)
+	}
+
+	expected := []struct {
+		field, key, msg string
+	}{
+		{"Name", "name", "form: invalid value jo for Go struct field s.Name: length 2 is less than minlen 3"},
+		{"Age", "age", "form: cannot unmarshal old into Go struct field s.Age of type int: strconv.ParseInt: parsing \"old\": invalid syntax"},
+		{"Email", "email", "Please enter your email"},
+		{"Vals", "vals", "form: cannot unmarshal [1, x] into Go struct field s.Vals of type []int: strconv.ParseInt: parsing \"x\": invalid syntax"},
+	}
+	if len(report.Skipped) != len(expected) {
+		t.Fatalf("wrong number of skipped fields. want=%d, got=%d: %+v", len(expected), len(report.Skipped), report.Skipped)
+	}
+	for i, e := range expected {
+		sk := report.Skipped[i]
+		if sk.Field != e.field || sk.Key != e.key || sk.Err.Error() != e.msg {
+			t.Fatalf("wrong skipped field %d. want=%+v, got=%+v", i, e, sk)
+		}
+	}
+	var typeErr *form.UnmarshalTypeError
+	if !errors.As(report.Skipped[1].Err, &typeErr) {
+		t.Fatalf("expected *form.UnmarshalTypeError; got %T", report.Skipped[1].Err)
+	}
+
+	err = form.NewDecoder().SkipErrors().Unmarshal(r, &s{})
+	if err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	err = form.Unmarshal(r, &s{})
+	if err == nil {
+		t.Fatalf("expected fail-fast error without SkipErrors")
+	}
+
+	report, err = form.NewDecoder().UnmarshalReport(r, &s{})
+	if err == nil || report != nil {
+		t.Fatalf("expected error and nil report without SkipErrors. got=%v, %v", report, err)
+	}
+}
+
+func TestUnmarshalReportDurations(t *testing.T) {
+	t.Parallel()
+	type slow string
+	type s struct {
+		Name  string `form:"name"`
+		Slow  slow   `form:"slow"`
+		Slows []slow `form:"slows"`
+	}
+
+	d := form.NewDecoder().RegisterDecoder(reflect.TypeOf(slow("")), func(value string) (interface{}, error) {
+		time.Sleep(2 * time.Millisecond)
+		return slow(value), nil
+	})
+	r, _ := http.NewRequest(http.MethodGet, "/?name=ann&slow=a&slows=b&slows=c", nil)
+	report, err := d.UnmarshalReport(r, &s{})
+	if err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	if len(report.DecoderDurations) != 2 {
+		t.Fatalf("expected durations for the fields with a registered decoder. got=%v", report.DecoderDurations)
+	}
+	if report.DecoderDurations["slow"] < 2*time.Millisecond || report.DecoderDurations["slows"] < 4*time.Millisecond {
+		t.Fatalf("wrong decoder durations. got=%v", report.DecoderDurations)
+	}
+	if report.Duration < report.DecoderDurations["slow"]+report.DecoderDurations["slows"] {
+		t.Fatalf("expected the total duration to include the fields. got=%s", report.Duration)
+	}
+
+	type retry struct {
+		Slow slow `form:"slow"`
+	}
+	type nested struct {
+		Read  retry   `form:"read"`
+		Write retry   `form:"write"`
+		Steps []retry `form:"steps"`
+	}
+	r, _ = http.NewRequest(http.MethodGet, "/?read.slow=a&write.slow=b&steps[1][slow]=c", nil)
+	report, err = d.UnmarshalReport(r, &nested{})
+	if err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	for _, key := range []string{"read.slow", "write.slow", "steps[1].slow"} {
+		if report.DecoderDurations[key] < 2*time.Millisecond {
+			t.Fatalf("expected a duration for %s. got=%v", key, report.DecoderDurations)
+		}
+	}
+	if len(report.DecoderDurations) != 3 {
+		t.Fatalf("expected fields of the same name to be timed apart. got=%v", report.DecoderDurations)
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "/?name=ann", nil)
+	report, err = form.NewDecoder().UnmarshalReport(r, &struct {
+		Name string `form:"name"`
+	}{})
+	if err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	if report.DecoderDurations != nil {
+		t.Fatalf("wrong report without registered decoders. got=%+v", report)
+	}
+}
+
+func TestUnmarshalErrorsField(t *testing.T) {
+	t.Parallel()
+	type fieldErrors map[string]string
+	type s struct {
+		Name   string      `form:"name,minlen=3"`
+		Age    int         `form:"age"`
+		Email  string      `form:"email,pattern=@,msg=Please enter your email"`
+		City   string      `form:"city"`
+		Errors fieldErrors `form:",errors"`
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, "/?name=jo&age=old&email=none&city=Paris", nil)
+	actual := s{Errors: fieldErrors{"stale": "x"}}
+	err := form.NewDecoder().SkipErrors().Unmarshal(r, &actual)
+	if err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	expected := fieldErrors{
+		"name":  "form: invalid value jo for Go struct field s.Name: length 2 is less than minlen 3",
+		"age":   "form: cannot unmarshal old into Go struct field s.Age of type int: strconv.ParseInt: parsing \"old\": invalid syntax",
+		"email": "Please enter your email",
+	}
+	if !reflect.DeepEqual(actual.Errors, expected) {
+		t.Fatalf("wrong errors. want=%v, got=%v", expected, actual.Errors)
+	}
+	if actual.City != "Paris" {
+		t.Fatalf("expected good fields to be bound. got=%+v", actual)
+	}
+
+	actual = s{}
+	err = form.Unmarshal(r, &actual)
+	if err == nil {
+		t.Fatalf("expected fail-fast error")
+	}
+	if len(actual.Errors) != 1 || actual.Errors["name"] != err.Error() {
+		t.Fatalf("expected only the first error. got=%v", actual.Errors)
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "/?name=john", nil)
+	actual = s{Errors: fieldErrors{"stale": "x"}}
+	if err := form.Unmarshal(r, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	if actual.Errors != nil {
+		t.Fatalf("expected nil errors. got=%v", actual.Errors)
+	}
+	testMarshalForm(t, &s{Name: "john", Errors: fieldErrors{"a": "b"}}, "age=0&city=&email=&name=john")
+
+	type invalid struct {
+		Errors map[string]int `form:",errors"`
+	}
+	testUnmarshalFormError(t, "1", &invalid{}, "form: invalid option errors in tag of Go struct field invalid.Errors: option only applies to map[string]string fields, not map[string]int")
+}
+
+func TestUnmarshalRequired(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Name string            `form:"name,required"`
+		Tags []string          `form:"tags,required"`
+		Meta map[string]string `form:"meta,required"`
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, "/?name=john&tags[0]=a&meta[k]=v", nil)
+	var actual s
+	if err := form.Unmarshal(r, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{"tags=a&meta[k]=v", "form: missing value for required Go struct field s.Name"},
+		{"name=&tags=a&meta[k]=v", "form: missing value for required Go struct field s.Name"},
+		{"name=john&tags=&tags=&meta[k]=v", "form: missing value for required Go struct field s.Tags"},
+		{"name=john&tags=a&meta=v", "form: missing value for required Go struct field s.Meta"},
+	}
+	for _, tt := range tests {
+		r, _ := http.NewRequest(http.MethodGet, "/?"+tt.query, nil)
+		err := form.Unmarshal(r, &s{})
+		var missingErr *form.MissingFieldError
+		if !errors.As(err, &missingErr) || err.Error() != tt.expected {
+			t.Fatalf("wrong error for %s. want=%s, got=%v", tt.query, tt.expected, err)
+		}
+	}
+}
+
+func TestUnmarshalRequiredIf(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Payment string `form:"payment"`
+		Pickup  bool   `form:"pickup"`
+		Card    string `form:"card,requiredif=payment=card"`
+		Address string `form:"address,requiredif=pickup=false,msg=Please enter a shipping address"`
+	}
+
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{"payment=card&card=4242&pickup=true", ""},
+		{"payment=cash&pickup=true", ""},
+		{"payment=cash&pickup=false&address=1 Main St", ""},
+		{"payment=card&pickup=true", "form: missing value for Go struct field s.Card, required when payment=card"},
+		{"payment=card&card=&pickup=true", "form: missing value for Go struct field s.Card, required when payment=card"},
+		{"payment=cash", "Please enter a shipping address"},
+	}
+	for _, tt := range tests {
+		r, _ := http.NewRequest(http.MethodGet, "/?"+strings.ReplaceAll(tt.query, " ", "+"), nil)
+		err := form.Unmarshal(r, &s{})
+		if tt.expected == "" && err != nil {
+			t.Fatalf("unexpected unmarshal error for %s: %s", tt.query, err)
+		}
+		if tt.expected != "" && (err == nil || err.Error() != tt.expected) {
+			t.Fatalf("wrong error for %s. want=%s, got=%v", tt.query, tt.expected, err)
+		}
+	}
+
+	type later struct {
+		Card    string `form:"value,requiredif=payment=card"`
+		Payment string `form:"payment"`
+	}
+	type unknown struct {
+		Card string `form:"value,requiredif=payment=card"`
+	}
+	type malformed struct {
+		Card string `form:"value,requiredif=payment"`
+	}
+	testUnmarshalFormError(t, "1", &later{}, "form: invalid option requiredif in tag of Go struct field later.Card: field Payment with key \"payment\" must be declared before the field that depends on it")
+	testUnmarshalFormError(t, "1", &unknown{}, "form: invalid option requiredif in tag of Go struct field unknown.Card: struct has no other field with key \"payment\"")
+	testUnmarshalFormError(t, "1", &malformed{}, "form: invalid option requiredif in tag of Go struct field malformed.Card: condition \"payment\" is not of the form key=value")
+}
+
+func TestUnmarshalGroup(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Kind   string `form:"kind"`
+		Email  string `form:"email,group=kind=email,required,pattern=@"`
+		Phone  string `form:"phone,group=kind=sms,required,maxlen=5"`
+		Digits int    `form:"digits,group=kind=sms"`
+		Note   string `form:"note"`
+	}
+
+	tests := []struct {
+		query    string
+		expected s
+		err      string
+	}{
+		{"kind=email&email=a@b.c&phone=x&digits=99&note=hi", s{Kind: "email", Email: "a@b.c", Note: "hi"}, ""},
+		{"kind=sms&phone=555&digits=10&email=invalid", s{Kind: "sms", Phone: "555", Digits: 10}, ""},
+		{"kind=email", s{}, "form: missing value for required Go struct field s.Email"},
+		{"kind=sms&phone=555555", s{}, "form: invalid value 555555 for Go struct field s.Phone: length 6 is greater than maxlen 5"},
+		{"kind=other&email=invalid&digits=x", s{Kind: "other"}, ""},
+	}
+	for _, tt := range tests {
+		r, _ := http.NewRequest(http.MethodGet, "/?"+tt.query, nil)
+		var actual s
+		err := form.Unmarshal(r, &actual)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Fatalf("wrong error for %s. want=%s, got=%v", tt.query, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("unexpected unmarshal error for %s: %s", tt.query, err)
+		}
+		if actual != tt.expected {
+			t.Fatalf("wrong struct for %s. want=%+v, got=%+v", tt.query, tt.expected, actual)
+		}
+	}
+
+	type later struct {
+		Email string `form:"email,group=kind=email"`
+		Kind  string `form:"kind"`
+	}
+	testUnmarshalFormError(t, "1", &later{}, "form: invalid option group in tag of Go struct field later.Email: field Kind with key \"kind\" must be declared before the field that depends on it")
+}
+
+func TestUnmarshalConditionDecoderKeys(t *testing.T) {
+	t.Parallel()
+	type untagged struct {
+		Payment string
+		Card    string `form:"card,requiredif=Payment=card"`
+	}
+	type fallback struct {
+		Kind  string `json:"kind"`
+		Email string `form:"email,group=kind=email,required"`
+	}
+	type keyed struct {
+		Password string `form:"pw"`
+		Confirm  string `form:",match=password"`
+		Again    string `form:"again,match=pw"`
+	}
+	type unexported struct {
+		ID  int `form:"id,match=ref"`
+		ref int `form:"ref"`
+	}
+	lower := func(fieldName, tag string) string { return strings.ToLower(fieldName) }
+
+	tests := []struct {
+		d        *form.Decoder
+		query    string
+		target   interface{}
+		expected string
+	}{
+		{form.NewDecoder().UntaggedFieldNames(), "Payment=cash", &untagged{}, ""},
+		{form.NewDecoder().UntaggedFieldNames(), "Payment=card", &untagged{}, "form: missing value for Go struct field untagged.Card, required when Payment=card"},
+		{form.NewDecoder().FallbackTag("json"), "kind=sms", &fallback{}, ""},
+		{form.NewDecoder().FallbackTag("json"), "kind=email", &fallback{}, "form: missing value for required Go struct field fallback.Email"},
+		{form.NewDecoder().KeyFunc(lower), "password=a&confirm=a&again=a", &keyed{}, ""},
+		{form.NewDecoder().KeyFunc(lower), "password=a&confirm=b", &keyed{}, "form: invalid value b for Go struct field keyed.Confirm: does not match password=a"},
+		{form.NewDecoder().KeyFunc(lower), "password=a&confirm=a&again=b", &keyed{}, "form: invalid value b for Go struct field keyed.Again: does not match password=a"},
+		{form.NewDecoder(), "pw=a", &keyed{}, "form: invalid option match in tag of Go struct field keyed.Confirm: struct has no other field with key \"password\""},
+		{form.NewDecoder(), "id=1&ref=1", &unexported{}, "form: invalid value 1 for Go struct field unexported.ID: does not match ref=0"},
+	}
+	for _, tt := range tests {
+		r, _ := http.NewRequest(http.MethodGet, "/?"+tt.query, nil)
+		err := tt.d.Unmarshal(r, tt.target)
+		if tt.expected == "" && err != nil {
+			t.Fatalf("unexpected unmarshal error for %s: %s", tt.query, err)
+		}
+		if tt.expected != "" && (err == nil || err.Error() != tt.expected) {
+			t.Fatalf("wrong error for %s. want=%s, got=%v", tt.query, tt.expected, err)
+		}
+	}
+}
+
+func TestUnmarshalStructDefaults(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		_     struct{}  `form:",sep=;,layout:2006-01-02,omitempty"`
+		Tags  []string  `form:"tags"`
+		IDs   []int     `form:"ids,sep=|"`
+		Day   time.Time `form:"day"`
+		Stamp time.Time `form:"stamp,layout=2006-01-02T15:04"`
+		Name  string    `form:"name"`
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, "/?tags=a%3Bb&ids=1|2&day=2024-05-06&stamp=2024-05-06T07:08", nil)
+	var actual s
+	if err := form.Unmarshal(r, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	expected := s{
+		Tags:  []string{"a", "b"},
+		IDs:   []int{1, 2},
+		Day:   time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC),
+		Stamp: time.Date(2024, 5, 6, 7, 8, 0, 0, time.UTC),
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("wrong struct. want=%+v, got=%+v", expected, actual)
+	}
+	testMarshalForm(t, &actual, "day=2024-05-06&ids=1%7C2&stamp=2024-05-06T07%3A08&tags=a%3Bb")
+
+	type fieldOnly struct {
+		_    struct{} `form:",count=N"`
+		Tags []string `form:"tags"`
+		N    int
+	}
+	type invalidValue struct {
+		_    struct{} `form:",minlen=abc"`
+		Name string   `form:"name"`
+	}
+	testUnmarshalFormError(t, "1", &fieldOnly{}, "form: invalid option count in tag of Go struct field fieldOnly._: option cannot be a struct default")
+	testUnmarshalFormError(t, "1", &invalidValue{}, "form: invalid option minlen in tag of Go struct field invalidValue.Name: \"abc\" is not a non-negative integer")
+}
+
+func TestDecoderEmptyValueAsEmptySlice(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Tags   []string  `form:"tags,count=N"`
+		Ids    []int     `form:"ids,sep"`
+		Two    []string  `form:"two"`
+		Array  [1]string `form:"array"`
+		Absent []string  `form:"absent"`
+		N      int
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, "/?tags=&ids=&two=&two=&array=", nil)
+	var actual s
+	if err := form.NewDecoder().EmptyValueAsEmptySlice().Unmarshal(r, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	expected := s{Tags: []string{}, Ids: []int{}, Two: []string{"", ""}}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("wrong struct. want=%#v, got=%#v", expected, actual)
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "/?tags=&two=", nil)
+	actual = s{}
+	if err := form.Unmarshal(r, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	if !reflect.DeepEqual(actual.Tags, []string{""}) || actual.N != 1 {
+		t.Fatalf("expected one empty element by default. got=%#v", actual)
+	}
+
+	type required struct {
+		Tags []string `form:"tags,required"`
+	}
+	r, _ = http.NewRequest(http.MethodGet, "/?tags=", nil)
+	err := form.NewDecoder().EmptyValueAsEmptySlice().Unmarshal(r, &required{})
+	var missingErr *form.MissingFieldError
+	if !errors.As(err, &missingErr) {
+		t.Fatalf("expected MissingFieldError. got=%v", err)
+	}
+}
+
+func TestUnmarshalPreservesOrder(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Slice   []int            `form:"slice"`
+		Array   [4]string        `form:"array"`
+		Sep     []string         `form:"sep,sep"`
+		Indexed []string         `form:"indexed"`
+		Groups  map[string][]int `form:"groups"`
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, "/?slice=3&slice=1&array=d&slice=2&array=b&array=c&array=a&sep=z,x&sep=y&indexed[2]=c&indexed[0]=a&indexed[1]=b&groups[a]=9&groups[a]=7&groups[a]=8", nil)
+	var actual s
+	if err := form.Unmarshal(r, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	expected := s{
+		Slice:   []int{3, 1, 2},
+		Array:   [4]string{"d", "b", "c", "a"},
+		Sep:     []string{"z", "x", "y"},
+		Indexed: []string{"a", "b", "c"},
+		Groups:  map[string][]int{"a": {9, 7, 8}},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("wrong struct. want=%v, got=%v", expected, actual)
+	}
+}
+
+func TestUnmarshalCombinedOptions(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Tags  []string `form:"tags,sep=;,default=a;b"`
+		Name  string   `form:"name,required,msg=Please enter your name\\, first and last"`
+		Expr  string   `form:"expr,default=x=1,maxlen=5"`
+		Pair  [2]int   `form:"pair,sep,default=1\\,2,count=NPair"`
+		NPair int
+		Code  string `form:"code,pattern=^\\d+$,default=007"`
+		Flag  bool   `form:"flag,required,default=true"`
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, "/?name=John+Smith&tags=", nil)
+	var actual s
+	if err := form.Unmarshal(r, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	expected := s{
+		Tags:  []string{"a", "b"},
+		Name:  "John Smith",
+		Expr:  "x=1",
+		Pair:  [2]int{1, 2},
+		NPair: 2,
+		Code:  "007",
+		Flag:  true,
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("wrong struct. want=%+v, got=%+v", expected, actual)
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "/?name=J&tags=c&expr=y%3D22&pair=3,4&code=12&flag=false", nil)
+	actual = s{}
+	if err := form.Unmarshal(r, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	expected = s{Tags: []string{"c"}, Name: "J", Expr: "y=22", Pair: [2]int{3, 4}, NPair: 2, Code: "12"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("wrong struct. want=%+v, got=%+v", expected, actual)
+	}
+
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{"tags=a", "Please enter your name, first and last"},
+		{"name=J&expr=toolong", "form: invalid value toolong for Go struct field s.Expr: length 7 is greater than maxlen 5"},
+		{"name=J&code=x1", "form: invalid value x1 for Go struct field s.Code: value does not match pattern ^\\d+$"},
+	}
+	for _, tt := range tests {
+		r, _ := http.NewRequest(http.MethodGet, "/?"+tt.query, nil)
+		err := form.Unmarshal(r, &s{})
+		if err == nil || err.Error() != tt.expected {
+			t.Fatalf("wrong error for %s. want=%s, got=%v", tt.query, tt.expected, err)
+		}
+	}
+
+	type invalid struct {
+		Meta map[string]string `form:"value,default=x"`
+	}
+	testUnmarshalFormError(t, "1", &invalid{}, "form: invalid option default in tag of Go struct field invalid.Meta: option does not apply to map fields")
+}
+
+func TestDecoderMaxSliceLen(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Ids    []int            `form:"ids"`
+		Pair   [3]int           `form:"pair"`
+		Tags   []string         `form:"tags,sep"`
+		Groups map[string][]int `form:"groups"`
+	}
+
+	d := form.NewDecoder().MaxSliceLen(3).ArrayLengthMode(form.ArrayPad)
+	r, _ := http.NewRequest(http.MethodGet, "/?ids=1&ids[]=2&ids=3&pair=1&tags=a,b,c&groups[a]=1&groups[a][]=2&groups[a]=3", nil)
+	var actual s
+	if err := d.Unmarshal(r, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{"ids=1&ids=2&ids[]=3&ids=4", "form: cannot unmarshal ids into Go struct field s.Ids of type []int: 4 values exceed the maximum of 3"},
+		{"tags=a,b,c,d", "form: cannot unmarshal tags into Go struct field s.Tags of type []string: 4 values exceed the maximum of 3"},
+		{"ids[0]=1&ids[1000000000]=2", "form: cannot unmarshal ids[1000000000] into Go struct field s.Ids of type []int: 1000000001 values exceed the maximum of 3"},
+		{"groups[a]=1&groups[a]=2&groups[a]=3&groups[a][]=4", "form: cannot unmarshal groups[a] into Go struct field s.Groups of type map[string][]int: 4 values exceed the maximum of 3"},
+		{"groups[b]=1&groups[b]=2&groups[b]=3&groups[b]=4", "form: cannot unmarshal groups[b] into Go struct field s.Groups of type map[string][]int: 4 values exceed the maximum of 3"},
+	}
+	for _, tt := range tests {
+		r, _ := http.NewRequest(http.MethodGet, "/?"+tt.query, nil)
+		err := d.Unmarshal(r, &s{})
+		if err == nil || err.Error() != tt.expected {
+			t.Fatalf("wrong error for %s. want=%s, got=%v", tt.query, tt.expected, err)
+		}
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "/?ids=1&ids=2&ids=3&ids=4", nil)
+	if err := form.NewDecoder().Unmarshal(r, &s{}); err != nil {
+		t.Fatalf("expected the default limit to accept a few values. got=%s", err)
+	}
+
+	ids := make(url.Values)
+	ids["ids"] = make([]string, form.DefaultMaxSliceLen+1)
+	r, _ = http.NewRequest(http.MethodGet, "/?"+ids.Encode(), nil)
+	err := form.NewDecoder().Unmarshal(r, &s{})
+	want := fmt.Sprintf("form: cannot unmarshal ids into Go struct field s.Ids of type []int: %d values exceed the maximum of %d", form.DefaultMaxSliceLen+1, form.DefaultMaxSliceLen)
+	if err == nil || err.Error() != want {
+		t.Fatalf("wrong error with the default limit. want=%s, got=%v", want, err)
+	}
+}
+
+func TestDecoderMaxSliceLenSparseIndex(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Items []string `form:"items"`
+	}
+
+	tests := []struct {
+		query    string
+		mode     form.SparseIndex
+		expected string
+	}{
+		{"items[2]=c", form.SparseFill, ""},
+		{"items[0]=a&items[3]=d", form.SparseFill, "form: cannot unmarshal items[3] into Go struct field s.Items of type []string: 4 values exceed the maximum of 3"},
+		{"items[999999999999]=x", form.SparseFill, "form: cannot unmarshal items[999999999999] into Go struct field s.Items of type []string: 1000000000000 values exceed the maximum of 3"},
+		{"items[99999999999999999999]=x", form.SparseFill, "form: cannot unmarshal items[99999999999999999999] into Go struct field s.Items of type []string: invalid index \"99999999999999999999\""},
+		{"items[999999999999]=x", form.SparseError, "form: cannot unmarshal items[999999999999] into Go struct field s.Items of type []string: 1000000000000 values exceed the maximum of 3"},
+	}
+	for _, tt := range tests {
+		r, _ := http.NewRequest(http.MethodGet, "/?"+tt.query, nil)
+		var actual s
+		err := form.NewDecoder().MaxSliceLen(3).SparseIndexMode(tt.mode).Unmarshal(r, &actual)
+		if tt.expected == "" {
+			if err != nil {
+				t.Fatalf("unexpected unmarshal error for %s: %s", tt.query, err)
+			}
+			if !reflect.DeepEqual(actual.Items, []string{"", "", "c"}) {
+				t.Fatalf("expected the gap to be filled. got=%q", actual.Items)
+			}
+			continue
+		}
+		if err == nil || err.Error() != tt.expected {
+			t.Fatalf("wrong error for %s. want=%s, got=%v", tt.query, tt.expected, err)
+		}
+	}
+}
+
+func TestUnmarshalIndexDefaultLimit(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Items []string `form:"items"`
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, "/?items[99999999999]=x", nil)
+	var actual s
+	err := form.NewDecoder().Unmarshal(r, &actual)
+	want := fmt.Sprintf("form: cannot unmarshal items[99999999999] into Go struct field s.Items of type []string: 100000000000 values exceed the maximum of %d", form.DefaultMaxSliceLen)
+	if err == nil || err.Error() != want {
+		t.Fatalf("wrong error without a configured limit. want=%s, got=%v", want, err)
+	}
+	if actual.Items != nil {
+		t.Fatalf("expected nothing to be allocated. got len=%d", len(actual.Items))
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, fmt.Sprintf("/?items[%d]=x", math.MaxInt), nil)
+	err = form.NewDecoder().MaxSliceLen(0).Unmarshal(r, &actual)
+	want = fmt.Sprintf("form: cannot unmarshal items[%d] into Go struct field s.Items of type []string: invalid index \"%d\"", math.MaxInt, math.MaxInt)
+	if err == nil || err.Error() != want {
+		t.Fatalf("wrong error for the largest index without a limit. want=%s, got=%v", want, err)
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, fmt.Sprintf("/?items[%d]=x", form.DefaultMaxSliceLen-1), nil)
+	if err := form.NewDecoder().Unmarshal(r, &actual); err != nil || len(actual.Items) != form.DefaultMaxSliceLen {
+		t.Fatalf("expected the largest index under the default limit to bind. got len=%d, err=%v", len(actual.Items), err)
+	}
+
+	type arrays struct {
+		Counts  [20000]int            `form:"counts"`
+		Sparse  [20000]byte           `form:"sparse"`
+		Grouped map[string][20000]int `form:"grouped"`
+	}
+	values := url.Values{}
+	for range 20000 {
+		values.Add("counts", "1")
+		values.Add("grouped[a]", "2")
+	}
+	values.Set("sparse[19999]", "3")
+	r, _ = http.NewRequest(http.MethodGet, "/?"+values.Encode(), nil)
+	var a arrays
+	if err := form.NewDecoder().ArrayLengthMode(form.ArrayPad).Unmarshal(r, &a); err != nil {
+		t.Fatalf("expected arrays longer than the default limit to bind. got err=%v", err)
+	}
+	if a.Counts[19999] != 1 || a.Sparse[19999] != 3 || a.Grouped["a"][19999] != 2 {
+		t.Fatalf("wrong array values. got counts=%d, sparse=%d, grouped=%d", a.Counts[19999], a.Sparse[19999], a.Grouped["a"][19999])
+	}
+}
+
+func TestSetMaxSliceLen(t *testing.T) {
+	type s struct {
+		Ids []int `form:"ids"`
+	}
+	form.SetMaxSliceLen(2)
+	defer form.SetMaxSliceLen(form.DefaultMaxSliceLen)
+
+	r, _ := http.NewRequest(http.MethodGet, "/?ids=1&ids=2&ids=3", nil)
+	err := form.Unmarshal(r, &s{})
+	if err == nil || err.Error() != "form: cannot unmarshal ids into Go struct field s.Ids of type []int: 3 values exceed the maximum of 2" {
+		t.Fatalf("wrong error with global limit. got=%v", err)
+	}
+	if err := form.NewDecoder().MaxSliceLen(0).Unmarshal(r, &s{}); err != nil {
+		t.Fatalf("expected Decoder limit to take precedence. got=%s", err)
+	}
+}
+
+func TestDecoderValidateOnly(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Name string `form:"name,required,minlen=2"`
+		Age  int    `form:"age"`
+	}
+
+	var calls int
+	d := form.NewDecoder().AllowJSON().Validator(func(i interface{}, values url.Values) error {
+		calls++
+		if i.(*s).Age < 18 {
+			return errors.New("too young")
+		}
+		return nil
+	})
+
+	original := s{Name: "unchanged", Age: 99}
+	r, _ := http.NewRequest(http.MethodGet, "/?name=John&age=30", nil)
+	if err := d.ValidateOnly(r, &original); err != nil {
+		t.Fatalf("unexpected validation error: %s", err)
+	}
+	if original != (s{Name: "unchanged", Age: 99}) {
+		t.Fatalf("expected struct to be unchanged. got=%+v", original)
+	}
+	if calls != 1 {
+		t.Fatalf("expected validator to be called once. got=%d", calls)
+	}
+
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{"age=30", "form: missing value for required Go struct field s.Name"},
+		{"name=J&age=30", "form: invalid value J for Go struct field s.Name: length 1 is less than minlen 2"},
+		{"name=John&age=x", "form: cannot unmarshal x into Go struct field s.Age of type int: strconv.ParseInt: parsing \"x\": invalid syntax"},
+		{"name=John&age=12", "too young"},
+	}
+	for _, tt := range tests {
+		r, _ := http.NewRequest(http.MethodGet, "/?"+tt.query, nil)
+		err := d.ValidateOnly(r, (*s)(nil))
+		if err == nil || err.Error() != tt.expected {
+			t.Fatalf("wrong error for %s. want=%s, got=%v", tt.query, tt.expected, err)
+		}
+	}
+
+	r, _ = http.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name": "John", "age": 30}`))
+	r.Header.Add("Content-Type", "application/json")
+	if err := d.ValidateOnly(r, &original); err != nil {
+		t.Fatalf("unexpected validation error: %s", err)
+	}
+	var actual s
+	if err := d.Unmarshal(r, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error after ValidateOnly: %s", err)
+	}
+	if actual != (s{Name: "John", Age: 30}) {
+		t.Fatalf("wrong struct after ValidateOnly. got=%+v", actual)
+	}
+
+	err := d.ValidateOnly(r, s{})
+	if err == nil || err.Error() != "form: Unmarshal(non-pointer form_test.s)" {
+		t.Fatalf("wrong error for non-pointer. got=%v", err)
+	}
+}
+
+func TestUnmarshalUntaggedFields(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Name     string
+		Required string `form:",required"`
+		Tagged   string `form:"tagged"`
+		hidden   string
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, "/?=x&tagged=y", nil)
+	var actual s
+	if err := form.Unmarshal(r, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	if actual != (s{Tagged: "y"}) {
+		t.Fatalf("a value under an empty key should not bind untagged fields. got=%+v", actual)
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "/?Name=ann&Required=z&tagged=y&hidden=h", nil)
+	actual = s{}
+	if err := form.NewDecoder().UntaggedFieldNames().Unmarshal(r, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	if actual != (s{Name: "ann", Required: "z", Tagged: "y"}) {
+		t.Fatalf("wrong struct. got=%+v", actual)
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "/?tagged=y", nil)
+	err := form.NewDecoder().UntaggedFieldNames().Unmarshal(r, &s{})
+	var missingErr *form.MissingFieldError
+	if !errors.As(err, &missingErr) || missingErr.Field != "Required" {
+		t.Fatalf("expected MissingFieldError for Required, got=%v", err)
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "/", nil)
+	if err := form.NewEncoder().UntaggedFieldNames().Marshal(r, &actual); err != nil {
+		t.Fatalf("unexpected marshal error: %s", err)
+	}
+	if r.URL.RawQuery != "Name=ann&Required=z&tagged=y" {
+		t.Fatalf("wrong query. got=%s", r.URL.RawQuery)
+	}
+	testMarshalForm(t, &actual, "tagged=y")
+}
+
+func TestDecoderFallbackTag(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Name     string   `json:"name"`
+		Email    string   `json:"email,omitempty" form:"contact"`
+		Age      int      `json:"age" form:",required"`
+		Tags     []string `json:"tags,omitempty"`
+		Secret   string   `json:"-"`
+		Untagged string
+	}
+
+	d := form.NewDecoder().FallbackTag("json")
+	r, _ := http.NewRequest(http.MethodGet, "/?name=ann&email=a@x.io&contact=c@x.io&age=30&tags=a&tags=b&Secret=x&secret=y", nil)
+	var actual s
+	if err := d.Unmarshal(r, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	expected := s{Name: "ann", Email: "c@x.io", Age: 30, Tags: []string{"a", "b"}}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("wrong struct. want=%+v, got=%+v", expected, actual)
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "/?name=ann", nil)
+	err := d.Unmarshal(r, &s{})
+	var missingErr *form.MissingFieldError
+	if !errors.As(err, &missingErr) || missingErr.Key != "age" {
+		t.Fatalf("expected MissingFieldError for age. got=%v", err)
+	}
+
+	var plain struct {
+		Name string `json:"name"`
+	}
+	r, _ = http.NewRequest(http.MethodGet, "/?name=ann", nil)
+	if err := form.Unmarshal(r, &plain); err != nil || plain.Name != "" {
+		t.Fatalf("expected json tags to be ignored by default. got=%+v, err=%v", plain, err)
+	}
+}
+
+func TestDecoderKeyFunc(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Name   string   `form:"name,minlen=2"`
+		Tags   []string `form:"tags"`
+		Nick   string
+		Errors map[string]string `form:",errors"`
+	}
+
+	d := form.NewDecoder().KeyFunc(func(fieldName, tag string) string {
+		if tag == "" {
+			tag = strings.ToLower(fieldName)
+		}
+		return "v2_" + tag
+	})
+	r, _ := http.NewRequest(http.MethodGet, "/?v2_name=John&name=ignored&v2_tags[1]=b&v2_tags[0]=a&v2_nick=JJ", nil)
+	var actual s
+	if err := d.Unmarshal(r, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	expected := s{Name: "John", Tags: []string{"a", "b"}, Nick: "JJ"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("wrong struct. want=%+v, got=%+v", expected, actual)
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "/?v2_name=J", nil)
+	actual = s{}
+	err := d.Unmarshal(r, &actual)
+	var validationErr *form.ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Key != "v2_name" {
+		t.Fatalf("expected error for key v2_name. got=%v", err)
+	}
+	if _, ok := actual.Errors["v2_name"]; !ok {
+		t.Fatalf("expected errors field keyed by v2_name. got=%v", actual.Errors)
+	}
+}
+
+func TestUnmarshalSeparatedBools(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Flags []bool `form:"flags,sep"`
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, "/?flags=true,false,1", nil)
+	var actual s
+	err := form.Unmarshal(r, &actual)
+	if err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	if fmt.Sprint(actual.Flags) != "[true false true]" {
+		t.Fatalf("wrong flags. want=%s, got=%v", "[true false true]", actual.Flags)
+	}
+
+	type value struct {
+		Val []bool `form:"value,sep"`
+	}
+	r, _ = http.NewRequest(http.MethodGet, "/?value=true%2Cmaybe", nil)
+	err = form.Unmarshal(r, &value{})
+	expected := "form: cannot unmarshal [true, maybe] into Go struct field value.Val of type []bool: strconv.ParseBool: parsing \"maybe\": invalid syntax"
+	if err == nil || err.Error() != expected {
+		t.Fatalf("wrong error. want=%s, got=%v", expected, err)
+	}
+}
+
+func TestUnmarshalSeparator(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Tags  []string `form:"tags,sep=;"`
+		Pair  [2]int   `form:"pair,sep"`
+		Multi []int    `form:"multi,sep"`
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, "/?tags=a%3Bb,c&pair=1,2&multi=1,2&multi=3", nil)
+	var actual s
+	err := form.Unmarshal(r, &actual)
+	if err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	if fmt.Sprintf("%q", actual.Tags) != `["a" "b,c"]` {
+		t.Fatalf("wrong tags. want=%s, got=%q", `["a" "b,c"]`, actual.Tags)
+	}
+	if actual.Pair != [2]int{1, 2} {
+		t.Fatalf("wrong pair. want=%v, got=%v", [2]int{1, 2}, actual.Pair)
+	}
+	if fmt.Sprint(actual.Multi) != "[1 2 3]" {
+		t.Fatalf("wrong multi. want=%s, got=%v", "[1 2 3]", actual.Multi)
+	}
+}
+
+func TestUnmarshalSeparatorEmptyElements(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Tags []string `form:"tags,sep"`
+	}
+
+	tests := []struct {
+		value    string
+		keep     bool
+		expected []string
+	}{
+		{"a,b,", false, []string{"a", "b"}},
+		{"a,b,,", false, []string{"a", "b"}},
+		{",a,,b", false, []string{"", "a", "", "b"}},
+		{",", false, nil},
+		{"a,b,", true, []string{"a", "b", ""}},
+		{"a,b,,", true, []string{"a", "b", "", ""}},
+		{",a,,b", true, []string{"", "a", "", "b"}},
+		{",", true, []string{"", ""}},
+	}
+	for _, tt := range tests {
+		r, _ := http.NewRequest(http.MethodGet, "/?tags="+tt.value, nil)
+		d := form.NewDecoder()
+		if tt.keep {
+			d.KeepTrailingEmpty()
+		}
+		var actual s
+		if err := d.Unmarshal(r, &actual); err != nil {
+			t.Fatalf("unexpected unmarshal error for %s: %s", tt.value, err)
+		}
+		if !reflect.DeepEqual(actual.Tags, tt.expected) {
+			t.Fatalf("wrong tags for %s with keep=%t. want=%q, got=%q", tt.value, tt.keep, tt.expected, actual.Tags)
+		}
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, "/?tags=a,&tags=b,", nil)
+	var actual s
+	if err := form.Unmarshal(r, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	if !reflect.DeepEqual(actual.Tags, []string{"a", "b"}) {
+		t.Fatalf("expected trailing empty elements of each value to be dropped. got=%q", actual.Tags)
+	}
+}
+
+func TestUnmarshalSeparatorKinds(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Uints    []uint          `form:"uints,sep"`
+		Floats   [3]float32      `form:"floats,sep=|"`
+		Complex  []complex128    `form:"complex,sep"`
+		Bools    []bool          `form:"bools,sep=;"`
+		Dates    []time.Time     `form:"dates,sep,layout=2006-01-02"`
+		Timeouts []time.Duration `form:"timeouts,sep"`
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, "/?uints=1,2,3&floats=1.5|-2|3e2&complex=1%2B2i,3&bools=true%3B0&dates=2024-01-02,2024-12-31&timeouts=1s,1h30m", nil)
+	var actual s
+	if err := form.Unmarshal(r, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	expected := s{
+		Uints:   []uint{1, 2, 3},
+		Floats:  [3]float32{1.5, -2, 300},
+		Complex: []complex128{1 + 2i, 3},
+		Bools:   []bool{true, false},
+		Dates: []time.Time{
+			time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+			time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC),
+		},
+		Timeouts: []time.Duration{time.Second, 90 * time.Minute},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("wrong struct. want=%v, got=%v", expected, actual)
+	}
+
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{"uints=1,-2", "form: cannot unmarshal [1, -2] into Go struct field s.Uints of type []uint: strconv.ParseUint: parsing \"-2\": invalid syntax"},
+		{"floats=1|x|3", "form: cannot unmarshal [1, x, 3] into Go struct field s.Floats of type [3]float32: strconv.ParseFloat: parsing \"x\": invalid syntax"},
+		{"complex=1,i2", "form: cannot unmarshal [1, i2] into Go struct field s.Complex of type []complex128: strconv.ParseComplex: parsing \"i2\": invalid syntax"},
+		{"dates=2024-01-02,01/02/2024", "form: cannot unmarshal [2024-01-02, 01/02/2024] into Go struct field s.Dates of type []time.Time: parsing time \"01/02/2024\" as \"2006-01-02\": cannot parse \"01/02/2024\" as \"2006\""},
+	}
+	for _, tt := range tests {
+		r, _ := http.NewRequest(http.MethodGet, "/?"+tt.query, nil)
+		err := form.Unmarshal(r, &s{})
+		if err == nil || err.Error() != tt.expected {
+			t.Fatalf("wrong error for %s. want=%s, got=%v", tt.query, tt.expected, err)
+		}
+	}
+}
+
+func TestUnmarshalTime(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Created time.Time `form:"created"`
+		Date    time.Time `form:"date,layout=2006-01-02"`
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, "/?created=2024-03-04T05:06:07%2B02:00&date=2024-03-04", nil)
+	var actual s
+	if err := form.Unmarshal(r, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	if !actual.Created.Equal(time.Date(2024, 3, 4, 3, 6, 7, 0, time.UTC)) {
+		t.Fatalf("wrong created. got=%s", actual.Created)
+	}
+	if !actual.Date.Equal(time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("wrong date. got=%s", actual.Date)
+	}
+
+	type invalid struct {
+		Val string `form:"value,layout=2006"`
+	}
+	testUnmarshalFormError(t, "2024", &invalid{}, "form: invalid option layout in tag of Go struct field invalid.Val: option only applies to time.Time fields, not string")
+}
+
+func TestDecoderDecimalComma(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Price  float64   `form:"price"`
+		Rate   float32   `form:"rate"`
+		Prices []float64 `form:"prices,sep=;"`
+		Amount int       `form:"amount"`
+	}
+
+	d := form.NewDecoder().DecimalComma()
+	r, _ := http.NewRequest(http.MethodGet, "/?price=10%2C49&rate=0.5&prices=1%2C5%3B2%2C25&amount=3", nil)
+	var actual s
+	if err := d.Unmarshal(r, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	expected := s{Price: 10.49, Rate: 0.5, Prices: []float64{1.5, 2.25}, Amount: 3}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("wrong struct. want=%v, got=%v", expected, actual)
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "/?price=1.234%2C5", nil)
+	err := d.Unmarshal(r, &s{})
+	if err == nil || err.Error() != "form: cannot unmarshal 1.234,5 into Go struct field s.Price of type float64: strconv.ParseFloat: parsing \"1.234.5\": invalid syntax" {
+		t.Fatalf("wrong error. got=%v", err)
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "/?price=10%2C49", nil)
+	err = form.Unmarshal(r, &s{})
+	if err == nil {
+		t.Fatalf("expected error for decimal comma without DecimalComma")
+	}
+
+	type commaSep struct {
+		Vals []float64 `form:"vals,sep"`
+	}
+	r, _ = http.NewRequest(http.MethodGet, "/?vals=1%2C5", nil)
+	err = d.Unmarshal(r, &commaSep{})
+	if err == nil || err.Error() != "form: cannot unmarshal vals into Go struct field commaSep.Vals of type []float64: sep cannot split around commas when DecimalComma is set" {
+		t.Fatalf("wrong error for comma separator. got=%v", err)
+	}
+}
+
+func TestUnmarshalPointerTime(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Date    *time.Time   `form:"date,layout=2006-01-02"`
+		Created *time.Time   `form:"created"`
+		Times   []*time.Time `form:"times"`
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, "/?date=2024-03-04&times=2024-01-02T00:00:00Z", nil)
+	var actual s
+	if err := form.Unmarshal(r, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	expected := time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)
+	if actual.Date == nil || !actual.Date.Equal(expected) {
+		t.Fatalf("wrong date. want=%s, got=%v", expected, actual.Date)
+	}
+	if actual.Created != nil {
+		t.Fatalf("expected absent key to leave pointer nil. got=%v", actual.Created)
+	}
+	if len(actual.Times) != 1 || actual.Times[0] == nil || actual.Times[0].Year() != 2024 {
+		t.Fatalf("wrong times. got=%v", actual.Times)
+	}
+	testMarshalForm(t, &actual, "date=2024-03-04&times=2024-01-02T00%3A00%3A00Z")
+
+	testUnmarshalFormError(t, "March 4", &struct {
+		Date *time.Time `form:"value"`
+	}{}, "form: cannot unmarshal March 4 into Go struct field .Date of type *time.Time: parsing time \"March 4\" as \"2006-01-02T15:04:05Z07:00\": cannot parse \"March 4\" as \"2006\"")
+}
+
+func TestUnmarshalPointers(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Int   *int      `form:"int"`
+		Bool  *bool     `form:"bool"`
+		Name  *string   `form:"name"`
+		Tags  *[]string `form:"tags"`
+		Ids   []*int    `form:"ids"`
+		Empty *int      `form:"empty"`
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, "/?int=0&bool=false&name=&tags=a&tags=b&ids=1&ids=2", nil)
+	var actual s
+	if err := form.Unmarshal(r, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	if actual.Int == nil || *actual.Int != 0 || actual.Bool == nil || *actual.Bool || actual.Name == nil || *actual.Name != "" {
+		t.Fatalf("expected present zero values to be allocated. got=%+v", actual)
+	}
+	if actual.Tags == nil || !reflect.DeepEqual(*actual.Tags, []string{"a", "b"}) {
+		t.Fatalf("wrong tags. got=%v", actual.Tags)
+	}
+	if len(actual.Ids) != 2 || *actual.Ids[0] != 1 || *actual.Ids[1] != 2 {
+		t.Fatalf("wrong ids. got=%v", actual.Ids)
+	}
+	if actual.Empty != nil {
+		t.Fatalf("expected absent key to leave pointer nil. got=%v", *actual.Empty)
+	}
+
+	testUnmarshalFormError(t, "x", &struct {
+		Int *int8 `form:"value"`
+	}{}, "form: cannot unmarshal x into Go struct field .Int of type *int8: strconv.ParseInt: parsing \"x\": invalid syntax")
+}
+
+func TestUnmarshalTimeLayouts(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Date  time.Time   `form:"date,layout:2006-01-02|2006/01/02|02-01-2006"`
+		Dates []time.Time `form:"dates,layout:15:04|3:04PM"`
+	}
+
+	expected := time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)
+	for _, value := range []string{"2024-03-04", "2024/03/04", "04-03-2024"} {
+		r, _ := http.NewRequest(http.MethodGet, "/?date="+value, nil)
+		var actual s
+		if err := form.Unmarshal(r, &actual); err != nil {
+			t.Fatalf("unexpected unmarshal error for %s: %s", value, err)
+		}
+		if !actual.Date.Equal(expected) {
+			t.Fatalf("wrong date for %s. want=%s, got=%s", value, expected, actual.Date)
+		}
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, "/?dates=13:30&dates=1:30PM", nil)
+	var actual s
+	if err := form.Unmarshal(r, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	if len(actual.Dates) != 2 || !actual.Dates[0].Equal(actual.Dates[1]) || actual.Dates[0].Hour() != 13 {
+		t.Fatalf("wrong dates. got=%v", actual.Dates)
+	}
+
+	testUnmarshalFormError(t, "March 4", &struct {
+		Date time.Time `form:"value,layout:2006-01-02|2006/01/02"`
+	}{}, "form: cannot unmarshal March 4 into Go struct field .Date of type time.Time: parsing time \"March 4\": does not match any of the layouts 2006-01-02, 2006/01/02")
+	testUnmarshalFormError(t, "2024", &struct {
+		Date time.Time `form:"value,layout:2006-01-02|"`
+	}{}, "form: invalid option layout in tag of Go struct field .Date: layout cannot be empty")
+}
+
+func TestInvalidSeparatorTag(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Val bool `form:"value,sep"`
+	}
+
+	testUnmarshalFormError(t, "true", &s{}, "form: invalid option sep in tag of Go struct field s.Val: option only applies to slice and array fields, not bool")
+}
+
+func TestUnmarshalDurationError(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Val time.Duration `form:"value"`
+	}
+	type seconds struct {
+		Val time.Duration `form:"value,unit=s"`
+	}
+	type invalidUnit struct {
+		Val time.Duration `form:"value,unit=days"`
+	}
+	type notDuration struct {
+		Val int64 `form:"value,unit=s"`
+	}
+
+	testUnmarshalFormError(t, "5 minutes", &s{}, "form: cannot unmarshal 5 minutes into Go struct field s.Val of type time.Duration: time: unknown unit \" minutes\" in duration \"5 minutes\"")
+	testUnmarshalFormError(t, "1h", &seconds{}, "form: cannot unmarshal 1h into Go struct field seconds.Val of type time.Duration: strconv.ParseInt: parsing \"1h\": invalid syntax")
+	testUnmarshalFormError(t, "9223372036854775807", &seconds{}, "form: cannot unmarshal 9223372036854775807 into Go struct field seconds.Val of type time.Duration: 9223372036854775807 overflows time.Duration value")
+	testUnmarshalFormError(t, "1", &invalidUnit{}, "form: invalid option unit in tag of Go struct field invalidUnit.Val: unknown unit \"days\"")
+	testUnmarshalFormError(t, "1", &notDuration{}, "form: invalid option unit in tag of Go struct field notDuration.Val: option only applies to time.Duration fields, not int64")
+}
+
+func TestDecode(t *testing.T) {
+	t.Parallel()
+	type person struct {
+		Name string `form:"name"`
+		Age  int    `form:"age"`
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, "/?name=John&age=24", nil)
+	p, err := form.Decode[person](r)
+	if err != nil {
+		t.Fatalf("unexpected decode error: %s", err)
+	}
+	if p != (person{Name: "John", Age: 24}) {
+		t.Fatalf("wrong person. want=%+v, got=%+v", person{Name: "John", Age: 24}, p)
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "/?name=John&age=old", nil)
+	p, err = form.Decode[person](r)
+	var typeErr *form.UnmarshalTypeError
+	if !errors.As(err, &typeErr) {
+		t.Fatalf("expected *form.UnmarshalTypeError; got %T", err)
+	}
+	if p != (person{}) {
+		t.Fatalf("expected zero person on error. got=%+v", p)
+	}
+
+	_, err = form.Decode[int](r)
+	if err == nil || err.Error() != "form: Unmarshal(nil *int)" {
+		t.Fatalf("wrong error for non-struct type. got=%v", err)
+	}
+}
+
+func TestUnmarshalArrayPad(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Vals  [4]int `form:"vals,count=NVals"`
+		NVals int
+		Tags  []string `form:"tags,sep,count=NTags"`
+		NTags uint8
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, "/?vals=0&vals=7&tags=a,b,c", nil)
+	var actual s
+	err := form.NewDecoder().ArrayLengthMode(form.ArrayPad).Unmarshal(r, &actual)
+	if err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	if actual.Vals != [4]int{0, 7, 0, 0} {
+		t.Fatalf("wrong vals. want=%v, got=%v", [4]int{0, 7, 0, 0}, actual.Vals)
+	}
+	if actual.NVals != 2 {
+		t.Fatalf("wrong vals count. want=%d, got=%d", 2, actual.NVals)
+	}
+	if actual.NTags != 3 {
+		t.Fatalf("wrong tags count. want=%d, got=%d", 3, actual.NTags)
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "/?vals=1&vals=2&vals=3&vals=4&vals=5", nil)
+	err = form.NewDecoder().ArrayLengthMode(form.ArrayPad).Unmarshal(r, &actual)
+	if err == nil || err.Error() != "form: cannot unmarshal [1, 2, 3, 4, 5] into Go struct field s.Vals of type [4]int: cannot use [5]int as [4]int value in struct" {
+		t.Fatalf("expected error for too many values. got=%v", err)
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "/?vals=1", nil)
+	err = form.Unmarshal(r, &actual)
+	if err == nil || err.Error() != "form: cannot unmarshal [1] into Go struct field s.Vals of type [4]int: cannot use [1]int as [4]int value in struct" {
+		t.Fatalf("expected error for too few values without ArrayPad. got=%v", err)
+	}
+}
+
+func TestInvalidCountTag(t *testing.T) {
+	t.Parallel()
+	type missing struct {
+		Val []int `form:"value,count=N"`
+	}
+	type notInt struct {
+		Val []int `form:"value,count=N"`
+		N   string
+	}
+	type notSlice struct {
+		Val int `form:"value,count=N"`
+		N   int
+	}
+
+	testUnmarshalFormError(t, "1", &missing{}, "form: invalid option count in tag of Go struct field missing.Val: struct has no field \"N\"")
+	testUnmarshalFormError(t, "1", &notInt{}, "form: invalid option count in tag of Go struct field notInt.Val: field N has type string, not an integer type")
+	testUnmarshalFormError(t, "1", &notSlice{}, "form: invalid option count in tag of Go struct field notSlice.Val: option only applies to slice and array fields, not int")
+}
+
+func TestUnmarshalMap(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Meta   map[string]string            `form:"meta"`
+		Config map[string]map[string]string `form:"config"`
+		Counts map[string]int               `form:"counts"`
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, "/?meta[color]=red&meta[size]=L&config[db][host]=localhost&config[db][port]=5432&config[cache][host]=redis&counts[a]=1&meta=ignored", nil)
+	var actual s
+	err := form.Unmarshal(r, &actual)
+	if err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+
+	expected := s{
+		Meta: map[string]string{"color": "red", "size": "L"},
+		Config: map[string]map[string]string{
+			"db":    {"host": "localhost", "port": "5432"},
+			"cache": {"host": "redis"},
+		},
+		Counts: map[string]int{"a": 1},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("wrong maps. want=%v, got=%v", expected, actual)
+	}
+}
+
+func TestUnmarshalMapOfSlices(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Groups map[string][]int               `form:"groups"`
+		Pairs  map[string][2]string           `form:"pairs"`
+		Nested map[string]map[string][]string `form:"nested"`
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, "/?groups[a]=1&groups[a]=2&groups[b]=3&groups[a][]=4&pairs[x]=l&pairs[x]=r&nested[n][m]=p&nested[n][m][]=q", nil)
+	var actual s
+	if err := form.Unmarshal(r, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	expected := s{
+		Groups: map[string][]int{"a": {1, 2, 4}, "b": {3}},
+		Pairs:  map[string][2]string{"x": {"l", "r"}},
+		Nested: map[string]map[string][]string{"n": {"m": {"p", "q"}}},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("wrong struct. want=%v, got=%v", expected, actual)
+	}
+
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{"groups[a]=1&groups[b]=2&groups[b]=x", "form: cannot unmarshal groups[b]=x into Go struct field s.Groups of type map[string][]int: strconv.ParseInt: parsing \"x\": invalid syntax"},
+		{"groups[a][0]=1", "form: cannot unmarshal groups[a][0] into Go struct field s.Groups of type map[string][]int: key has 2 levels of brackets but map[string][]int needs 1"},
+		{"pairs[x]=l", "form: cannot unmarshal pairs[x]=[l] into Go struct field s.Pairs of type map[string][2]string: cannot use [1]string as [2]string value in struct"},
+	}
+	for _, tt := range tests {
+		r, _ := http.NewRequest(http.MethodGet, "/?"+tt.query, nil)
+		err := form.Unmarshal(r, &s{})
+		if err == nil || err.Error() != tt.expected {
+			t.Fatalf("wrong error for %s. want=%s, got=%v", tt.query, tt.expected, err)
+		}
+	}
+}
+
+func TestUnmarshalSubForm(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Extra url.Values `form:"extra"`
+		Name  string     `form:"name"`
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, "/?name=a&extra.color=red&extra[size]=m&extra[size]=l&extra[tags][]=x&extra.a.b=c&extra=ignored", nil)
+	var actual s
+	if err := form.Unmarshal(r, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	expected := s{
+		Extra: url.Values{"color": {"red"}, "size": {"m", "l"}, "tags[]": {"x"}, "a.b": {"c"}},
+		Name:  "a",
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("wrong struct. want=%v, got=%v", expected, actual)
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "/", nil)
+	if err := form.Marshal(r, &actual); err != nil {
+		t.Fatalf("unexpected marshal error: %s", err)
+	}
+	if r.URL.RawQuery != "extra%5Ba.b%5D=c&extra%5Bcolor%5D=red&extra%5Bsize%5D=m&extra%5Bsize%5D=l&extra%5Btags%5D%5B%5D=x&name=a" {
+		t.Fatalf("wrong query. got=%s", r.URL.RawQuery)
+	}
+	var roundTrip s
+	if err := form.Unmarshal(r, &roundTrip); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	if !reflect.DeepEqual(roundTrip, expected) {
+		t.Fatalf("sub-form did not round trip. want=%v, got=%v", expected, roundTrip)
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "/?extra[a=1", nil)
+	err := form.Unmarshal(r, &s{})
+	if err == nil || err.Error() != "form: cannot unmarshal extra[a into Go struct field s.Extra of type url.Values: mismatched brackets" {
+		t.Fatalf("wrong error for mismatched brackets. got=%v", err)
+	}
+}
+
+func TestDecoderNestingDot(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Meta   map[string]string            `form:"meta"`
+		Config map[string]map[string]string `form:"config"`
+		Groups map[string][]int             `form:"groups"`
+		Items  []string                     `form:"items"`
+	}
+
+	d := form.NewDecoder().NestingMode(form.NestingDot)
+	r, _ := http.NewRequest(http.MethodGet, "/?meta.color=red&meta[size]=m&config.db.host=x&groups.a=1&groups.a=2&items[1]=b&items[0]=a", nil)
+	var actual s
+	if err := d.Unmarshal(r, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	expected := s{
+		Meta:   map[string]string{"color": "red"},
+		Config: map[string]map[string]string{"db": {"host": "x"}},
+		Groups: map[string][]int{"a": {1, 2}},
+		Items:  []string{"a", "b"},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("wrong struct. want=%v, got=%v", expected, actual)
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "/?config.db=x", nil)
+	err := d.Unmarshal(r, &s{})
+	if err == nil || err.Error() != "form: cannot unmarshal config.db into Go struct field s.Config of type map[string]map[string]string: key has 1 levels of dots but map[string]map[string]string needs 2" {
+		t.Fatalf("wrong error for missing level. got=%v", err)
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "/?meta.color=red", nil)
+	actual = s{}
+	if err := form.Unmarshal(r, &actual); err != nil || actual.Meta != nil {
+		t.Fatalf("expected dot keys to be ignored by default. got=%v, err=%v", actual.Meta, err)
+	}
+}
+
+func TestUnmarshalMapError(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Config map[string]map[string]string `form:"config"`
+		Counts map[string]int               `form:"counts"`
+	}
+	type deep struct {
+		Deep map[string]map[string]map[string]string `form:"deep"`
+	}
+
+	tests := []struct {
+		query    string
+		target   interface{}
+		expected string
+	}{
+		{"config[db]=x", &s{}, "form: cannot unmarshal config[db] into Go struct field s.Config of type map[string]map[string]string: key has 1 levels of brackets but map[string]map[string]string needs 2"},
+		{"config[db][host", &s{}, "form: cannot unmarshal config[db][host into Go struct field s.Config of type map[string]map[string]string: mismatched brackets"},
+		{"config[db]x[host]", &s{}, "form: cannot unmarshal config[db]x[host] into Go struct field s.Config of type map[string]map[string]string: unexpected \"x[host]\" outside of brackets"},
+		{"counts[a]=one", &s{}, "form: cannot unmarshal counts[a]=one into Go struct field s.Counts of type map[string]int: strconv.ParseInt: parsing \"one\": invalid syntax"},
+		{"deep[a][b][c]=x", &deep{}, "form: cannot unmarshal deep into Go struct field deep.Deep of type map[string]map[string]map[string]string: map[string]map[string]map[string]string has 3 levels of nested maps, more than the maximum of 2"},
+	}
+
+	for _, tt := range tests {
+		r, _ := http.NewRequest(http.MethodGet, "/?"+tt.query, nil)
+		err := form.Unmarshal(r, tt.target)
+		if err == nil || err.Error() != tt.expected {
+			t.Fatalf("wrong error for %s. want=%s, got=%v", tt.query, tt.expected, err)
+		}
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, "/?deep[a][b][c]=x", nil)
+	var actual deep
+	err := form.NewDecoder().MaxMapDepth(3).Unmarshal(r, &actual)
+	if err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	if actual.Deep["a"]["b"]["c"] != "x" {
+		t.Fatalf("wrong deep map. got=%v", actual.Deep)
+	}
+}
+
+func TestUnmarshalEncodedBytesError(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Val [4]byte `form:"value,hex"`
+	}
+	type notBytes struct {
+		Val [4]int `form:"value,hex"`
+	}
+	type both struct {
+		Val []byte `form:"value,hex,base64"`
+	}
+
+	testUnmarshalFormError(t, "zz", &s{}, "form: cannot unmarshal zz into Go struct field s.Val of type [4]uint8: encoding/hex: invalid byte: U+007A 'z'")
+	testUnmarshalFormError(t, "deadbe", &s{}, "form: cannot unmarshal deadbe into Go struct field s.Val of type [4]uint8: decoded 3 bytes but [4]uint8 needs 4")
+	testUnmarshalFormError(t, "de,ad", &s{}, "form: cannot unmarshal [de, ad] into Go struct field s.Val of type [4]uint8: cannot unmarshal more than one value for hex encoded field")
+	testUnmarshalFormError(t, "00", &notBytes{}, "form: invalid option hex in tag of Go struct field notBytes.Val: option only applies to byte array and slice fields, not [4]int")
+	testUnmarshalFormError(t, "00", &both{}, "form: invalid option base64 in tag of Go struct field both.Val: option cannot be combined with hex")
+}
+
+func TestUnmarshalJSONArrayError(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Val []int `form:"value,json"`
+	}
+	type notSlice struct {
+		Val int `form:"value,json"`
+	}
+	type withSep struct {
+		Val []int `form:"value,json,sep=;"`
+	}
+
+	testUnmarshalFormError(t, "[1;2]", &s{}, "form: cannot unmarshal [1;2] into Go struct field s.Val of type []int: invalid character ';' after array element")
+	testUnmarshalFormError(t, "[1],[2]", &s{}, "form: cannot unmarshal [[1], [2]] into Go struct field s.Val of type []int: cannot unmarshal more than one value for json encoded field")
+	testUnmarshalFormError(t, "1", &notSlice{}, "form: invalid option json in tag of Go struct field notSlice.Val: option only applies to slice and array fields, not int")
+	testUnmarshalFormError(t, "[1]", &withSep{}, "form: invalid option json in tag of Go struct field withSep.Val: option cannot be combined with sep")
+}
+
+func TestUnmarshalOverflowClamp(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Int8    int8    `form:"int8"`
+		Int16   int16   `form:"int16"`
+		Int64   int64   `form:"int64"`
+		Uint8   uint8   `form:"uint8"`
+		Uint64  uint64  `form:"uint64"`
+		Float32 float32 `form:"float32"`
+		Float64 float64 `form:"float64"`
+		Ints    []int8  `form:"ints"`
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, "/?int8=257&int16=-40000&int64=99999999999999999999&uint8=300&uint64=99999999999999999999&float32=1e39&float64=-1e400&ints=5&ints=-129", nil)
+	var actual s
+	err := form.NewDecoder().OverflowMode(form.OverflowClamp).Unmarshal(r, &actual)
+	if err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+
+	expected := s{
+		Int8:    math.MaxInt8,
+		Int16:   math.MinInt16,
+		Int64:   math.MaxInt64,
+		Uint8:   math.MaxUint8,
+		Uint64:  math.MaxUint64,
+		Float32: math.MaxFloat32,
+		Float64: -math.MaxFloat64,
+		Ints:    []int8{5, math.MinInt8},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("wrong clamped values. want=%+v, got=%+v", expected, actual)
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "/?uint8=-5&uint64=-99999999999999999999", nil)
+	actual = s{Uint8: 7, Uint64: 7}
+	err = form.NewDecoder().OverflowMode(form.OverflowClamp).Unmarshal(r, &actual)
+	if err != nil || actual.Uint8 != 0 || actual.Uint64 != 0 {
+		t.Fatalf("expected negative values to clamp to 0. got=%+v, err=%v", actual, err)
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "/?uint8=-x", nil)
+	err = form.NewDecoder().OverflowMode(form.OverflowClamp).Unmarshal(r, &actual)
+	if err == nil || err.Error() != "form: cannot unmarshal -x into Go struct field s.Uint8 of type uint8: strconv.ParseUint: parsing \"-x\": invalid syntax" {
+		t.Fatalf("expected syntax error to be returned when clamping. got=%v", err)
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "/?uint8=-1", nil)
+	err = form.NewDecoder().Unmarshal(r, &actual)
+	if err == nil || err.Error() != "form: cannot unmarshal -1 into Go struct field s.Uint8 of type uint8: strconv.ParseUint: parsing \"-1\": invalid syntax" {
+		t.Fatalf("expected negative values to be an error without clamping. got=%v", err)
+	}
+}
+
+func TestUnmarshalBoolInt(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Flag   int   `form:"flag,bool"`
+		Active uint8 `form:"active,bool"`
+		Flags  []int `form:"flags,bool"`
+		Plain  int   `form:"plain"`
+	}
+
+	tests := []struct {
+		value    string
+		expected int
+	}{
+		{"true", 1},
+		{"on", 1},
+		{"1", 1},
+		{"TRUE", 1},
+		{"false", 0},
+		{"off", 0},
+		{"0", 0},
+		{"5", 5},
+	}
+	for _, tt := range tests {
+		r, _ := http.NewRequest(http.MethodGet, "/?flag="+tt.value+"&active="+tt.value, nil)
+		actual := s{Flag: -1}
+		if err := form.Unmarshal(r, &actual); err != nil {
+			t.Fatalf("unexpected unmarshal error for %s: %s", tt.value, err)
+		}
+		if actual.Flag != tt.expected || int(actual.Active) != tt.expected {
+			t.Fatalf("wrong value for %s. want=%d, got=%+v", tt.value, tt.expected, actual)
+		}
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, "/?flags=on&flags=off&flags=2", nil)
+	var actual s
+	if err := form.Unmarshal(r, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	if !reflect.DeepEqual(actual.Flags, []int{1, 0, 2}) {
+		t.Fatalf("wrong flags. got=%v", actual.Flags)
+	}
+
+	testUnmarshalFormError(t, "on", &struct {
+		Plain int `form:"value"`
+	}{}, "form: cannot unmarshal on into Go struct field .Plain of type int: strconv.ParseInt: parsing \"on\": invalid syntax")
+	testUnmarshalFormError(t, "yes", &struct {
+		Flag int `form:"value,bool"`
+	}{}, "form: cannot unmarshal yes into Go struct field .Flag of type int: strconv.ParseInt: parsing \"yes\": invalid syntax")
+	testUnmarshalFormError(t, "on", &struct {
+		Flag string `form:"value,bool"`
+	}{}, "form: invalid option bool in tag of Go struct field .Flag: option only applies to integer fields, not string")
+}
+
+func TestUnmarshalUnderscores(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Int   int     `form:"int"`
+		Uint  uint16  `form:"uint"`
+		Ints  []int64 `form:"ints"`
+		Float float64 `form:"float"`
+	}
+	type tagged struct {
+		Int   int `form:"int,underscore"`
+		Plain int `form:"plain"`
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, "/?int=-1_000_000&uint=65_535&ints=1_0&ints=2&float=1.5", nil)
+	var actual s
+	if err := form.NewDecoder().Underscores().Unmarshal(r, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	expected := s{Int: -1000000, Uint: 65535, Ints: []int64{10, 2}, Float: 1.5}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("wrong struct. want=%+v, got=%+v", expected, actual)
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "/?int=1_000", nil)
+	var actualTagged tagged
+	if err := form.Unmarshal(r, &actualTagged); err != nil || actualTagged.Int != 1000 {
+		t.Fatalf("expected underscore option to strip underscores. got=%+v, err=%v", actualTagged, err)
+	}
+
+	tests := []struct {
+		query    string
+		decoder  *form.Decoder
+		expected string
+	}{
+		{"int=1_000", form.NewDecoder(), "form: cannot unmarshal 1_000 into Go struct field s.Int of type int: strconv.ParseInt: parsing \"1_000\": invalid syntax"},
+		{"int=_1", form.NewDecoder().Underscores(), "form: cannot unmarshal _1 into Go struct field s.Int of type int: strconv.ParseInt: parsing \"_1\": invalid syntax"},
+		{"int=1__0", form.NewDecoder().Underscores(), "form: cannot unmarshal 1__0 into Go struct field s.Int of type int: strconv.ParseInt: parsing \"1__0\": invalid syntax"},
+		{"int=0x1_0", form.NewDecoder().Underscores(), "form: cannot unmarshal 0x1_0 into Go struct field s.Int of type int: strconv.ParseInt: parsing \"0x1_0\": invalid syntax"},
+		{"uint=65_536", form.NewDecoder().Underscores(), "form: cannot unmarshal 65_536 into Go struct field s.Uint of type uint16: 65_536 overflows uint16 value"},
+	}
+	for _, tt := range tests {
+		r, _ := http.NewRequest(http.MethodGet, "/?"+tt.query, nil)
+		err := tt.decoder.Unmarshal(r, &s{})
+		if err == nil || err.Error() != tt.expected {
+			t.Fatalf("wrong error for %s. want=%s, got=%v", tt.query, tt.expected, err)
+		}
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "/?uint=99_999", nil)
+	var clamped s
+	if err := form.NewDecoder().Underscores().OverflowMode(form.OverflowClamp).Unmarshal(r, &clamped); err != nil || clamped.Uint != math.MaxUint16 {
+		t.Fatalf("expected underscored value to clamp. got=%+v, err=%v", clamped, err)
+	}
+
+	type invalid struct {
+		Name string `form:"name,underscore"`
+	}
+	testUnmarshalFormError(t, "1", &invalid{}, "form: invalid option underscore in tag of Go struct field invalid.Name: option only applies to integer fields, not string")
+}
+
+func TestUnmarshalIndexedKeys(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Items  []string `form:"items"`
+		Sparse []int    `form:"sparse"`
+		Array  [3]int   `form:"array"`
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, "/?items[2]=c&items[0]=a&items[1]=b&sparse[3]=3&sparse[1]=1&array[2]=9", nil)
+	var actual s
+	err := form.Unmarshal(r, &actual)
+	if err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+
+	expected := s{
+		Items:  []string{"a", "b", "c"},
+		Sparse: []int{0, 1, 0, 3},
+		Array:  [3]int{0, 0, 9},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("wrong indexed values. want=%+v, got=%+v", expected, actual)
+	}
+}
+
+func TestUnmarshalPercentEncodedBrackets(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Items  []string                     `form:"items"`
+		IDs    []int                        `form:"ids"`
+		Meta   map[string]string            `form:"meta"`
+		Config map[string]map[string]string `form:"config"`
+	}
+
+	query := "items%5B1%5D=b&items%5b0%5d=a&ids%5B%5D=1&ids%5B%5D=2&meta%5Bcolor%5D=red"
+	body := "config%5Bdb%5D%5Bhost%5D=x"
+	r, _ := http.NewRequest(http.MethodPost, "/?"+query, strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	var actual s
+	if err := form.Unmarshal(r, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+
+	expected := s{
+		Items:  []string{"a", "b"},
+		IDs:    []int{1, 2},
+		Meta:   map[string]string{"color": "red"},
+		Config: map[string]map[string]string{"db": {"host": "x"}},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("wrong values. want=%+v, got=%+v", expected, actual)
+	}
+}
+
+func TestUnmarshalEscapedBracketKeys(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Meta   map[string]string            `form:"meta"`
+		Config map[string]map[string]string `form:"config"`
+	}
+
+	values := url.Values{
+		`meta[a\[1\]]`:        {"x"},
+		`meta[back\\slash]`:   {"y"},
+		`meta[plain\path]`:    {"z"},
+		`config[\]\[][inner]`: {"w"},
+		`config[db][host\]]`:  {"v"},
+	}
+	r, _ := http.NewRequest(http.MethodGet, "/?"+values.Encode(), nil)
+	var actual s
+	if err := form.Unmarshal(r, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	expected := s{
+		Meta:   map[string]string{"a[1]": "x", `back\slash`: "y", `plain\path`: "z"},
+		Config: map[string]map[string]string{"][": {"inner": "w"}, "db": {"host]": "v"}},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("wrong struct. want=%+v, got=%+v", expected, actual)
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "/", nil)
+	if err := form.Marshal(r, &expected); err != nil {
+		t.Fatalf("unexpected marshal error: %s", err)
+	}
+	marshalled, _ := url.ParseQuery(r.URL.RawQuery)
+	if marshalled.Get(`meta[a\[1\]]`) != "x" || marshalled.Get(`meta[plain\\path]`) != "z" {
+		t.Fatalf("expected escaped keys. got=%v", marshalled)
+	}
+	var roundTrip s
+	if err := form.Unmarshal(r, &roundTrip); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	if !reflect.DeepEqual(roundTrip, expected) {
+		t.Fatalf("wrong round trip. want=%+v, got=%+v", expected, roundTrip)
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "/?"+url.Values{`meta[a[1]]`: {"x"}}.Encode(), nil)
+	if err := form.Unmarshal(r, &s{}); err == nil || !strings.Contains(err.Error(), "mismatched brackets") {
+		t.Fatalf("expected mismatched brackets error for unescaped key, got=%v", err)
+	}
+}
+
+func TestUnmarshalIndexedKeysError(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Items []string `form:"items"`
+		Array [2]int   `form:"array"`
+	}
+
+	tests := []struct {
+		query    string
+		decoder  *form.Decoder
+		expected string
+	}{
+		{"items[0]=a&items[2]=c", form.NewDecoder().SparseIndexMode(form.SparseError), "form: cannot unmarshal items[0], items[2] into Go struct field s.Items of type []string: indices are not contiguous from 0 to 2"},
+		{"array[2]=1", form.NewDecoder(), "form: cannot unmarshal array[2] into Go struct field s.Array of type [2]int: index 2 out of range for [2]int"},
+		{"array[0]=x", form.NewDecoder(), "form: cannot unmarshal array[0]=x into Go struct field s.Array of type [2]int: strconv.ParseInt: parsing \"x\": invalid syntax"},
+		{"items[-1]=a", form.NewDecoder(), "form: cannot unmarshal items[-1] into Go struct field s.Items of type []string: invalid index \"-1\""},
+		{"items[a]=a", form.NewDecoder(), "form: cannot unmarshal items[a] into Go struct field s.Items of type []string: invalid index \"a\""},
+		{"items[0][name]=a", form.NewDecoder(), "form: cannot unmarshal items[0][name] into Go struct field s.Items of type []string: key is not a single bracketed index"},
+		{"items[0]=a&items[0]=b", form.NewDecoder(), "form: cannot unmarshal items[0]=[a, b] into Go struct field s.Items of type []string: cannot unmarshal more than one value for index 0"},
+		{"items=a&items[1]=b", form.NewDecoder(), "form: cannot unmarshal items[1] into Go struct field s.Items of type []string: cannot mix indexed keys with unindexed values for items"},
+	}
+
+	for _, tt := range tests {
+		r, _ := http.NewRequest(http.MethodGet, "/?"+tt.query, nil)
+		err := tt.decoder.Unmarshal(r, &s{})
+		if err == nil || err.Error() != tt.expected {
+			t.Fatalf("wrong error for %s. want=%s, got=%v", tt.query, tt.expected, err)
+		}
+	}
+}
+
+func testUnmarshalFormData[T constraints.Ordered](t *testing.T, expected UrlFormData[T]) {
+	t.Helper()
+
+	var actual UrlFormData[T]
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		err := form.Unmarshal(r, &actual)
+		if err != nil {
+			t.Fatalf("unexpected unmarshal error: %s", err)
+		}
+	}))
+	defer server.Close()
+
+	r, err := http.NewRequest(http.MethodPost, server.URL, nil)
+	if err != nil {
+		t.Fatalf("unexpected error creating request: %s", err)
+	}
+	form := make(url.Values)
+	form.Add("single", fmt.Sprintf("%v", expected.Single))
+	for _, val := range expected.Slice {
+		form.Add("slice", fmt.Sprintf("%v", val))
+	}
+	for _, val := range expected.Array {
+		form.Add("array", fmt.Sprintf("%v", val))
+	}
+	r.URL.RawQuery = form.Encode()
+	r.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		t.Fatalf("unexpected error sending request: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if actual.Single != expected.Single {
+		t.Fatalf("single value does not match. want=%v, got=%v", expected.Single, actual.Single)
+	}
+	if len(actual.Slice) != len(expected.Slice) {
+		t.Fatalf("slices do not have the same length. want=%d, got=%d", len(expected.Slice), len(actual.Slice))
+	}
+	for i := 0; i < len(actual.Slice); i++ {
+		if actual.Slice[i] != expected.Slice[i] {
+			t.Fatalf("mismatch value at index %d of slice. want=%v, got=%v", i, expected.Slice[i], actual.Slice[i])
+		}
+	}
+
+	for i := 0; i < len(actual.Array); i++ {
+		if actual.Array[i] != expected.Array[i] {
+			t.Fatalf("mismatch value at index %d of array. want=%v, got=%v", i, expected.Array[i], actual.Array[i])
+		}
+	}
+}
+
+func TestParseFormError(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Val int `form:"value"`
+	}
+
+	r, err := http.NewRequest(http.MethodPost, "/", nil)
+	if err != nil {
+		t.Fatalf("unexpected error creating request: %s", err)
+	}
+
+	err = form.Unmarshal(r, &s{})
+	if err == nil {
+		t.Fatalf("expected error from r.ParseForm()")
+	}
+	var parseErr *form.ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("expected *form.ParseError; got %T", err)
+	}
+}
+
+func TestDecoderAllowJSON(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Name  string            `form:"name,minlen=2"`
+		Age   uint8             `form:"age"`
+		Price float64           `form:"price"`
+		Admin bool              `form:"admin"`
+		Tags  []string          `form:"tags"`
+		Meta  map[string]string `form:"meta"`
+		Note  string            `form:"note"`
+		Page  int               `form:"page"`
+	}
+
+	body := `{"name": "John", "age": 24, "price": 1.5e2, "admin": true, "tags": ["a", "b"], "meta": {"color": "red"}, "note": null}`
+	r, _ := http.NewRequest(http.MethodPost, "/?page=2", strings.NewReader(body))
+	r.Header.Add("Content-Type", "application/json; charset=utf-8")
+	var actual s
+	if err := form.NewDecoder().AllowJSON().Unmarshal(r, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	expected := s{Name: "John", Age: 24, Price: 150, Admin: true, Tags: []string{"a", "b"}, Meta: map[string]string{"color": "red"}, Page: 2}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("wrong struct. want=%+v, got=%+v", expected, actual)
+	}
+
+	tests := []struct {
+		body     string
+		expected string
+	}{
+		{`{"name": "J"}`, "form: invalid value J for Go struct field s.Name: length 1 is less than minlen 2"},
+		{`{"age": 300}`, "form: cannot unmarshal 300 into Go struct field s.Age of type uint8: 300 overflows uint8 value"},
+		{`{"tags": [["a"]]}`, "form: cannot parse request form: JSON value of tags is an array nested in an array"},
+		{`["a"]`, "form: cannot parse request form: json: cannot unmarshal array into Go value of type map[string]interface {}"},
+	}
+	for _, tt := range tests {
+		r, _ := http.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+		r.Header.Add("Content-Type", "application/json")
+		err := form.NewDecoder().AllowJSON().Unmarshal(r, &s{})
+		if err == nil || err.Error() != tt.expected {
+			t.Fatalf("wrong error for %s. want=%s, got=%v", tt.body, tt.expected, err)
+		}
+	}
+
+	r, _ = http.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name": "John"}`))
+	r.Header.Add("Content-Type", "application/json")
+	actual = s{}
+	if err := form.Unmarshal(r, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	if actual.Name != "" {
+		t.Fatalf("expected JSON body to be ignored without AllowJSON. got=%+v", actual)
+	}
+}
+
+func TestUnmarshalRawBody(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Event string `form:"event"`
+		Raw   []byte `form:",rawbody"`
+	}
+	type str struct {
+		Raw  string `form:"raw,rawbody"`
+		Page int    `form:"page"`
+	}
+
+	r, _ := http.NewRequest(http.MethodPost, "/", strings.NewReader("event=push&id=7"))
+	r.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	var actual s
+	if err := form.Unmarshal(r, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	if actual.Event != "push" || string(actual.Raw) != "event=push&id=7" {
+		t.Fatalf("wrong struct. got=%+v", actual)
+	}
+	rest, _ := io.ReadAll(r.Body)
+	if string(rest) != "event=push&id=7" {
+		t.Fatalf("expected body to be restored. got=%q", rest)
+	}
+
+	r, _ = http.NewRequest(http.MethodPost, "/?page=2", strings.NewReader(`{"event": "push"}`))
+	r.Header.Add("Content-Type", "application/json")
+	var actualStr str
+	if err := form.Unmarshal(r, &actualStr); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	if actualStr.Raw != `{"event": "push"}` || actualStr.Page != 2 {
+		t.Fatalf("wrong struct. got=%+v", actualStr)
+	}
+	testMarshalForm(t, &actualStr, "page=2")
+
+	r, _ = http.NewRequest(http.MethodGet, "/?page=3", nil)
+	actualStr = str{}
+	if err := form.Unmarshal(r, &actualStr); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	if actualStr.Raw != "" || actualStr.Page != 3 {
+		t.Fatalf("wrong struct without body. got=%+v", actualStr)
+	}
+
+	r, _ = http.NewRequest(http.MethodPost, "/", strings.NewReader(strings.Repeat("a", 10<<20+1)))
+	err := form.Unmarshal(r, &s{})
+	if err == nil || err.Error() != "form: cannot parse request form: request body too large" {
+		t.Fatalf("wrong error for large body. got=%v", err)
+	}
+
+	type invalid struct {
+		Raw int `form:"value,rawbody"`
+	}
+	testUnmarshalFormError(t, "1", &invalid{}, "form: invalid option rawbody in tag of Go struct field invalid.Raw: option only applies to string and []byte fields, not int")
+}
+
+func TestUnmarshalRawValues(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Name   string            `form:"name"`
+		IDs    []int             `form:"ids"`
+		Meta   map[string]string `form:"meta"`
+		All    url.Values        `form:",raw"`
+		Others url.Values        `form:",raw=unbound"`
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, "/?name=a&ids[0]=1&ids[1]=2&meta[color]=red&utm_source=mail&names=b", nil)
+	var actual s
+	if err := form.Unmarshal(r, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	expected := s{
+		Name: "a",
+		IDs:  []int{1, 2},
+		Meta: map[string]string{"color": "red"},
+		All: url.Values{
+			"name": {"a"}, "ids[0]": {"1"}, "ids[1]": {"2"}, "meta[color]": {"red"},
+			"utm_source": {"mail"}, "names": {"b"},
+		},
+		Others: url.Values{"utm_source": {"mail"}, "names": {"b"}},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("wrong struct. want=%+v, got=%+v", expected, actual)
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "/?name=a", nil)
+	if err := form.Unmarshal(r, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	if actual.Others != nil || !reflect.DeepEqual(actual.All, url.Values{"name": {"a"}}) {
+		t.Fatalf("wrong raw values. got all=%v, others=%v", actual.All, actual.Others)
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "/", nil)
+	if err := form.Marshal(r, &expected); err != nil {
+		t.Fatalf("unexpected marshal error: %s", err)
+	}
+	if r.URL.RawQuery != "ids=1&ids=2&meta%5Bcolor%5D=red&name=a" {
+		t.Fatalf("raw fields should not be marshalled. got=%s", r.URL.RawQuery)
+	}
+
+	type invalid struct {
+		Raw map[string]string `form:",raw=unbound"`
+	}
+	err := form.Unmarshal(r, &invalid{})
+	var tagErr *form.InvalidTagError
+	if !errors.As(err, &tagErr) || tagErr.Option != "raw" {
+		t.Fatalf("expected InvalidTagError for raw, got=%v", err)
+	}
+}
+
+func TestUnmarshalMulti(t *testing.T) {
+	t.Parallel()
+	type pagination struct {
+		Page  int `form:"page"`
+		Limit int `form:"limit"`
+	}
+	type filter struct {
+		Query string   `form:"q"`
+		Tags  []string `form:"tags"`
+	}
+	type search struct {
+		Query string `form:"q"`
+		Limit int    `form:"limit"`
+	}
+
+	r, _ := http.NewRequest(http.MethodPost, "/?page=2&q=go", strings.NewReader("limit=50&tags=a&tags=b"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	var p pagination
+	var f filter
+	var s search
+	if err := form.UnmarshalMulti(r, &p, &f, &s); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	if p != (pagination{Page: 2, Limit: 50}) {
+		t.Fatalf("wrong pagination. got=%+v", p)
+	}
+	if !reflect.DeepEqual(f, filter{Query: "go", Tags: []string{"a", "b"}}) {
+		t.Fatalf("wrong filter. got=%+v", f)
+	}
+	if s != (search{Query: "go", Limit: 50}) {
+		t.Fatalf("overlapping keys should bind every target. got=%+v", s)
+	}
+
+	r, _ = http.NewRequest(http.MethodPost, "/", strings.NewReader(`{"page": 3, "q": "json"}`))
+	r.Header.Set("Content-Type", "application/json")
+	p, f = pagination{}, filter{}
+	if err := form.NewDecoder().AllowJSON().UnmarshalMulti(r, &p, &f); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	if p.Page != 3 || f.Query != "json" {
+		t.Fatalf("the body should be read once for every target. got=%+v, %+v", p, f)
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "/?page=2&limit=many", nil)
+	err := form.UnmarshalMulti(r, &filter{}, &pagination{})
+	var targetErr *form.TargetError
+	if !errors.As(err, &targetErr) || targetErr.Index != 1 || targetErr.Type != reflect.TypeOf(&pagination{}) {
+		t.Fatalf("expected TargetError for the second target, got=%v", err)
+	}
+	var typeErr *form.UnmarshalTypeError
+	if !errors.As(err, &typeErr) || typeErr.Struct != "pagination" || typeErr.Field != "Limit" {
+		t.Fatalf("expected UnmarshalTypeError for pagination.Limit, got=%v", err)
+	}
+	want := `form: cannot unmarshal many into Go struct field pagination.Limit of type int: strconv.ParseInt: parsing "many": invalid syntax (target 1, *form_test.pagination)`
+	if err.Error() != want {
+		t.Fatalf("wrong error. want=%s, got=%s", want, err)
+	}
+}
+
+func TestUnmarshalValues(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Name   string `form:"name,minlen=2"`
+		Page   int    `form:"page"`
+		Method string `form:",method"`
+	}
+
+	var actual s
+	if err := form.UnmarshalValues(url.Values{"name": {"ann"}, "page": {"2"}}, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	if actual != (s{Name: "ann", Page: 2}) {
+		t.Fatalf("wrong struct. got=%+v", actual)
+	}
+
+	err := form.UnmarshalValues(url.Values{"name": {"a"}}, &s{})
+	var validationErr *form.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("expected ValidationError. got=%v", err)
+	}
+
+	var seen url.Values
+	d := form.NewDecoder().SkipErrors().Validator(func(i interface{}, values url.Values) error {
+		seen = values
+		return nil
+	})
+	values := url.Values{"name": {"bob"}, "page": {"x"}}
+	actual = s{Method: "GET"}
+	if err := d.DecodeFrom(values, &actual); err != nil {
+		t.Fatalf("unexpected decode error: %s", err)
+	}
+	if actual != (s{Name: "bob", Method: "GET"}) {
+		t.Fatalf("wrong struct. got=%+v", actual)
+	}
+	if !reflect.DeepEqual(seen, values) {
+		t.Fatalf("expected validator to receive the values. got=%v", seen)
+	}
+
+	err = form.UnmarshalValues(url.Values{}, s{})
+	var invalidErr *form.InvalidUnmarshalError
+	if !errors.As(err, &invalidErr) {
+		t.Fatalf("expected InvalidUnmarshalError. got=%v", err)
+	}
+}
+
+func TestUnmarshalRequestAttrs(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Method     string `form:",method"`
+		Path       string `form:"route,path"`
+		FormMethod string `form:"method"`
+		Name       string `form:"name"`
+	}
+
+	r, _ := http.NewRequest(http.MethodDelete, "/users/7?name=ann&method=email&route=x", nil)
+	var actual s
+	if err := form.Unmarshal(r, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	expected := s{Method: "DELETE", Path: "/users/7", FormMethod: "email", Name: "ann"}
+	if actual != expected {
+		t.Fatalf("wrong struct. expected=%+v, got=%+v", expected, actual)
+	}
+	testMarshalForm(t, &actual, "method=email&name=ann")
+
+	type invalid struct {
+		Method int `form:",method"`
+	}
+	testUnmarshalFormError(t, "1", &invalid{}, "form: invalid option method in tag of Go struct field invalid.Method: option only applies to string fields, not int")
+
+	type both struct {
+		Raw string `form:",rawbody,path"`
+	}
+	testUnmarshalFormError(t, "1", &both{}, "form: invalid option path in tag of Go struct field both.Raw: option cannot be combined with rawbody")
+}
+
+func TestUnmarshalPathSegments(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Resource string `form:",segment=0"`
+		PathID   int    `form:",segment=1,match=id"`
+		ID       int    `form:"id"`
+		Name     string `form:"name"`
+	}
+
+	tests := []struct {
+		path     string
+		body     string
+		expected s
+		err      string
+	}{
+		{"/users/7", "id=7&name=ann", s{Resource: "users", PathID: 7, ID: 7, Name: "ann"}, ""},
+		{"/users/7/", "name=ann", s{Resource: "users", PathID: 7, Name: "ann"}, ""},
+		{"/users", "id=7", s{Resource: "users", ID: 7}, "form: invalid value 0 for Go struct field s.PathID: does not match id=7"},
+		{"/users/7", "id=8", s{}, "form: invalid value 7 for Go struct field s.PathID: does not match id=8"},
+		{"/users/x", "id=7", s{}, `form: cannot unmarshal x into Go struct field s.PathID of type int: strconv.ParseInt: parsing "x": invalid syntax`},
+	}
+	for _, tt := range tests {
+		r, _ := http.NewRequest(http.MethodPut, tt.path, strings.NewReader(tt.body))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		var actual s
+		err := form.Unmarshal(r, &actual)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Fatalf("wrong error for %s %s. want=%s, got=%v", tt.path, tt.body, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("unexpected unmarshal error for %s: %s", tt.path, err)
+		}
+		if actual != tt.expected {
+			t.Fatalf("wrong struct for %s. want=%+v, got=%+v", tt.path, tt.expected, actual)
+		}
+	}
+
+	type named struct {
+		ID      int64  `form:",pathvalue=id,match=id"`
+		Missing string `form:",pathvalue=slug"`
+		BodyID  int64  `form:"id"`
+	}
+	mux := http.NewServeMux()
+	var actual named
+	var err error
+	mux.HandleFunc("PUT /items/{id}", func(w http.ResponseWriter, r *http.Request) {
+		err = form.Unmarshal(r, &actual)
+	})
+	r := httptest.NewRequest(http.MethodPut, "/items/42?id=42", nil)
+	mux.ServeHTTP(httptest.NewRecorder(), r)
+	if err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	if actual != (named{ID: 42, BodyID: 42}) {
+		t.Fatalf("wrong struct. got=%+v", actual)
+	}
+	r = httptest.NewRequest(http.MethodPut, "/items/42?id=41", nil)
+	mux.ServeHTTP(httptest.NewRecorder(), r)
+	var validationErr *form.ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Option != "match" || validationErr.Field != "ID" {
+		t.Fatalf("expected ValidationError for match, got=%v", err)
+	}
+
+	testUnmarshalFormError(t, "1", &struct {
+		IDs []int `form:",segment=1"`
+	}{}, "form: invalid option segment in tag of Go struct field .IDs: option only applies to single value fields, not []int")
+	testUnmarshalFormError(t, "1", &struct {
+		ID int `form:",segment=-1"`
+	}{}, "form: invalid option segment in tag of Go struct field .ID: \"-1\" is not a non-negative integer")
+	testUnmarshalFormError(t, "1", &struct {
+		ID int `form:",pathvalue=id,match=uid"`
+	}{}, "form: invalid option match in tag of Go struct field .ID: struct has no other field with key \"uid\"")
+}
+
+func TestUnmarshalMultipart(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Title string `form:"title"`
+		Page  int    `form:"page"`
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("title", "report")
+	fw, _ := mw.CreateFormFile("upload", "report.txt")
+	fw.Write(bytes.Repeat([]byte("a"), 1024))
+	mw.Close()
+
+	newRequest := func() *http.Request {
+		r, _ := http.NewRequest(http.MethodPost, "/?page=2", bytes.NewReader(body.Bytes()))
+		r.Header.Set("Content-Type", mw.FormDataContentType())
+		return r
+	}
+
+	var actual s
+	if err := form.Unmarshal(newRequest(), &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	if actual.Title != "" || actual.Page != 2 {
+		t.Fatalf("expected multipart body to be ignored without Multipart. got=%+v", actual)
+	}
+
+	r := newRequest()
+	actual = s{}
+	cleanup, err := form.NewDecoder().Multipart(16).UnmarshalMultipart(r, &actual)
+	if err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	if actual.Title != "report" || actual.Page != 2 {
+		t.Fatalf("wrong struct. got=%+v", actual)
+	}
+	fh := r.MultipartForm.File["upload"][0]
+	f, err := fh.Open()
+	if err != nil {
+		t.Fatalf("unexpected error opening upload: %s", err)
+	}
+	f.Close()
+	if err := cleanup(); err != nil {
+		t.Fatalf("unexpected cleanup error: %s", err)
+	}
+	if f, err := fh.Open(); err == nil {
+		f.Close()
+		t.Fatalf("expected cleanup to remove the temporary file")
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "/?page=3", nil)
+	cleanup, err = form.NewDecoder().Multipart(16).UnmarshalMultipart(r, &actual)
+	if err != nil || actual.Page != 3 || cleanup() != nil {
+		t.Fatalf("expected no-op cleanup without a multipart body. got=%+v, err=%v", actual, err)
+	}
+
+	r, _ = http.NewRequest(http.MethodPost, "/", strings.NewReader("--x\r\nbroken"))
+	r.Header.Set("Content-Type", "multipart/form-data; boundary=x")
+	cleanup, err = form.NewDecoder().Multipart(16).UnmarshalMultipart(r, &actual)
+	var parseErr *form.ParseError
+	if !errors.As(err, &parseErr) || cleanup == nil || cleanup() != nil {
+		t.Fatalf("expected ParseError and usable cleanup. got=%v", err)
+	}
+}
+
+func TestUnmarshalSourceOption(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Token string `form:"token,in=body"`
+		Page  int    `form:"page,in=query"`
+		Name  string `form:"name"`
+	}
+
+	newRequest := func(query, body string) *http.Request {
+		r, _ := http.NewRequest(http.MethodPost, "/?"+query, strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return r
+	}
+
+	var actual s
+	if err := form.Unmarshal(newRequest("token=evil&page=2&name=q", "token=good&page=9"), &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	if actual != (s{Token: "good", Page: 2, Name: "q"}) {
+		t.Fatalf("wrong struct. got=%+v", actual)
+	}
+
+	actual = s{}
+	if err := form.Unmarshal(newRequest("token=evil", "name=b"), &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	if actual != (s{Name: "b"}) {
+		t.Fatalf("expected the query token to be ignored. got=%+v", actual)
+	}
+
+	actual = s{}
+	if err := form.UnmarshalValues(url.Values{"token": {"v"}, "page": {"3"}}, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	if actual != (s{Token: "v", Page: 3}) {
+		t.Fatalf("expected supplied values to be read for every source. got=%+v", actual)
+	}
+
+	r, _ := http.NewRequest(http.MethodPost, "/?token=evil&page=4", strings.NewReader(`{"page": 9}`))
+	r.Header.Set("Content-Type", "application/json")
+	actual = s{}
+	if err := form.NewDecoder().AllowJSON().Unmarshal(r, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	if actual != (s{Page: 4}) {
+		t.Fatalf("expected the query token to be ignored for a JSON body. got=%+v", actual)
+	}
+
+	type invalid struct {
+		Token string `form:"token,in=header"`
+	}
+	err := form.ValidateStruct(&invalid{})
+	want := "form: invalid option in in tag of Go struct field invalid.Token: unknown source \"header\", expected body or query"
+	if err == nil || err.Error() != want {
+		t.Fatalf("wrong error. expected=%s, got=%v", want, err)
+	}
+}
+
+func TestUnmarshalSourceChain(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Token string `form:"x-token,source=body|query|header"`
+		Page  int    `form:"page,source=query|body,default=1"`
+	}
+
+	newRequest := func(query, body, header string) *http.Request {
+		r, _ := http.NewRequest(http.MethodPost, "/?"+query, strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if header != "" {
+			r.Header.Set("X-Token", header)
+		}
+		return r
+	}
+
+	tests := []struct {
+		name     string
+		r        *http.Request
+		expected s
+	}{
+		{"body", newRequest("x-token=q&page=2", "x-token=b&page=9", "h"), s{Token: "b", Page: 2}},
+		{"query", newRequest("x-token=q", "page=9", "h"), s{Token: "q", Page: 9}},
+		{"header", newRequest("", "", "h"), s{Token: "h", Page: 1}},
+		{"none", newRequest("", "", ""), s{Page: 1}},
+	}
+
+	for _, tt := range tests {
+		var actual s
+		if err := form.Unmarshal(tt.r, &actual); err != nil {
+			t.Fatalf("unexpected unmarshal error for %s: %s", tt.name, err)
+		}
+		if actual != tt.expected {
+			t.Fatalf("wrong struct when %s wins. want=%+v, got=%+v", tt.name, tt.expected, actual)
+		}
+	}
+
+	type bodyOnly struct {
+		Token string `form:"token,source=body"`
+		Page  int    `form:"page,source=body|query"`
+	}
+	r, _ := http.NewRequest(http.MethodPost, "/?token=evil&page=4", strings.NewReader(`{"token": null}`))
+	r.Header.Set("Content-Type", "application/json")
+	var fromJSON bodyOnly
+	if err := form.NewDecoder().AllowJSON().Unmarshal(r, &fromJSON); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	if fromJSON != (bodyOnly{Page: 4}) {
+		t.Fatalf("expected the query token to be ignored for a JSON body. got=%+v", fromJSON)
+	}
+
+	r, _ = http.NewRequest(http.MethodPost, "/?token=evil&page=4", strings.NewReader(`{"token": "good", "page": 9}`))
+	r.Header.Set("Content-Type", "application/json")
+	fromJSON = bodyOnly{}
+	if err := form.NewDecoder().AllowJSON().Unmarshal(r, &fromJSON); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	if fromJSON != (bodyOnly{Token: "good", Page: 9}) {
+		t.Fatalf("expected the JSON body to win. got=%+v", fromJSON)
+	}
+
+	type invalid struct {
+		Token string `form:"token,source=body|cookie"`
+	}
+	err := form.ValidateStruct(&invalid{})
+	want := "form: invalid option source in tag of Go struct field invalid.Token: unknown source \"cookie\", expected body, query or header"
+	if err == nil || err.Error() != want {
+		t.Fatalf("wrong error. expected=%s, got=%v", want, err)
+	}
+}
+
+func TestDecoderUnmarshalRecord(t *testing.T) {
+	t.Parallel()
+	type item struct {
+		Name string `form:"name"`
+	}
+	type s struct {
+		Name  string   `form:"name"`
+		Tags  []string `form:"tags,sep"`
+		Page  int      `form:"page,default=1"`
+		Items []item   `form:"items"`
+		Count int      `form:"count"`
+	}
+
+	d := form.NewDecoder()
+	raw := make(map[string][]string)
+	r, _ := http.NewRequest(http.MethodGet, "/?name=ann&tags=a,b&count=x&items[0][name]=bolt", nil)
+	var actual s
+	err := d.UnmarshalRecord(r, &actual, raw)
+	if err == nil {
+		t.Fatalf("expected an error for count")
+	}
+	expected := map[string][]string{"name": {"ann"}, "tags": {"a,b"}, "count": {"x"}, "items[0].name": {"bolt"}}
+	if !reflect.DeepEqual(raw, expected) {
+		t.Fatalf("wrong raw values. expected=%v, got=%v", expected, raw)
+	}
+	if actual.Name != "ann" || !reflect.DeepEqual(actual.Tags, []string{"a", "b"}) || actual.Page != 1 {
+		t.Fatalf("wrong struct. got=%+v", actual)
+	}
+
+	// A shared Decoder records each call into its own map.
+	var wg sync.WaitGroup
+	for n := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			name := strconv.Itoa(n)
+			raw := make(map[string][]string)
+			r, _ := http.NewRequest(http.MethodGet, "/?name="+name, nil)
+			var actual s
+			if err := d.UnmarshalRecord(r, &actual, raw); err != nil {
+				t.Errorf("unexpected error: %s", err)
+				return
+			}
+			if !reflect.DeepEqual(raw, map[string][]string{"name": {name}}) {
+				t.Errorf("wrong raw values for %s. got=%v", name, raw)
+			}
+		}()
+	}
+	wg.Wait()
+
+	r, _ = http.NewRequest(http.MethodGet, "/?name=ann", nil)
+	if err := d.Unmarshal(r, &actual); err != nil || len(raw) != len(expected) {
+		t.Fatalf("expected Unmarshal not to record values. got=%v, err=%v", raw, err)
+	}
+}
+
+func TestDecoderMaxBodySize(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Name string `form:"name"`
+	}
+
+	newRequest := func(body string, contentType string, length int64) *http.Request {
+		r, _ := http.NewRequest(http.MethodPost, "/", io.NopCloser(strings.NewReader(body)))
+		r.Header.Set("Content-Type", contentType)
+		r.ContentLength = length
+		return r
+	}
+
+	d := form.NewDecoder().AllowJSON().MaxBodySize(16)
+	long := "name=" + strings.Repeat("a", 20)
+	tests := []struct {
+		name string
+		r    *http.Request
+	}{
+		{"declared length", newRequest(long, "application/x-www-form-urlencoded", int64(len(long)))},
+		{"unknown length", newRequest(long, "application/x-www-form-urlencoded", -1)},
+		{"unknown length json", newRequest(`{"name":"`+strings.Repeat("a", 20)+`"}`, "application/json", -1)},
+	}
+	for _, tt := range tests {
+		err := d.Unmarshal(tt.r, &s{})
+		var parseErr *form.ParseError
+		if !errors.As(err, &parseErr) || !errors.Is(err, form.ErrBodyTooLarge) {
+			t.Errorf("expected ParseError wrapping ErrBodyTooLarge for %s. got=%v", tt.name, err)
+		}
+	}
+
+	declared := newRequest(long, "application/x-www-form-urlencoded", 1<<40)
+	declared.Body = io.NopCloser(iotest.ErrReader(errors.New("body was read")))
+	if err := d.Unmarshal(declared, &s{}); !errors.Is(err, form.ErrBodyTooLarge) {
+		t.Fatalf("expected the declared length to be rejected before reading. got=%v", err)
+	}
+
+	var actual s
+	if err := d.Unmarshal(newRequest("name=ann", "application/x-www-form-urlencoded", -1), &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	if actual.Name != "ann" {
+		t.Fatalf("wrong struct. got=%+v", actual)
+	}
+}
+
+func TestDecoderLogger(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Name     string `form:"name"`
+		password string `form:"password"`
+		Email    string
+		Age      int
+	}
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+	var actual s
+	values := url.Values{"name": {"ann"}, "password": {"pw"}, "email": {"a@b.c"}}
+	if err := form.NewDecoder().Logger(logger).DecodeFrom(values, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	if actual != (s{Name: "ann"}) {
+		t.Fatalf("expected binding to be unchanged. got=%+v", actual)
+	}
+
+	expected := "level=WARN msg=\"form: tagged field is unexported and cannot be set\" struct=s field=password key=password\n" +
+		"level=WARN msg=\"form: field has no key but the form has a value for its name\" struct=s field=Email key=email\n"
+	if buf.String() != expected {
+		t.Fatalf("wrong log. want=%q, got=%q", expected, buf.String())
+	}
+}
+
+func TestParseFormConsumedBody(t *testing.T) {
+	t.Parallel()
+	type s struct {
+		Val int `form:"value"`
+	}
+
+	r, _ := http.NewRequest(http.MethodPost, "/", strings.NewReader("value=5"))
+	r.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	// simulate middleware that reads the body without restoring it
+	io.ReadAll(r.Body)
+
+	err := form.Unmarshal(r, &s{})
+	if !errors.Is(err, form.ErrBodyConsumed) {
+		t.Fatalf("expected form.ErrBodyConsumed; got %v", err)
+	}
+	var parseErr *form.ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("expected *form.ParseError; got %T", err)
+	}
+
+	r, _ = http.NewRequest(http.MethodPost, "/", strings.NewReader("value=5"))
+	r.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	var actual s
+	if err := form.Unmarshal(r, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	if actual.Val != 5 {
+		t.Fatalf("wrong value. want=%d, got=%d", 5, actual.Val)
+	}
+}
+
+func testUnmarshalFormError(t *testing.T, value string, i interface{}, expectedError string) {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		err := form.Unmarshal(r, i)
+		if err == nil || err.Error() != expectedError {
+			t.Errorf("wrong expected error. want=%s, got=%s", expectedError, err)
+		}
+	}))
+	defer server.Close()
+
+	r, err := http.NewRequest(http.MethodPost, server.URL, nil)
+	if err != nil {
+		t.Fatalf("unexpected error creating request: %s", err)
+	}
+	form := make(url.Values)
+	for _, val := range strings.Split(value, ",") {
+		form.Add("value", val)
+	}
+	r.URL.RawQuery = form.Encode()
+	r.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		t.Fatalf("unexpected error sending request: %s", err)
+	}
+	resp.Body.Close()
+}
+
+func TestUnmarshalPrefixedStructs(t *testing.T) {
+	t.Parallel()
+	type address struct {
+		City string `form:"city"`
+		Zip  string `form:"zip"`
+	}
+	type userInfo struct {
+		Name    string  `form:"name,required"`
+		Email   string  `form:"email"`
+		Address address `form:",prefix=addr_"`
+	}
+	type s struct {
+		User    userInfo `form:",prefix=user_"`
+		Billing address  `form:"billing,prefix=b_"`
+		Name    string   `form:"name"`
+	}
+
+	values := url.Values{
+		"user_name":      {"ann"},
+		"user_email":     {"ann@example.com"},
+		"user_addr_city": {"Oslo"},
+		"user_addr_zip":  {"0150"},
+		"billing.b_city": {"Bergen"},
+		"name":           {"account"},
+	}
+	var actual s
+	if err := form.UnmarshalValues(values, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	expected := s{
+		User:    userInfo{Name: "ann", Email: "ann@example.com", Address: address{City: "Oslo", Zip: "0150"}},
+		Billing: address{City: "Bergen"},
+		Name:    "account",
+	}
+	if actual != expected {
+		t.Fatalf("wrong struct. want=%+v, got=%+v", expected, actual)
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, "/", nil)
+	if err := form.Marshal(r, &expected); err != nil {
+		t.Fatalf("unexpected marshal error: %s", err)
+	}
+	want := "billing.b_city=Bergen&billing.b_zip=&name=account&user_addr_city=Oslo&user_addr_zip=0150&user_email=ann%40example.com&user_name=ann"
+	if r.URL.RawQuery != want {
+		t.Fatalf("wrong query. want=%s, got=%s", want, r.URL.RawQuery)
+	}
+
+	err := form.UnmarshalValues(url.Values{"user_email": {"x"}}, &s{})
+	if err == nil || err.Error() != "form: missing value for required Go struct field userInfo.Name" {
+		t.Fatalf("wrong error. got=%v", err)
+	}
+
+	type notStruct struct {
+		ID int `form:"id,prefix=x_"`
+	}
+	type withOptions struct {
+		User userInfo `form:",prefix=user_,omitempty"`
+	}
+	for _, tt := range []struct {
+		target   interface{}
+		expected string
+	}{
+		{&notStruct{}, "form: invalid option prefix in tag of Go struct field notStruct.ID: option only applies to nested struct fields, not int"},
+		{&withOptions{}, "form: invalid option prefix in tag of Go struct field withOptions.User: option cannot be combined with other options"},
+	} {
+		err := form.ValidateStruct(tt.target)
+		if err == nil || err.Error() != tt.expected {
+			t.Fatalf("wrong error. want=%s, got=%v", tt.expected, err)
+		}
+	}
+}
+
+func TestUnmarshalConcurrent(t *testing.T) {
+	t.Parallel()
+	type address struct {
+		City string `form:"city,required"`
+		Zip  string `form:"zip,pattern=^[0-9]+$"`
+	}
+	type item struct {
+		Name string `form:"name"`
+		Qty  int    `form:"qty,default=1"`
+	}
+	// s is only used by this test, so its fields are parsed and cached while the goroutines race to use it.
+	type s struct {
+		_       struct{}          `form:",omitempty"`
+		ID      int               `form:"id,min=1"`
+		Tags    []string          `form:"tags,sep"`
+		Address address           `form:"address"`
+		User    address           `form:",prefix=user_"`
+		Items   []item            `form:"items"`
+		Meta    map[string]string `form:"meta"`
+		When    time.Time         `form:"when,layout=2006-01-02"`
+	}
+
+	decoder := form.NewDecoder().LenientNumbers()
+	var wg sync.WaitGroup
+	errs := make(chan error, 64)
+	for g := 0; g < 64; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			query := fmt.Sprintf("id=%dpx&tags=a,b&address.city=c%d&address.zip=%d&user_city=u&items[0][name]=n%d&meta[k]=%d&when=2024-05-0%d",
+				g+1, g, g, g, g, g%9+1)
+			r, _ := http.NewRequest(http.MethodGet, "/?"+query, nil)
+			var actual s
+			if err := decoder.Unmarshal(r, &actual); err != nil {
+				errs <- err
+				return
+			}
+			if actual.ID != g+1 || actual.Address.City != fmt.Sprintf("c%d", g) || actual.Items[0].Name != fmt.Sprintf("n%d", g) ||
+				actual.Items[0].Qty != 1 || actual.Meta["k"] != fmt.Sprint(g) || actual.When.Day() != g%9+1 {
+				errs <- fmt.Errorf("goroutine %d bound another's values: %+v", g, actual)
+				return
+			}
+
+			out, _ := http.NewRequest(http.MethodGet, "/", nil)
+			actual.Items = nil
+			if err := form.Marshal(out, &actual); err != nil {
+				errs <- err
+				return
+			}
+			if out.URL.Query().Get("address.city") != fmt.Sprintf("c%d", g) {
+				errs <- fmt.Errorf("goroutine %d marshalled another's values: %s", g, out.URL.RawQuery)
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+}
+
+func TestUnmarshalNestedStructs(t *testing.T) {
+	t.Parallel()
+	type geo struct {
+		Lat float64 `form:"lat"`
+		Lng float64 `form:"lng"`
+	}
+	type address struct {
+		Street string `form:"street,required"`
+		Geo    geo    `form:"geo"`
+	}
+	type Meta struct {
+		Source string `form:"source"`
+	}
+	type s struct {
+		Meta
+		Name    string  `form:"name"`
+		Address address `form:"address"`
+		Tags    []int   `form:"tags,count=Count"`
+		Count   int
+		Errors  map[string]string `form:",errors"`
+	}
+
+	values := url.Values{
+		"name":            {"ann"},
+		"source":          {"web"},
+		"address.street":  {"Main St"},
+		"address.geo.lat": {"1.5"},
+		"address.geo.lng": {"-2"},
+		"tags":            {"1", "2"},
+	}
+	var actual s
+	if err := form.UnmarshalValues(values, &actual); err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	expected := s{
+		Meta:    Meta{Source: "web"},
+		Name:    "ann",
+		Address: address{Street: "Main St", Geo: geo{Lat: 1.5, Lng: -2}},
+		Tags:    []int{1, 2},
+		Count:   2,
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("wrong struct. got=%+v", actual)
+	}
+
+	actual = s{}
+	err := form.NewDecoder().SkipErrors().DecodeFrom(url.Values{"address.geo.lat": {"x"}}, &actual)
+	if err != nil {
+		t.Fatalf("unexpected unmarshal error: %s", err)
+	}
+	if actual.Errors["address.street"] == "" || actual.Errors["address.geo.lat"] == "" {
+		t.Fatalf("expected errors of the nested fields. got=%v", actual.Errors)
+	}
+}
+
+func BenchmarkUnmarshalRepeatedKeys(b *testing.B) {
+	type s struct {
+		IDs     []int          `form:"ids"`
+		Names   []string       `form:"names"`
+		Tags    []string       `form:"tags,sep"`
+		Options map[string]int `form:"options"`
+	}
+
+	values := make(url.Values)
+	for i := 0; i < 5000; i++ {
+		values.Add("ids", strconv.Itoa(i))
+		values.Add("names[]", "name"+strconv.Itoa(i))
+		values.Set("options["+strconv.Itoa(i)+"]", strconv.Itoa(i))
+	}
+	values.Set("tags", strings.Repeat("tag,", 4999)+"tag")
+	r, _ := http.NewRequest(http.MethodGet, "/?"+values.Encode(), nil)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var actual s
+		err := form.Unmarshal(r, &actual)
+		if err != nil {
+			b.Fatalf("unexpected unmarshal error: %s", err)
+		}
+	}
+}
+
+type benchLeaf struct {
+	A string `form:"a"`
+	B int    `form:"b"`
+}
+
+type benchMiddle struct {
+	C    string    `form:"c"`
+	Leaf benchLeaf `form:"leaf"`
+}
+
+type benchRoot struct {
+	D      string      `form:"d"`
+	Middle benchMiddle `form:"middle"`
+}
+
+// benchFlat holds the fields of benchRoot at the top level, under the keys the nested fields are bound to.
+type benchFlat struct {
+	D string `form:"d"`
+	C string `form:"middle.c"`
+	A string `form:"middle.leaf.a"`
+	B int    `form:"middle.leaf.b"`
+}
+
+var benchNestedValues = url.Values{"d": {"d"}, "middle.c": {"c"}, "middle.leaf.a": {"a"}, "middle.leaf.b": {"1"}}
+
+// BenchmarkUnmarshalNestedIndexPath binds a 3-level struct through the index paths cached for its leaf fields.
+// Compare it with BenchmarkUnmarshalNestedFlat, which binds the same form into a single level struct,
+// to measure what the nesting costs.
+func BenchmarkUnmarshalNestedIndexPath(b *testing.B) {
+	values := benchNestedValues
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var actual benchRoot
+		err := form.UnmarshalValues(values, &actual)
+		if err != nil {
+			b.Fatalf("unexpected unmarshal error: %s", err)
+		}
+	}
+}
+
+// BenchmarkUnmarshalNestedFlat binds the form of BenchmarkUnmarshalNestedIndexPath into benchFlat.
+func BenchmarkUnmarshalNestedFlat(b *testing.B) {
+	values := benchNestedValues
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var actual benchFlat
+		err := form.UnmarshalValues(values, &actual)
+		if err != nil {
+			b.Fatalf("unexpected unmarshal error: %s", err)
+		}
+		if actual.B != 1 {
+			b.Fatalf("wrong value. got=%d", actual.B)
+		}
 	}
 }
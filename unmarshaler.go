@@ -0,0 +1,86 @@
+package form
+
+import (
+	"reflect"
+	"strings"
+)
+
+// Unmarshaler is the interface implemented by types that can unmarshal themselves from form values.
+// UnmarshalForm receives every value of the field's key, after any sep splitting,
+// and is only called if the form has at least one value for the key.
+// Elements of a slice or array field whose element type implements Unmarshaler receive their single value each.
+//
+// Methods promoted from embedded fields count, so a field of a struct type that embeds a type
+// implementing Unmarshaler is unmarshalled entirely by the promoted method, and the outer struct's
+// own fields are not set from the form. Nil exported embedded pointers along the way are allocated before the call;
+// if an unexported embedded pointer is nil the field is handled as if it did not implement Unmarshaler.
+// The struct passed to [Unmarshal] is never unmarshalled through the interface, even if it implements it,
+// so embedding such a type in a request struct does not change how the request struct is bound.
+//
+// Functions registered with [Decoder.RegisterDecoder] or [RegisterType] take precedence over
+// Unmarshaler, which takes precedence over [sql.Scanner], [encoding.TextUnmarshaler] and the built-in
+// handling of the type's kind.
+type Unmarshaler interface {
+	UnmarshalForm(values []string) error
+}
+
+var unmarshalerType = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+
+// unmarshaler returns the Unmarshaler of the addressable value f, if its pointer implements it.
+// It reports false if the method is promoted through a nil embedded pointer that cannot be allocated.
+func unmarshaler(f reflect.Value) (Unmarshaler, bool) {
+	if !f.CanAddr() || !f.Addr().Type().Implements(unmarshalerType) {
+		return nil, false
+	}
+	if !allocEmbedded(f) {
+		return nil, false
+	}
+	return f.Addr().Interface().(Unmarshaler), true
+}
+
+// allocEmbedded allocates the nil embedded struct pointers of the struct f, recursively,
+// so methods promoted through them can be called. It reports false if an unexported
+// embedded pointer is nil, as it cannot be set.
+func allocEmbedded(f reflect.Value) bool {
+	if f.Kind() != reflect.Struct {
+		return true
+	}
+	ok := true
+	t := f.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.Anonymous {
+			continue
+		}
+		fv := f.Field(i)
+		if sf.Type.Kind() == reflect.Pointer && sf.Type.Elem().Kind() == reflect.Struct {
+			if fv.IsNil() {
+				if !fv.CanSet() {
+					ok = false
+					continue
+				}
+				fv.Set(reflect.New(sf.Type.Elem()))
+			}
+			fv = fv.Elem()
+		}
+		ok = allocEmbedded(fv) && ok
+	}
+	return ok
+}
+
+// unmarshalForm calls the UnmarshalForm method of u with values, wrapping any error in a [UnmarshalTypeError] for f.
+func unmarshalForm(u Unmarshaler, f reflect.Value, values []string) *UnmarshalTypeError {
+	err := u.UnmarshalForm(values)
+	if err != nil {
+		value := values[0]
+		if len(values) > 1 {
+			value = "[" + strings.Join(values, ", ") + "]"
+		}
+		return &UnmarshalTypeError{
+			Value: value,
+			Type:  f.Type(),
+			Err:   err,
+		}
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package form
+
+import "reflect"
+
+// UntaggedFieldNames makes the Decoder bind exported fields without a key, whether untagged or tagged
+// with options only such as `form:",required"`, using the Go name of the field as the key, so Name binds Name=ann.
+// By default such fields are skipped, as they are by [Encoder], and a value sent under an empty key binds nothing.
+// A key from [Decoder.FallbackTag] takes precedence, and [Decoder.KeyFunc] receives the field name as the tag's key.
+func (d *Decoder) UntaggedFieldNames() *Decoder {
+	d.untaggedNames = true
+	return d
+}
+
+// UntaggedFieldNames makes the Encoder write exported fields without a key under the Go name of the field,
+// matching [Decoder.UntaggedFieldNames].
+func (e *Encoder) UntaggedFieldNames() *Encoder {
+	e.untaggedNames = true
+	return e
+}
+
+// nameKey returns the Go name of the field f of the struct s as its key, or "" if the field is unexported
+// or is an embedded field.
+func (f field) nameKey(s reflect.Value) string {
+	sf := s.Type().Field(f.index)
+	if !sf.IsExported() || sf.Anonymous {
+		return ""
+	}
+	return f.name
+}
@@ -0,0 +1,235 @@
+package form
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"unicode/utf8"
+)
+
+// rules are the validation options of a "form" struct tag.
+// A negative length bound means the option was not set.
+type rules struct {
+	minLen  int
+	maxLen  int
+	pattern *regexp.Regexp
+
+	// Bounds of numeric values, parsed for the kind of the field so that integers
+	// beyond 2^53 are compared exactly: only the pair matching the kind is set.
+	min, max         float64
+	minInt, maxInt   int64
+	minUint, maxUint uint64
+	minText, maxText string // bounds as written in errors
+	hasMin, hasMax   bool
+}
+
+// parseRules reads the validation options in opts for a field of type t.
+// The options of slices, arrays and pointers apply to their elements.
+// If an option value is malformed or does not apply to t then a [InvalidTagError] is returned.
+func parseRules(t reflect.Type, opts tagOptions) (rules, *InvalidTagError) {
+	rs := rules{minLen: -1, maxLen: -1}
+	base := baseType(elemType(t))
+	for _, opt := range []string{"minlen", "maxlen"} {
+		v, ok := opts.Get(opt)
+		if !ok {
+			continue
+		}
+		if base.Kind() != reflect.String {
+			return rs, &InvalidTagError{
+				Option: opt,
+				Err:    inapplicable("string", t),
+			}
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return rs, &InvalidTagError{
+				Option: opt,
+				Err:    fmt.Errorf("%q is not a non-negative integer", v),
+			}
+		}
+		if opt == "minlen" {
+			rs.minLen = n
+		} else {
+			rs.maxLen = n
+		}
+	}
+
+	if v, ok := opts.Get("pattern"); ok {
+		if base.Kind() != reflect.String {
+			return rs, &InvalidTagError{
+				Option: "pattern",
+				Err:    inapplicable("string", t),
+			}
+		}
+		re, err := regexp.Compile(v)
+		if err != nil {
+			return rs, &InvalidTagError{
+				Option: "pattern",
+				Err:    err,
+			}
+		}
+		rs.pattern = re
+	}
+
+	for _, opt := range []string{"min", "max"} {
+		v, ok := opts.Get(opt)
+		if !ok {
+			continue
+		}
+		if !isFloat(base) && (!isInteger(base) || base == durationType) {
+			return rs, &InvalidTagError{
+				Option: opt,
+				Err:    inapplicable("number", t),
+			}
+		}
+		if err := rs.setBound(opt, v, base); err != nil {
+			return rs, &InvalidTagError{
+				Option: opt,
+				Err:    err,
+			}
+		}
+	}
+	return rs, nil
+}
+
+// setBound parses v as the min or max bound, named by opt, of numbers of type t:
+// as a signed or unsigned integer for integer types and as a float otherwise.
+func (rs *rules) setBound(opt, v string, t reflect.Type) error {
+	isMin := opt == "min"
+	var text string
+	switch {
+	case isFloat(t):
+		n, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", v)
+		}
+		if isMin {
+			rs.min = n
+		} else {
+			rs.max = n
+		}
+		text = fmt.Sprintf("%g", n)
+	case t.Kind() >= reflect.Uint && t.Kind() <= reflect.Uint64:
+		n, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return boundError(v, "a non-negative integer")
+		}
+		if isMin {
+			rs.minUint = n
+		} else {
+			rs.maxUint = n
+		}
+		text = strconv.FormatUint(n, 10)
+	default:
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return boundError(v, "an integer")
+		}
+		if isMin {
+			rs.minInt = n
+		} else {
+			rs.maxInt = n
+		}
+		text = strconv.FormatInt(n, 10)
+	}
+	if isMin {
+		rs.minText, rs.hasMin = text, true
+	} else {
+		rs.maxText, rs.hasMax = text, true
+	}
+	return nil
+}
+
+// boundError returns the error for an integer bound v that is not what, such as "an integer".
+// Values that are not numbers at all are reported as such.
+func boundError(v, what string) error {
+	if _, err := strconv.ParseFloat(v, 64); err != nil {
+		return fmt.Errorf("%q is not a number", v)
+	}
+	return fmt.Errorf("%q is not %s", v, what)
+}
+
+// validate checks the value of f against the rules, or each element of a slice or array,
+// in which case the error names the index of the first element that fails.
+// String lengths are counted in runes, not bytes.
+func (rs rules) validate(f reflect.Value) *ValidationError {
+	switch f.Kind() {
+	case reflect.Pointer:
+		if f.IsNil() {
+			return nil
+		}
+		return rs.validate(f.Elem())
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < f.Len(); i++ {
+			if err := rs.validate(f.Index(i)); err != nil {
+				err.Err = fmt.Errorf("element %d: %w", i, err.Err)
+				return err
+			}
+		}
+		return nil
+	case reflect.String:
+		return rs.validateString(f.String())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n := f.Int()
+		return rs.validateNumber(n < rs.minInt, n > rs.maxInt, func() string { return strconv.FormatInt(n, 10) })
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n := f.Uint()
+		return rs.validateNumber(n < rs.minUint, n > rs.maxUint, func() string { return strconv.FormatUint(n, 10) })
+	case reflect.Float32, reflect.Float64:
+		n := f.Float()
+		return rs.validateNumber(n < rs.min, n > rs.max, func() string {
+			return strconv.FormatFloat(n, 'g', -1, f.Type().Bits())
+		})
+	}
+	return nil
+}
+
+// validateString checks the string value against the length and pattern rules.
+func (rs rules) validateString(value string) *ValidationError {
+	n := utf8.RuneCountInString(value)
+	if rs.minLen >= 0 && n < rs.minLen {
+		return &ValidationError{
+			Value:  value,
+			Option: "minlen",
+			Err:    fmt.Errorf("length %d is less than minlen %d", n, rs.minLen),
+		}
+	}
+	if rs.maxLen >= 0 && n > rs.maxLen {
+		return &ValidationError{
+			Value:  value,
+			Option: "maxlen",
+			Err:    fmt.Errorf("length %d is greater than maxlen %d", n, rs.maxLen),
+		}
+	}
+	if rs.pattern != nil && !rs.pattern.MatchString(value) {
+		return &ValidationError{
+			Value:  value,
+			Option: "pattern",
+			Err:    fmt.Errorf("value does not match pattern %s", rs.pattern),
+		}
+	}
+	return nil
+}
+
+// validateNumber checks a number against the min and max rules. below and above report whether it is
+// less than the min and greater than the max bound of its kind, and format returns it as text for the error.
+func (rs rules) validateNumber(below, above bool, format func() string) *ValidationError {
+	if rs.hasMin && below {
+		value := format()
+		return &ValidationError{
+			Value:  value,
+			Option: "min",
+			Err:    fmt.Errorf("%s is less than min %s", value, rs.minText),
+		}
+	}
+	if rs.hasMax && above {
+		value := format()
+		return &ValidationError{
+			Value:  value,
+			Option: "max",
+			Err:    fmt.Errorf("%s is greater than max %s", value, rs.maxText),
+		}
+	}
+	return nil
+}
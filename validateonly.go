@@ -0,0 +1,23 @@
+package form
+
+import (
+	"net/http"
+	"reflect"
+)
+
+// ValidateOnly reports whether the request would unmarshal into i without error, without changing i.
+// It runs the whole of [Decoder.Unmarshal], including tag validation and validators, against a new zero value
+// of the struct type i points to, so i is only used for its type and may be a nil pointer such as (*Signup)(nil).
+// Validators receive a pointer to the new value rather than i.
+//
+// The request's form is parsed and cached as usual, and bodies the Decoder reads in full are restored,
+// so ValidateOnly can be followed by Unmarshal on the same request.
+func (d *Decoder) ValidateOnly(r *http.Request, i interface{}) error {
+	t := reflect.TypeOf(i)
+	if t == nil || t.Kind() != reflect.Pointer || t.Elem().Kind() != reflect.Struct {
+		return &InvalidUnmarshalError{
+			Type: t,
+		}
+	}
+	return d.Unmarshal(r, reflect.New(t.Elem()).Interface())
+}
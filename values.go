@@ -0,0 +1,133 @@
+package form
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+var valuesType = reflect.TypeOf(url.Values(nil))
+
+// subFormKeys returns the keys of form nested under key, either as key.name or key[name],
+// in sorted order.
+func subFormKeys(form url.Values, key string) []string {
+	var keys []string
+	for k := range form {
+		if len(k) > len(key)+1 && strings.HasPrefix(k, key) && (k[len(key)] == '.' || k[len(key)] == '[') {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// subFormKey returns the key within a sub-form of the form key k nested under key.
+// The prefix key. is removed, and key[name]rest becomes name followed by rest,
+// so extra[a][b] becomes a[b] and extra.a.b becomes a.b.
+func subFormKey(k, key string) (string, bool) {
+	rest := k[len(key):]
+	if rest[0] == '.' {
+		return rest[1:], true
+	}
+	end := strings.IndexByte(rest, ']')
+	if end < 0 {
+		return "", false
+	}
+	return rest[1:end] + rest[end+1:], true
+}
+
+// parseSubForm collects the values of every key of the form nested under key into the url.Values field f.
+// f is left unchanged if no nested keys are present.
+func (d *Decoder) parseSubForm(f reflect.Value, key string, form url.Values) *UnmarshalTypeError {
+	keys := subFormKeys(form, key)
+	if len(keys) == 0 {
+		return nil
+	}
+	values := make(url.Values, len(keys))
+	for _, k := range keys {
+		sub, ok := subFormKey(k, key)
+		if !ok {
+			return &UnmarshalTypeError{
+				Value: k,
+				Type:  f.Type(),
+				Err:   fmt.Errorf("mismatched brackets"),
+			}
+		}
+		values[sub] = append(values[sub], form[k]...)
+		if err := d.checkSliceLen(f.Type(), k, len(values[sub])); err != nil {
+			return err
+		}
+	}
+	f.Set(reflect.ValueOf(values))
+	return nil
+}
+
+// marshalSubForm adds the values of the url.Values v to form under key in the notation m, so with
+// [NestingBracket] a["b"] becomes key[b] and a["b[c]"] becomes key[b][c], and with [NestingDot] they
+// become key.b and key.b[c]. The new form keys are appended to keys in sorted order.
+func marshalSubForm(key string, v url.Values, m Nesting, form url.Values, keys []string) []string {
+	subKeys := make([]string, 0, len(v))
+	for k := range v {
+		subKeys = append(subKeys, k)
+	}
+	sort.Strings(subKeys)
+	for _, k := range subKeys {
+		fk := m.nestedKey(key, k)
+		if i := strings.IndexByte(k, '['); i >= 0 {
+			fk = m.nestedKey(key, k[:i]) + k[i:]
+		}
+		if _, seen := form[fk]; !seen && len(v[k]) > 0 {
+			keys = append(keys, fk)
+		}
+		form[fk] = append(form[fk], v[k]...)
+	}
+	return keys
+}
+
+// rawValues returns a copy of form for a field with the raw option. With mode "unbound" the keys
+// belonging to the fields in bound are left out, whether read as the key itself or nested under it,
+// as in key[0] or key.name. The result is nil if no keys remain.
+func rawValues(form url.Values, mode string, bound []string) url.Values {
+	var values url.Values
+	for k, v := range form {
+		if mode == "unbound" && boundKey(k, bound) {
+			continue
+		}
+		if values == nil {
+			values = make(url.Values, len(form))
+		}
+		values[k] = append([]string(nil), v...)
+	}
+	return values
+}
+
+// boundKey reports whether the form key k is one of bound or nested under one of them.
+func boundKey(k string, bound []string) bool {
+	for _, key := range bound {
+		if k == key || len(k) > len(key) && strings.HasPrefix(k, key) && (k[len(key)] == '[' || k[len(key)] == '.') {
+			return true
+		}
+	}
+	return false
+}
+
+// valuesOf returns the [url.Values] held by v, reading it through reflection so that
+// the values of unexported fields can be marshalled too.
+func valuesOf(v reflect.Value) url.Values {
+	if v.IsNil() {
+		return nil
+	}
+	values := make(url.Values, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		vs := iter.Value()
+		strs := make([]string, vs.Len())
+		for i := range strs {
+			strs[i] = vs.Index(i).String()
+		}
+		values[iter.Key().String()] = strs
+	}
+	return values
+}